@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/config"
+)
+
+type agentConfig struct {
+	Heartbeats []heartbeatConfig `yaml:"heartbeats"`
+}
+
+// heartbeatConfig describes one heartbeat published by the agent. It must
+// stay comparable so reloads can detect changed entries with ==.
+type heartbeatConfig struct {
+	Subject      string          `yaml:"subject"`
+	Interval     config.Duration `yaml:"interval"`
+	Grace        config.Duration `yaml:"grace"`
+	Description  string          `yaml:"description"`
+	Probe        string          `yaml:"probe"`
+	ProbeTimeout config.Duration `yaml:"probe_timeout"`
+}
+
+// loadConfig reads the heartbeat list from path, filling unset intervals
+// with defaultInterval.
+func loadConfig(path string, defaultInterval time.Duration) ([]heartbeatConfig, error) {
+	var cfg agentConfig
+	if err := config.Load(path, &cfg); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(cfg.Heartbeats))
+	for i := range cfg.Heartbeats {
+		hb := &cfg.Heartbeats[i]
+		if hb.Subject == "" {
+			return nil, fmt.Errorf("heartbeat %d: subject is required", i+1)
+		}
+		if seen[hb.Subject] {
+			return nil, fmt.Errorf("heartbeat %d: duplicate subject %q", i+1, hb.Subject)
+		}
+		seen[hb.Subject] = true
+		if hb.Interval == 0 {
+			hb.Interval = config.Duration(defaultInterval)
+		}
+		if hb.Interval < 0 || hb.Grace < 0 || hb.ProbeTimeout < 0 {
+			return nil, fmt.Errorf("heartbeat %q: durations cannot be negative", hb.Subject)
+		}
+	}
+	return cfg.Heartbeats, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []heartbeatConfig
+		wantErr string
+	}{
+		{
+			name: "defaults interval",
+			content: `heartbeats:
+  - subject: heartbeat.api
+    grace: 30s
+    description: API
+  - subject: heartbeat.db
+    interval: 1m
+    probe: pg_isready
+    probe_timeout: 5s
+`,
+			want: []heartbeatConfig{
+				{Subject: "heartbeat.api", Interval: config.Duration(15 * time.Second), Grace: config.Duration(30 * time.Second), Description: "API"},
+				{Subject: "heartbeat.db", Interval: config.Duration(time.Minute), Probe: "pg_isready", ProbeTimeout: config.Duration(5 * time.Second)},
+			},
+		},
+		{
+			name:    "empty file",
+			content: "",
+		},
+		{
+			name:    "missing subject",
+			content: "heartbeats:\n  - interval: 1m\n",
+			wantErr: "heartbeat 1: subject is required",
+		},
+		{
+			name:    "duplicate subject",
+			content: "heartbeats:\n  - subject: a\n  - subject: a\n",
+			wantErr: `heartbeat 2: duplicate subject "a"`,
+		},
+		{
+			name:    "negative duration",
+			content: "heartbeats:\n  - subject: a\n    grace: -1s\n",
+			wantErr: "durations cannot be negative",
+		},
+		{
+			name:    "unknown field",
+			content: "heartbeats:\n  - subject: a\n    intervall: 1m\n",
+			wantErr: "intervall",
+		},
+	}
+
+	for _, tc := range cases {
+		path := filepath.Join(t.TempDir(), "agent.yaml")
+		if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		got, err := loadConfig(path, 15*time.Second)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %d heartbeats, got %+v", tc.name, len(tc.want), got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: heartbeat %d: expected %+v, got %+v", tc.name, i, tc.want[i], got[i])
+			}
+		}
+	}
+}
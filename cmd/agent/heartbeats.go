@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+// agent runs one publishing loop per configured heartbeat.
+type agent struct {
+	nc              *nats.Conn
+	pub             *heartbeat.Publisher
+	logger          *slog.Logger
+	flushTimeout    time.Duration
+	exitOnFlushFail bool
+	// stop shuts the whole agent down (used by exit-on-flush-fail).
+	stop context.CancelFunc
+
+	wg      sync.WaitGroup
+	running map[string]*runner
+}
+
+type runner struct {
+	cfg    heartbeatConfig
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// apply starts, restarts or stops heartbeat loops so that exactly cfgs are
+// running. It must only be called from one goroutine.
+func (a *agent) apply(ctx context.Context, cfgs []heartbeatConfig) {
+	if a.running == nil {
+		a.running = make(map[string]*runner)
+	}
+
+	wanted := make(map[string]heartbeatConfig, len(cfgs))
+	for _, cfg := range cfgs {
+		wanted[cfg.Subject] = cfg
+	}
+
+	for subject, r := range a.running {
+		cfg, ok := wanted[subject]
+		if ok && cfg == r.cfg {
+			continue
+		}
+		r.cancel()
+		<-r.done
+		delete(a.running, subject)
+		if !ok {
+			a.logger.Info("heartbeat removed", "subject", subject)
+		}
+	}
+
+	for _, cfg := range cfgs {
+		if _, ok := a.running[cfg.Subject]; ok {
+			continue
+		}
+		a.start(ctx, cfg)
+	}
+}
+
+func (a *agent) start(ctx context.Context, cfg heartbeatConfig) {
+	runCtx, cancel := context.WithCancel(ctx)
+	r := &runner{cfg: cfg, cancel: cancel, done: make(chan struct{})}
+	a.running[cfg.Subject] = r
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer close(r.done)
+		a.run(runCtx, cfg)
+	}()
+	a.logger.Info("heartbeat started", "subject", cfg.Subject, "interval", cfg.Interval, "grace", cfg.Grace, "probe", cfg.Probe)
+}
+
+// wait blocks until every heartbeat loop has exited.
+func (a *agent) wait() {
+	a.wg.Wait()
+}
+
+func (a *agent) run(ctx context.Context, cfg heartbeatConfig) {
+	interval := time.Duration(cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.beat(ctx, cfg)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *agent) beat(ctx context.Context, cfg heartbeatConfig) {
+	if cfg.Probe != "" {
+		if err := runProbe(ctx, cfg); err != nil {
+			a.logger.Warn("probe failed, skipping heartbeat", "subject", cfg.Subject, "probe", cfg.Probe, "err", err)
+			return
+		}
+	}
+
+	hb := heartbeat.Message{
+		Subject:     cfg.Subject,
+		GeneratedAt: time.Now().UTC(),
+		Interval:    time.Duration(cfg.Interval),
+		Description: cfg.Description,
+	}
+	if cfg.Grace > 0 {
+		grace := time.Duration(cfg.Grace)
+		hb.GracePeriod = &grace
+	}
+
+	if err := a.pub.Publish(ctx, hb); err != nil {
+		a.logger.Error("publish heartbeat failed", "err", err, "subject", hb.Subject)
+	} else if err := flushWithTimeout(ctx, a.nc, a.flushTimeout); err != nil {
+		a.logger.Warn("heartbeat flush failed", "err", err, "subject", hb.Subject, "timeout", a.flushTimeout)
+		if a.exitOnFlushFail {
+			a.logger.Error("exiting due to flush failure", "subject", hb.Subject)
+			a.stop()
+		}
+	} else {
+		a.logger.Debug("heartbeat published", "subject", hb.Subject, "interval", hb.Interval, "grace", hb.GracePeriod)
+	}
+}
+
+// runProbe runs the heartbeat's probe command through the shell; the
+// heartbeat is only published when it exits successfully.
+func runProbe(ctx context.Context, cfg heartbeatConfig) error {
+	timeout := time.Duration(cfg.ProbeTimeout)
+	if timeout <= 0 {
+		timeout = time.Duration(cfg.Interval)
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return exec.CommandContext(probeCtx, "sh", "-c", cfg.Probe).Run()
+}
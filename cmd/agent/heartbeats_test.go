@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/config"
+)
+
+func TestAgentApply(t *testing.T) {
+	// a failing probe keeps the loops from publishing, so no connection is
+	// needed
+	hb := func(subject string, interval time.Duration) heartbeatConfig {
+		return heartbeatConfig{Subject: subject, Interval: config.Duration(interval), Probe: "exit 1"}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := &agent{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), stop: cancel}
+
+	a.apply(ctx, []heartbeatConfig{hb("a", time.Hour), hb("b", time.Hour), hb("c", time.Hour)})
+	if len(a.running) != 3 {
+		t.Fatalf("expected 3 running heartbeats, got %d", len(a.running))
+	}
+	before := make(map[string]*runner, len(a.running))
+	for subject, r := range a.running {
+		before[subject] = r
+	}
+
+	// keep a, change b, drop c and add d
+	a.apply(ctx, []heartbeatConfig{hb("a", time.Hour), hb("b", time.Minute), hb("d", time.Hour)})
+
+	cases := []struct {
+		subject   string
+		running   bool
+		restarted bool
+	}{
+		{"a", true, false},
+		{"b", true, true},
+		{"c", false, false},
+		{"d", true, false},
+	}
+	for _, tc := range cases {
+		r, ok := a.running[tc.subject]
+		if ok != tc.running {
+			t.Errorf("%s: expected running %t, got %t", tc.subject, tc.running, ok)
+			continue
+		}
+		if old, existed := before[tc.subject]; ok && existed && (r != old) != tc.restarted {
+			t.Errorf("%s: expected restarted %t", tc.subject, tc.restarted)
+		}
+	}
+	if got := time.Duration(a.running["b"].cfg.Interval); got != time.Minute {
+		t.Errorf("expected b to run with the new interval, got %s", got)
+	}
+	for _, subject := range []string{"b", "c"} {
+		select {
+		case <-before[subject].done:
+		default:
+			t.Errorf("%s: expected the old loop to have stopped", subject)
+		}
+	}
+
+	a.apply(ctx, nil)
+	if len(a.running) != 0 {
+		t.Fatalf("expected every heartbeat to stop, got %d running", len(a.running))
+	}
+	a.wait()
+}
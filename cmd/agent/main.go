@@ -12,18 +12,25 @@ import (
 
 	"github.com/nats-io/nats.go"
 
+	"github.com/venkytv/nats-heartbeat/internal/config"
+	"github.com/venkytv/nats-heartbeat/internal/natsconn"
 	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
 )
 
 func main() {
+	var connOpts natsconn.Options
+	connOpts.RegisterFlags(flag.CommandLine)
+
 	var (
-		natsURL         = flag.String("nats-url", envDefault("NATS_URL", nats.DefaultURL), "NATS server URL")
-		subject         = flag.String("subject", envDefault("SUBJECT", ""), "Full heartbeat subject (required)")
-		interval        = flag.Duration("interval", envDuration("INTERVAL", 15*time.Second), "Heartbeat interval")
+		configPath      = flag.String("config", envDefault("CONFIG", ""), "Optional YAML/JSON file listing heartbeats to publish (reloaded on SIGHUP)")
+		subject         = flag.String("subject", envDefault("SUBJECT", ""), "Full heartbeat subject (required without -config)")
+		interval        = flag.Duration("interval", envDuration("INTERVAL", 15*time.Second), "Heartbeat interval (default for config entries)")
 		grace           = flag.Duration("grace", envDuration("GRACE", 0), "Optional max duration to miss beats before alerting")
 		desc            = flag.String("description", envDefault("DESCRIPTION", ""), "Human-friendly description for alerts")
+		probe           = flag.String("probe", envDefault("PROBE", ""), "Optional shell command; heartbeats are only published while it succeeds")
 		flushTimeout    = flag.Duration("flush-timeout", envDuration("FLUSH_TIMEOUT", 2*time.Second), "How long to wait for NATS flush after publish")
 		exitOnFlushFail = flag.Bool("exit-on-flush-fail", envBool("EXIT_ON_FLUSH_FAIL", false), "Exit when flush fails instead of just logging")
+		signingSeed     = flag.String("signing-seed", envDefault("SIGNING_SEED", ""), "Optional nkey seed file used to sign heartbeats")
 		debug           = flag.Bool("debug", envBool("DEBUG", false), "Enable debug logging")
 	)
 	flag.Parse()
@@ -38,52 +45,84 @@ func main() {
 	}
 	slog.SetDefault(logger)
 
-	if *subject == "" {
+	loadHeartbeats := func() ([]heartbeatConfig, error) {
+		return []heartbeatConfig{{
+			Subject:     *subject,
+			Interval:    config.Duration(*interval),
+			Grace:       config.Duration(*grace),
+			Description: *desc,
+			Probe:       *probe,
+		}}, nil
+	}
+	if *configPath != "" {
+		if *subject != "" {
+			logger.Warn("-subject is ignored when -config is set", "subject", *subject)
+		}
+		loadHeartbeats = func() ([]heartbeatConfig, error) {
+			return loadConfig(*configPath, *interval)
+		}
+	} else if *subject == "" {
 		log.Fatal("subject is required")
 	}
 
+	heartbeats, err := loadHeartbeats()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	nc, err := connectWithRetry(ctx, logger, *natsURL)
+	connOpts.Name = "heartbeat-agent"
+	nc, err := natsconn.Connect(ctx, logger, connOpts, natsconn.Hooks{})
 	if err != nil {
 		logger.Error("connect to nats failed", "err", err)
 		return
 	}
 	defer nc.Drain()
 
-	pub := heartbeat.NewPublisher(nc, "")
-
-	ticker := time.NewTicker(*interval)
-	defer ticker.Stop()
-
-	for {
-		hb := heartbeat.Message{
-			Subject:     *subject,
-			GeneratedAt: time.Now().UTC(),
-			Interval:    *interval,
-			Description: *desc,
-		}
-		if grace != nil && *grace > 0 {
-			hb.GracePeriod = grace
+	var pubOpts []heartbeat.PublisherOption
+	if *signingSeed != "" {
+		signer, err := heartbeat.LoadSigner(*signingSeed)
+		if err != nil {
+			logger.Error("load signing seed failed", "err", err)
+			return
 		}
+		logger.Info("signing heartbeats", "public_key", signer.PublicKey())
+		pubOpts = append(pubOpts, heartbeat.WithSigner(signer))
+	}
 
-		if err := pub.Publish(ctx, hb); err != nil {
-			logger.Error("publish heartbeat failed", "err", err, "subject", hb.Subject)
-		} else if err := flushWithTimeout(ctx, nc, *flushTimeout); err != nil {
-			logger.Warn("heartbeat flush failed", "err", err, "subject", hb.Subject, "timeout", *flushTimeout)
-			if *exitOnFlushFail {
-				logger.Error("exiting due to flush failure", "subject", hb.Subject)
-				return
-			}
-		} else {
-			logger.Debug("heartbeat published", "subject", hb.Subject, "interval", hb.Interval, "grace", hb.GracePeriod)
-		}
+	a := &agent{
+		nc:              nc,
+		pub:             heartbeat.NewPublisher(nc, "", pubOpts...),
+		logger:          logger,
+		flushTimeout:    *flushTimeout,
+		exitOnFlushFail: *exitOnFlushFail,
+		stop:            cancel,
+	}
+	a.apply(ctx, heartbeats)
+	defer a.wait()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 
+	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-hup:
+			if *configPath == "" {
+				logger.Info("ignoring SIGHUP without -config")
+				continue
+			}
+			heartbeats, err := loadHeartbeats()
+			if err != nil {
+				logger.Error("reload config failed; keeping current heartbeats", "err", err)
+				continue
+			}
+			logger.Info("reloading config", "path", *configPath, "heartbeats", len(heartbeats))
+			a.apply(ctx, heartbeats)
 		}
 	}
 }
@@ -111,65 +150,6 @@ func envDuration(key string, fallback time.Duration) time.Duration {
 	return fallback
 }
 
-func connectWithRetry(ctx context.Context, logger *slog.Logger, url string) (*nats.Conn, error) {
-	backoff := time.Second
-	const maxBackoff = 30 * time.Second
-
-	for {
-		nc, err := nats.Connect(
-			url,
-			nats.MaxReconnects(-1), // never give up once connected
-			nats.ReconnectWait(2*time.Second),
-			nats.RetryOnFailedConnect(true), // keep trying initial connects with the same backoff policy
-			nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
-				if err == nil {
-					return
-				}
-				if sub != nil {
-					logger.Warn("nats async error", "err", err, "subject", sub.Subject)
-					return
-				}
-				logger.Warn("nats async error", "err", err)
-			}),
-			nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
-				if err != nil {
-					logger.Warn("nats disconnected", "err", err)
-					return
-				}
-				logger.Warn("nats disconnected")
-			}),
-			nats.ReconnectHandler(func(_ *nats.Conn) {
-				logger.Info("nats reconnected")
-			}),
-			nats.ClosedHandler(func(nc *nats.Conn) {
-				if nc != nil && nc.LastError() != nil {
-					logger.Error("nats connection closed; will restart if context allows", "err", nc.LastError())
-					return
-				}
-				logger.Error("nats connection closed; will restart if context allows")
-			}),
-		)
-		if err == nil {
-			return nc, nil
-		}
-
-		logger.Error("connect to nats failed", "err", err, "retry_in", backoff)
-
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(backoff):
-		}
-
-		if backoff < maxBackoff {
-			backoff *= 2
-			if backoff > maxBackoff {
-				backoff = maxBackoff
-			}
-		}
-	}
-}
-
 func flushWithTimeout(ctx context.Context, nc *nats.Conn, timeout time.Duration) error {
 	if nc == nil || timeout <= 0 {
 		return nil
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/config"
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+)
+
+// settings holds the monitor options that may come from flags or the
+// config file.
+type settings struct {
+	Prefix           string
+	PrimeStream      string
+	PollEvery        time.Duration
+	RepeatEvery      time.Duration
+	StatusAddr       string
+	StatusTLSCert    string
+	StatusTLSKey     string
+	StatusClientCA   string
+	StatusAuthFile   string
+	StatusAuth       []monitor.Credential
+	ExpireAfter      time.Duration
+	MaxSkew          time.Duration
+	MaxDrift         float64
+	HostWindow       time.Duration
+	PerHost          bool
+	TrustedKeysFile  string
+	TrustRules       []monitor.TrustRule
+	RejectUnverified bool
+	AdminSubject     string
+	ServiceSubject   string
+	EventSubject     string
+	EventStream      string
+	HistorySize      int
+	HistoryStream    string
+	HistorySubject   string
+	UptimeWindows    string
+	Uptime           []time.Duration
+	ShardGroup       string
+	ShardMember      string
+	ShardHeartbeat   time.Duration
+	PushoverUser     string
+	PushoverToken    string
+}
+
+// fileConfig is the on-disk config format. Unset fields keep the flag value.
+type fileConfig struct {
+	SubjectPrefix    *string            `yaml:"subject_prefix"`
+	PrimeStream      *string            `yaml:"prime_stream"`
+	Poll             *config.Duration   `yaml:"poll"`
+	RepeatEvery      *config.Duration   `yaml:"repeat_every"`
+	StatusAddr       *string            `yaml:"status_addr"`
+	StatusTLSCert    *string            `yaml:"status_tls_cert"`
+	StatusTLSKey     *string            `yaml:"status_tls_key"`
+	StatusClientCA   *string            `yaml:"status_client_ca"`
+	StatusAuthFile   *string            `yaml:"status_auth_file"`
+	StatusAuth       []credentialConfig `yaml:"status_auth"`
+	ExpireAfter      *config.Duration   `yaml:"expire_after"`
+	MaxSkew          *config.Duration   `yaml:"max_skew"`
+	MaxDrift         *float64           `yaml:"max_drift"`
+	HostWindow       *config.Duration   `yaml:"host_window"`
+	PerHost          *bool              `yaml:"per_host"`
+	TrustedKeysFile  *string            `yaml:"trusted_keys_file"`
+	TrustedKeys      []trustRuleConfig  `yaml:"trusted_keys"`
+	RejectUnverified *bool              `yaml:"reject_unverified"`
+	AdminSubject     *string            `yaml:"admin_subject"`
+	ServiceSubject   *string            `yaml:"service_subject"`
+	EventSubject     *string            `yaml:"event_subject"`
+	EventStream      *string            `yaml:"event_stream"`
+	HistorySize      *int               `yaml:"history_size"`
+	HistoryStream    *string            `yaml:"history_stream"`
+	HistorySubject   *string            `yaml:"history_subject"`
+	UptimeWindows    *string            `yaml:"uptime_windows"`
+	ShardGroup       *string            `yaml:"shard_group"`
+	ShardMember      *string            `yaml:"shard_member"`
+	ShardHeartbeat   *config.Duration   `yaml:"shard_heartbeat"`
+	Pushover         *pushoverConfig    `yaml:"pushover"`
+}
+
+type trustRuleConfig struct {
+	Pattern string   `yaml:"pattern"`
+	Keys    []string `yaml:"keys"`
+}
+
+// credentialConfig is one status server credential: a token, a user and
+// password, or a client certificate common name.
+type credentialConfig struct {
+	Role     string `yaml:"role"`
+	Token    string `yaml:"token"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Cert     string `yaml:"cert"`
+}
+
+type pushoverConfig struct {
+	User  *string `yaml:"user"`
+	Token *string `yaml:"token"`
+}
+
+// flagEnv maps flags that can be overridden by the config file to their
+// environment mirrors.
+var flagEnv = map[string]string{
+	"subject-prefix":    "SUBJECT_PREFIX",
+	"prime-stream":      "PRIME_STREAM",
+	"poll":              "POLL_INTERVAL",
+	"repeat-every":      "REPEAT_EVERY",
+	"status-addr":       "STATUS_ADDR",
+	"status-tls-cert":   "STATUS_TLS_CERT",
+	"status-tls-key":    "STATUS_TLS_KEY",
+	"status-client-ca":  "STATUS_CLIENT_CA",
+	"status-auth":       "STATUS_AUTH",
+	"expire-after":      "EXPIRE_AFTER",
+	"max-skew":          "MAX_SKEW",
+	"max-drift":         "MAX_DRIFT",
+	"host-window":       "HOST_WINDOW",
+	"per-host":          "PER_HOST",
+	"trusted-keys":      "TRUSTED_KEYS",
+	"reject-unverified": "REJECT_UNVERIFIED",
+	"admin-subject":     "ADMIN_SUBJECT",
+	"service-subject":   "SERVICE_SUBJECT",
+	"event-subject":     "EVENT_SUBJECT",
+	"event-stream":      "EVENT_STREAM",
+	"history-size":      "HISTORY_SIZE",
+	"history-stream":    "HISTORY_STREAM",
+	"history-subject":   "HISTORY_SUBJECT",
+	"uptime-windows":    "UPTIME_WINDOWS",
+	"shard-group":       "SHARD_GROUP",
+	"shard-member":      "SHARD_MEMBER",
+	"shard-heartbeat":   "SHARD_HEARTBEAT",
+	"pushover-user":     "PUSHOVER_USER",
+	"pushover-token":    "PUSHOVER_TOKEN",
+}
+
+// explicitFlags returns the flags set on the command line or through their
+// environment mirror; these take precedence over the config file.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, env := range flagEnv {
+		if os.Getenv(env) != "" {
+			explicit[name] = true
+		}
+	}
+	return explicit
+}
+
+// loadSettings overlays the config file at path (if any) on base and
+// resolves trust rules and uptime windows.
+func loadSettings(base settings, path string, explicit map[string]bool) (settings, error) {
+	s := base
+	if path != "" {
+		var f fileConfig
+		if err := config.Load(path, &f); err != nil {
+			return settings{}, err
+		}
+		override(&s.Prefix, f.SubjectPrefix, "subject-prefix", explicit)
+		override(&s.PrimeStream, f.PrimeStream, "prime-stream", explicit)
+		overrideDuration(&s.PollEvery, f.Poll, "poll", explicit)
+		overrideDuration(&s.RepeatEvery, f.RepeatEvery, "repeat-every", explicit)
+		override(&s.StatusAddr, f.StatusAddr, "status-addr", explicit)
+		override(&s.StatusTLSCert, f.StatusTLSCert, "status-tls-cert", explicit)
+		override(&s.StatusTLSKey, f.StatusTLSKey, "status-tls-key", explicit)
+		override(&s.StatusClientCA, f.StatusClientCA, "status-client-ca", explicit)
+		override(&s.StatusAuthFile, f.StatusAuthFile, "status-auth", explicit)
+		overrideDuration(&s.ExpireAfter, f.ExpireAfter, "expire-after", explicit)
+		overrideDuration(&s.MaxSkew, f.MaxSkew, "max-skew", explicit)
+		override(&s.MaxDrift, f.MaxDrift, "max-drift", explicit)
+		overrideDuration(&s.HostWindow, f.HostWindow, "host-window", explicit)
+		override(&s.PerHost, f.PerHost, "per-host", explicit)
+		override(&s.TrustedKeysFile, f.TrustedKeysFile, "trusted-keys", explicit)
+		override(&s.RejectUnverified, f.RejectUnverified, "reject-unverified", explicit)
+		override(&s.AdminSubject, f.AdminSubject, "admin-subject", explicit)
+		override(&s.ServiceSubject, f.ServiceSubject, "service-subject", explicit)
+		override(&s.EventSubject, f.EventSubject, "event-subject", explicit)
+		override(&s.EventStream, f.EventStream, "event-stream", explicit)
+		override(&s.HistorySize, f.HistorySize, "history-size", explicit)
+		override(&s.HistoryStream, f.HistoryStream, "history-stream", explicit)
+		override(&s.HistorySubject, f.HistorySubject, "history-subject", explicit)
+		override(&s.UptimeWindows, f.UptimeWindows, "uptime-windows", explicit)
+		override(&s.ShardGroup, f.ShardGroup, "shard-group", explicit)
+		override(&s.ShardMember, f.ShardMember, "shard-member", explicit)
+		overrideDuration(&s.ShardHeartbeat, f.ShardHeartbeat, "shard-heartbeat", explicit)
+		if f.Pushover != nil {
+			override(&s.PushoverUser, f.Pushover.User, "pushover-user", explicit)
+			override(&s.PushoverToken, f.Pushover.Token, "pushover-token", explicit)
+		}
+		for _, rule := range f.TrustedKeys {
+			s.TrustRules = append(s.TrustRules, monitor.TrustRule{Pattern: rule.Pattern, Keys: rule.Keys})
+		}
+		for i, c := range f.StatusAuth {
+			cred, err := c.credential()
+			if err != nil {
+				return settings{}, fmt.Errorf("status_auth %d: %w", i+1, err)
+			}
+			s.StatusAuth = append(s.StatusAuth, cred)
+		}
+	}
+
+	if s.TrustedKeysFile != "" {
+		rules, err := monitor.LoadTrustRules(s.TrustedKeysFile)
+		if err != nil {
+			return settings{}, err
+		}
+		s.TrustRules = append(s.TrustRules, rules...)
+	}
+
+	if s.StatusAuthFile != "" {
+		creds, err := monitor.LoadCredentials(s.StatusAuthFile)
+		if err != nil {
+			return settings{}, fmt.Errorf("status auth: %w", err)
+		}
+		s.StatusAuth = append(s.StatusAuth, creds...)
+	}
+
+	if (s.StatusTLSCert == "") != (s.StatusTLSKey == "") {
+		return settings{}, fmt.Errorf("status TLS certificate and key must be set together")
+	}
+	if s.StatusClientCA != "" && s.StatusTLSCert == "" {
+		return settings{}, fmt.Errorf("status client CA requires a TLS certificate and key")
+	}
+
+	if s.EventStream != "" && s.EventSubject == "" {
+		return settings{}, fmt.Errorf("event stream requires an event subject")
+	}
+
+	if prefix := strings.TrimSuffix(s.Prefix, "."); s.ShardGroup != "" && (prefix == "" || strings.HasPrefix(s.ShardGroup+".", prefix+".")) {
+		return settings{}, fmt.Errorf("shard group %q must be outside the subject prefix", s.ShardGroup)
+	}
+	if s.ShardGroup != "" && s.PrimeStream == "" {
+		return settings{}, fmt.Errorf("shard group requires a prime stream")
+	}
+
+	s.Uptime = nil
+	for _, w := range strings.Split(s.UptimeWindows, ",") {
+		if w = strings.TrimSpace(w); w == "" {
+			continue
+		}
+		d, err := monitor.ParseWindow(w)
+		if err != nil {
+			return settings{}, fmt.Errorf("uptime windows: %w", err)
+		}
+		s.Uptime = append(s.Uptime, d)
+	}
+	return s, nil
+}
+
+func (c credentialConfig) credential() (monitor.Credential, error) {
+	role, err := monitor.ParseRole(c.Role)
+	if err != nil {
+		return monitor.Credential{}, err
+	}
+	cred := monitor.Credential{Role: role, Token: c.Token, User: c.User, Password: c.Password, CommonName: c.Cert}
+	set := 0
+	for _, v := range []string{c.Token, c.User, c.Cert} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return monitor.Credential{}, fmt.Errorf("set exactly one of token, user or cert")
+	}
+	if c.User != "" && c.Password == "" {
+		return monitor.Credential{}, fmt.Errorf("user %q needs a password", c.User)
+	}
+	return cred, nil
+}
+
+func override[T any](dst *T, v *T, flagName string, explicit map[string]bool) {
+	if v != nil && !explicit[flagName] {
+		*dst = *v
+	}
+}
+
+func overrideDuration(dst *time.Duration, v *config.Duration, flagName string, explicit map[string]bool) {
+	if v != nil && !explicit[flagName] {
+		*dst = time.Duration(*v)
+	}
+}
+
+func (s settings) monitorConfig() monitor.Config {
+	return monitor.Config{
+		Prefix:           s.Prefix,
+		PrimeStream:      s.PrimeStream,
+		PollEvery:        s.PollEvery,
+		RepeatEvery:      s.RepeatEvery,
+		StatusAddr:       s.StatusAddr,
+		StatusTLSCert:    s.StatusTLSCert,
+		StatusTLSKey:     s.StatusTLSKey,
+		StatusClientCA:   s.StatusClientCA,
+		StatusAuth:       s.StatusAuth,
+		ExpireAfter:      s.ExpireAfter,
+		MaxSkew:          s.MaxSkew,
+		MaxDrift:         s.MaxDrift,
+		HostWindow:       s.HostWindow,
+		PerHost:          s.PerHost,
+		TrustRules:       s.TrustRules,
+		RejectUnverified: s.RejectUnverified,
+		AdminSubject:     s.AdminSubject,
+		ServiceSubject:   s.ServiceSubject,
+		EventSubject:     s.EventSubject,
+		EventStream:      s.EventStream,
+		HistorySize:      s.HistorySize,
+		HistoryStream:    s.HistoryStream,
+		HistorySubject:   s.HistorySubject,
+		UptimeWindows:    s.Uptime,
+		ShardGroup:       s.ShardGroup,
+		ShardMember:      s.ShardMember,
+		ShardHeartbeat:   s.ShardHeartbeat,
+	}
+}
+
+func (s settings) notifier() notifier.Notifier {
+	return notifier.Pushover{
+		User:  s.PushoverUser,
+		Token: s.PushoverToken,
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "monitor.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestLoadSettingsPrecedence(t *testing.T) {
+	path := writeConfig(t, `subject_prefix: file.
+poll: 5s
+repeat_every: 1h
+per_host: true
+`)
+	cases := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		wantPrefix string
+		wantPoll   time.Duration
+		wantRepeat time.Duration
+	}{
+		{
+			name:       "file over default",
+			wantPrefix: "file.",
+			wantPoll:   5 * time.Second,
+			wantRepeat: time.Hour,
+		},
+		{
+			name:       "env over file",
+			env:        map[string]string{"SUBJECT_PREFIX": "env.", "POLL_INTERVAL": "7s"},
+			wantPrefix: "env.",
+			wantPoll:   7 * time.Second,
+			wantRepeat: time.Hour,
+		},
+		{
+			name:       "flag over env",
+			args:       []string{"-subject-prefix", "flag.", "-repeat-every", "2h"},
+			env:        map[string]string{"SUBJECT_PREFIX": "env."},
+			wantPrefix: "flag.",
+			wantPoll:   5 * time.Second,
+			wantRepeat: 2 * time.Hour,
+		},
+	}
+
+	for _, tc := range cases {
+		for key := range flagEnv {
+			t.Setenv(flagEnv[key], "")
+		}
+		for key, value := range tc.env {
+			t.Setenv(key, value)
+		}
+		// mirror how main registers these flags
+		fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+		var base settings
+		fs.StringVar(&base.Prefix, "subject-prefix", envDefault("SUBJECT_PREFIX", "heartbeat."), "")
+		fs.DurationVar(&base.PollEvery, "poll", envDuration("POLL_INTERVAL", time.Second), "")
+		fs.DurationVar(&base.RepeatEvery, "repeat-every", envDuration("REPEAT_EVERY", 12*time.Hour), "")
+		fs.BoolVar(&base.PerHost, "per-host", envBool("PER_HOST", false), "")
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("%s: parse: %v", tc.name, err)
+		}
+
+		got, err := loadSettings(base, path, explicitFlags(fs))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got.Prefix != tc.wantPrefix || got.PollEvery != tc.wantPoll || got.RepeatEvery != tc.wantRepeat {
+			t.Errorf("%s: expected prefix %q poll %s repeat %s, got %q %s %s", tc.name, tc.wantPrefix, tc.wantPoll, tc.wantRepeat, got.Prefix, got.PollEvery, got.RepeatEvery)
+		}
+		if !got.PerHost {
+			t.Errorf("%s: expected per_host from the file", tc.name)
+		}
+	}
+}
+
+func TestLoadSettingsWithoutFileKeepsBase(t *testing.T) {
+	base := settings{Prefix: "heartbeat.", PollEvery: time.Second}
+	got, err := loadSettings(base, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Prefix != base.Prefix || got.PollEvery != base.PollEvery || len(got.TrustRules) != 0 {
+		t.Fatalf("expected base settings, got %+v", got)
+	}
+}
+
+func TestLoadSettingsTrustRules(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "trusted")
+	if err := os.WriteFile(keysFile, []byte("heartbeat.db UDXE\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	path := writeConfig(t, "trusted_keys_file: "+keysFile+`
+trusted_keys:
+  - pattern: heartbeat.api
+    keys: [UBAJ]
+`)
+	got, err := loadSettings(settings{}, path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.TrustRules) != 2 || got.TrustRules[0].Pattern != "heartbeat.api" || got.TrustRules[1].Pattern != "heartbeat.db" {
+		t.Fatalf("expected inline rules before file rules, got %+v", got.TrustRules)
+	}
+}
+
+func TestLoadSettingsRejectsInvalidFiles(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown field", "subject_prefx: x\n", "subject_prefx"},
+		{"bad duration", "poll: soon\n", "invalid duration"},
+		{"missing trusted keys file", "trusted_keys_file: /nonexistent/trusted\n", "no such file"},
+	}
+	for _, tc := range cases {
+		_, err := loadSettings(settings{}, writeConfig(t, tc.content), nil)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+	if _, err := loadSettings(settings{}, "/nonexistent/monitor.yaml", nil); err == nil {
+		t.Errorf("expected a missing config file to fail")
+	}
+}
+
+func TestLoadSettingsShardGroup(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"outside the prefix", "subject_prefix: heartbeat\nshard_group: heartbeat-shard\nprime_stream: HEARTBEATS\n", ""},
+		{"under the prefix", "subject_prefix: heartbeat\nshard_group: heartbeat.shard\nprime_stream: HEARTBEATS\n", "outside the subject prefix"},
+		{"same as the prefix", "subject_prefix: heartbeat.\nshard_group: heartbeat\nprime_stream: HEARTBEATS\n", "outside the subject prefix"},
+		{"no prefix", "subject_prefix: \"\"\nshard_group: heartbeat-shard\nprime_stream: HEARTBEATS\n", "outside the subject prefix"},
+		{"without a prime stream", "subject_prefix: heartbeat\nshard_group: heartbeat-shard\n", "requires a prime stream"},
+	}
+	for _, tc := range cases {
+		got, err := loadSettings(settings{}, writeConfig(t, tc.content), nil)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			} else if got.ShardGroup != "heartbeat-shard" {
+				t.Errorf("%s: shard group %q, want heartbeat-shard", tc.name, got.ShardGroup)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestExplicitFlagsIncludesEnvMirrors(t *testing.T) {
+	for _, env := range flagEnv {
+		t.Setenv(env, "")
+	}
+	t.Setenv("MAX_SKEW", "30s")
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	fs.String("status-addr", "", "")
+	if err := fs.Parse([]string{"-status-addr", ":9090"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	explicit := explicitFlags(fs)
+	if !explicit["status-addr"] || !explicit["max-skew"] || explicit["poll"] {
+		t.Fatalf("expected status-addr and max-skew to be explicit, got %v", explicit)
+	}
+}
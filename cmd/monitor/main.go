@@ -7,26 +7,52 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
-	"github.com/nats-io/nats.go"
-
 	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/internal/natsconn"
 	"github.com/venkytv/nats-heartbeat/internal/notifier"
 )
 
 func main() {
+	var connOpts natsconn.Options
+	connOpts.RegisterFlags(flag.CommandLine)
+
+	var base settings
+	flag.StringVar(&base.Prefix, "subject-prefix", envDefault("SUBJECT_PREFIX", "heartbeat."), "Subject prefix to monitor")
+	flag.StringVar(&base.PrimeStream, "prime-stream", envDefault("PRIME_STREAM", ""), "Optional JetStream stream to prime cache from")
+	flag.DurationVar(&base.PollEvery, "poll", envDuration("POLL_INTERVAL", time.Second), "How often to check for missed beats")
+	flag.DurationVar(&base.RepeatEvery, "repeat-every", envDuration("REPEAT_EVERY", 12*time.Hour), "How often to repeat alerts while beats are missing")
+	flag.StringVar(&base.StatusAddr, "status-addr", envDefault("STATUS_ADDR", "127.0.0.1:8080"), "Listen address for HTTP status (empty to disable)")
+	flag.StringVar(&base.StatusTLSCert, "status-tls-cert", envDefault("STATUS_TLS_CERT", ""), "Certificate file to serve the status server over HTTPS")
+	flag.StringVar(&base.StatusTLSKey, "status-tls-key", envDefault("STATUS_TLS_KEY", ""), "Key file for -status-tls-cert")
+	flag.StringVar(&base.StatusClientCA, "status-client-ca", envDefault("STATUS_CLIENT_CA", ""), "Optional CA file; status server clients must present a certificate signed by it")
+	flag.StringVar(&base.StatusAuthFile, "status-auth", envDefault("STATUS_AUTH", ""), "Optional file of '<read|admin> token|basic|cert ...' credentials required by the status server and NATS requests")
+	flag.DurationVar(&base.ExpireAfter, "expire-after", envDuration("EXPIRE_AFTER", 0), "Forget subjects missing for longer than this (0 to disable)")
+	flag.DurationVar(&base.MaxSkew, "max-skew", envDuration("MAX_SKEW", 0), "Notify when a publisher's clock skew exceeds this (0 to disable)")
+	flag.Float64Var(&base.MaxDrift, "max-drift", envFloat("MAX_DRIFT", 0), "Notify when a subject's p95 inter-arrival time deviates from its interval by more than this fraction (0 to disable)")
+	flag.DurationVar(&base.HostWindow, "host-window", envDuration("HOST_WINDOW", 0), "How long a host counts as publishing a subject (0 for twice the allowed window)")
+	flag.BoolVar(&base.PerHost, "per-host", envBool("PER_HOST", false), "Evaluate liveness per subject and host")
+	flag.StringVar(&base.TrustedKeysFile, "trusted-keys", envDefault("TRUSTED_KEYS", ""), "Optional file of '<subject pattern> <nkey>...' rules requiring signed heartbeats")
+	flag.BoolVar(&base.RejectUnverified, "reject-unverified", envBool("REJECT_UNVERIFIED", false), "Drop heartbeats that fail signature verification instead of flagging them")
+	flag.StringVar(&base.AdminSubject, "admin-subject", envDefault("ADMIN_SUBJECT", ""), "Optional NATS subject prefix for admin requests (e.g. heartbeat-admin)")
+	flag.StringVar(&base.ServiceSubject, "service-subject", envDefault("SERVICE_SUBJECT", ""), "Optional NATS subject prefix for the micro service status endpoints (e.g. heartbeat-monitor)")
+	flag.StringVar(&base.EventSubject, "event-subject", envDefault("EVENT_SUBJECT", ""), "Optional NATS subject prefix to publish alert/resolve/expiry/notice events on (e.g. heartbeat.events)")
+	flag.StringVar(&base.EventStream, "event-stream", envDefault("EVENT_STREAM", ""), "Optional JetStream stream to publish events into (created if missing)")
+	flag.IntVar(&base.HistorySize, "history-size", envInt("HISTORY_SIZE", 100), "Beats and notifications kept in each subject's history")
+	flag.StringVar(&base.HistoryStream, "history-stream", envDefault("HISTORY_STREAM", ""), "Optional JetStream stream to persist history to (created if missing)")
+	flag.StringVar(&base.HistorySubject, "history-subject", envDefault("HISTORY_SUBJECT", "heartbeat-history"), "Subject prefix for persisted history entries")
+	flag.StringVar(&base.UptimeWindows, "uptime-windows", envDefault("UPTIME_WINDOWS", "24h,7d,30d"), "Comma-separated trailing windows for per-subject uptime")
+	flag.StringVar(&base.ShardGroup, "shard-group", envDefault("SHARD_GROUP", ""), "Optional NATS subject prefix shared by monitors that split the subjects between them (e.g. heartbeat-shard); requires -prime-stream")
+	flag.StringVar(&base.ShardMember, "shard-member", envDefault("SHARD_MEMBER", ""), "Unique, stable name of this monitor in its shard group (default hostname)")
+	flag.DurationVar(&base.ShardHeartbeat, "shard-heartbeat", envDuration("SHARD_HEARTBEAT", 2*time.Second), "How often shard group members announce themselves")
+	flag.StringVar(&base.PushoverUser, "pushover-user", os.Getenv("PUSHOVER_USER"), "Pushover user key")
+	flag.StringVar(&base.PushoverToken, "pushover-token", os.Getenv("PUSHOVER_TOKEN"), "Pushover app token")
 	var (
-		natsURL     = flag.String("nats-url", envDefault("NATS_URL", nats.DefaultURL), "NATS server URL")
-		prefix      = flag.String("subject-prefix", envDefault("SUBJECT_PREFIX", "heartbeat."), "Subject prefix to monitor")
-		primeStream = flag.String("prime-stream", envDefault("PRIME_STREAM", ""), "Optional JetStream stream to prime cache from")
-		pollEvery   = flag.Duration("poll", envDuration("POLL_INTERVAL", time.Second), "How often to check for missed beats")
-		repeatEvery = flag.Duration("repeat-every", envDuration("REPEAT_EVERY", 12*time.Hour), "How often to repeat alerts while beats are missing")
-		statusAddr  = flag.String("status-addr", envDefault("STATUS_ADDR", "127.0.0.1:8080"), "Listen address for HTTP status (empty to disable)")
-		poUser      = flag.String("pushover-user", os.Getenv("PUSHOVER_USER"), "Pushover user key")
-		poToken     = flag.String("pushover-token", os.Getenv("PUSHOVER_TOKEN"), "Pushover app token")
-		debug       = flag.Bool("debug", envBool("DEBUG", false), "Enable debug logging")
+		configPath = flag.String("config", envDefault("CONFIG", ""), "Optional YAML/JSON config file (reloaded on SIGHUP)")
+		debug      = flag.Bool("debug", envBool("DEBUG", false), "Enable debug logging")
 	)
 	flag.Parse()
 
@@ -40,30 +66,53 @@ func main() {
 	}
 	slog.SetDefault(logger)
 
-	nc, err := nats.Connect(*natsURL)
-	if err != nil {
-		log.Fatalf("connect to nats: %v", err)
+	explicit := explicitFlags(flag.CommandLine)
+	load := func() (monitor.Config, notifier.Notifier, error) {
+		s, err := loadSettings(base, *configPath, explicit)
+		if err != nil {
+			return monitor.Config{}, nil, err
+		}
+		cfg := s.monitorConfig()
+		cfg.Debug = *debug
+		cfg.Logger = logger
+		return cfg, s.notifier(), nil
 	}
-	defer nc.Drain()
 
-	notify := notifier.Pushover{
-		User:  *poUser,
-		Token: *poToken,
+	cfg, notify, err := load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
 	}
 
-	cfg := monitor.Config{
-		Prefix:      *prefix,
-		PrimeStream: *primeStream,
-		PollEvery:   *pollEvery,
-		RepeatEvery: *repeatEvery,
-		StatusAddr:  *statusAddr,
-		Debug:       *debug,
-		Logger:      logger,
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	connOpts.Name = "heartbeat-monitor"
+	nc, err := natsconn.Connect(ctx, logger, connOpts, natsconn.Hooks{})
+	if err != nil {
+		log.Fatalf("connect to nats: %v", err)
 	}
+	defer nc.Drain()
+
 	m := monitor.New(nc, notify, cfg)
+	if *configPath != "" {
+		m.SetReloadFunc(load)
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if _, err := m.TriggerReload(); err != nil {
+					logger.Warn("SIGHUP reload failed", "err", err)
+				}
+			}
+		}
+	}()
 
 	if err := m.Start(ctx); err != nil {
 		log.Fatalf("monitor failed: %v", err)
@@ -86,6 +135,24 @@ func envDuration(key string, fallback time.Duration) time.Duration {
 	return fallback
 }
 
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func envFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
 func envBool(key string, fallback bool) bool {
 	if v := os.Getenv(key); v != "" {
 		return v == "1" || v == "true" || v == "TRUE" || v == "yes" || v == "on"
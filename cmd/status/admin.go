@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// runSilence silences a subject for the duration in args[1]; "0" lifts
+// the silence.
+func runSilence(src source, timeout time.Duration, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("expected <subject> <duration>")
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	until, err := src.silence(ctx, args[0], d)
+	if err != nil {
+		return err
+	}
+	if until.IsZero() {
+		fmt.Fprintf(w, "Silence lifted for %s\n", args[0])
+		return nil
+	}
+	fmt.Fprintf(w, "Silenced %s until %s\n", args[0], until.Format(time.RFC3339))
+	return nil
+}
+
+// runAck acknowledges the active alert on the subject in args[0].
+func runAck(src source, timeout time.Duration, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("expected <subject>")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := src.ack(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Acknowledged %s\n", args[0])
+	return nil
+}
+
+func (s httpSource) silence(ctx context.Context, subject string, d time.Duration) (time.Time, error) {
+	return s.client.Silence(ctx, subject, d)
+}
+
+func (s httpSource) ack(ctx context.Context, subject string) error {
+	return s.client.Ack(ctx, subject)
+}
+
+func (s natsSource) silence(ctx context.Context, subject string, d time.Duration) (time.Time, error) {
+	res, err := s.adminAll(ctx, "silence", []byte(subject+" "+d.String()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return silencedUntil(res), nil
+}
+
+func (s natsSource) ack(ctx context.Context, subject string) error {
+	_, err := s.adminAll(ctx, "ack", []byte(subject))
+	return err
+}
+
+// adminAll sends an admin request to every monitor. It succeeds if any
+// monitor applied it, since each only knows the subjects it has seen;
+// otherwise it reports their distinct errors.
+func (s natsSource) adminAll(ctx context.Context, endpoint string, data []byte) (statusapi.AdminResult, error) {
+	replies, err := s.requestAll(ctx, s.subject+"."+endpoint, data)
+	if err != nil {
+		return statusapi.AdminResult{}, err
+	}
+	var errs []string
+	for _, r := range replies {
+		if r.err != "" {
+			if !contains(errs, r.err) {
+				errs = append(errs, r.err)
+			}
+			continue
+		}
+		var resp statusapi.AdminResult
+		if err := json.Unmarshal(r.data, &resp); err != nil {
+			return statusapi.AdminResult{}, fmt.Errorf("decode admin reply: %w", err)
+		}
+		if resp.OK {
+			return resp, nil
+		}
+	}
+	return statusapi.AdminResult{}, errors.New(strings.Join(errs, "; "))
+}
+
+func silencedUntil(res statusapi.AdminResult) time.Time {
+	if res.SilencedUntil == nil {
+		return time.Time{}
+	}
+	return *res.SilencedUntil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testHTTPSource(t *testing.T, url string) httpSource {
+	t.Helper()
+	src, err := newHTTPSource(url)
+	if err != nil {
+		t.Fatalf("new source: %v", err)
+	}
+	return src
+}
+
+func TestRunSilenceAndAck(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/subjects/heartbeat.api/silence":
+			if r.URL.Query().Get("for") == "0s" {
+				_, _ = w.Write([]byte(`{"subject":"heartbeat.api","ok":true}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"subject":"heartbeat.api","ok":true,"silenced_until":"2024-06-01T13:00:00Z"}`))
+		case "/subjects/heartbeat.api/ack":
+			_, _ = w.Write([]byte(`{"subject":"heartbeat.api","ok":true}`))
+		case "/subjects/heartbeat.db/ack":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"subject":"heartbeat.db","ok":false,"error":"subject is not alerting"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	src := testHTTPSource(t, srv.URL+"/")
+
+	cases := []struct {
+		name    string
+		run     func([]string) error
+		args    []string
+		want    string
+		request string
+		wantErr string
+	}{
+		{name: "silence", args: []string{"heartbeat.api", "1h"}, want: "Silenced heartbeat.api until 2024-06-01T13:00:00Z\n", request: "POST /subjects/heartbeat.api/silence?for=1h0m0s"},
+		{name: "lift", args: []string{"heartbeat.api", "0"}, want: "Silence lifted for heartbeat.api\n", request: "POST /subjects/heartbeat.api/silence?for=0s"},
+		{name: "bad duration", args: []string{"heartbeat.api", "soon"}, wantErr: "invalid duration"},
+		{name: "missing duration", args: []string{"heartbeat.api"}, wantErr: "expected <subject> <duration>"},
+		{name: "ack", args: []string{"heartbeat.api"}, want: "Acknowledged heartbeat.api\n", request: "POST /subjects/heartbeat.api/ack"},
+		{name: "ack healthy", args: []string{"heartbeat.db"}, wantErr: "subject is not alerting"},
+		{name: "ack missing subject", wantErr: "expected <subject>"},
+	}
+	for _, tc := range cases {
+		got = nil
+		var out bytes.Buffer
+		var err error
+		if strings.HasPrefix(tc.name, "ack") {
+			err = runAck(src, time.Second, tc.args, &out)
+		} else {
+			err = runSilence(src, time.Second, tc.args, &out)
+		}
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if out.String() != tc.want {
+			t.Errorf("%s: expected output %q, got %q", tc.name, tc.want, out.String())
+		}
+		if len(got) != 1 || got[0] != tc.request {
+			t.Errorf("%s: expected request %q, got %v", tc.name, tc.request, got)
+		}
+	}
+}
+
+func TestHTTPSourceAdminRejectsNonJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}))
+	defer srv.Close()
+
+	_, err := testHTTPSource(t, srv.URL).silence(context.Background(), "heartbeat.api", time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "405") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
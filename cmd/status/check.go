@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nagios/Icinga plugin exit codes.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// checkNames caps how many subject names the summary line lists per state.
+const checkNames = 5
+
+var checkStates = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// evaluateCheck turns the subjects in resp into a plugin result: CRITICAL
+// when any alert is firing, WARNING when any subject is late or a monitor
+// is unreachable, OK otherwise. The line carries perfdata with each
+// subject's time since last seen, warning and critical at its allowed
+// window.
+func evaluateCheck(resp statusResponse) (int, string) {
+	if len(resp.Subjects) == 0 {
+		return checkUnknown, checkLine(checkUnknown, "no matching heartbeats", "")
+	}
+	if resp.ObservedAt.IsZero() {
+		resp.ObservedAt = time.Now()
+	}
+
+	var alerting, late []string
+	perfdata := make([]string, 0, len(resp.Subjects))
+	for _, s := range resp.Subjects {
+		switch {
+		case s.AlertActive:
+			alerting = append(alerting, checkName(s))
+		case s.Missing:
+			late = append(late, checkName(s))
+		}
+		perfdata = append(perfdata, subjectPerfdata(resp.ObservedAt, s))
+	}
+
+	code := checkOK
+	var parts []string
+	if len(alerting) > 0 {
+		code = checkCritical
+		parts = append(parts, fmt.Sprintf("%d alerting (%s)", len(alerting), listNames(alerting)))
+	}
+	if len(late) > 0 {
+		if code == checkOK {
+			code = checkWarning
+		}
+		parts = append(parts, fmt.Sprintf("%d late (%s)", len(late), listNames(late)))
+	}
+	if len(resp.Unreachable) > 0 {
+		if code == checkOK {
+			code = checkWarning
+		}
+		names := make([]string, 0, len(resp.Unreachable))
+		for _, u := range resp.Unreachable {
+			names = append(names, u.Monitor)
+		}
+		parts = append(parts, fmt.Sprintf("%d monitor(s) unreachable (%s)", len(names), listNames(names)))
+	}
+	ok := len(resp.Subjects) - len(alerting) - len(late)
+	if code == checkOK {
+		parts = append(parts, fmt.Sprintf("%d heartbeat(s) ok", ok))
+	} else {
+		parts = append(parts, fmt.Sprintf("%d ok", ok))
+	}
+	return code, checkLine(code, strings.Join(parts, ", "), strings.Join(perfdata, " "))
+}
+
+func checkLine(code int, summary, perfdata string) string {
+	line := fmt.Sprintf("HEARTBEAT %s - %s", checkStates[code], summary)
+	if perfdata != "" {
+		line += " | " + perfdata
+	}
+	return line
+}
+
+// subjectPerfdata renders 'subject'=<seconds>s;<warn>;<crit>;0 for the time
+// since the subject was last seen.
+func subjectPerfdata(now time.Time, s subjectState) string {
+	label := "'" + strings.ReplaceAll(checkName(s), "'", "''") + "'"
+	value := "U"
+	if !s.LastSeen.IsZero() {
+		value = seconds(now.Sub(s.LastSeen)) + "s"
+	}
+	threshold := ""
+	if allowed, err := time.ParseDuration(s.AllowedWindow); err == nil {
+		threshold = seconds(allowed)
+	}
+	return fmt.Sprintf("%s=%s;%s;%s;0", label, value, threshold, threshold)
+}
+
+// checkName labels a subject, prefixed with its monitor when several are
+// queried.
+func checkName(s subjectState) string {
+	if s.Monitor != "" {
+		return s.Monitor + "/" + s.Subject
+	}
+	return s.Subject
+}
+
+func seconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.FormatFloat(d.Round(time.Millisecond).Seconds(), 'f', -1, 64)
+}
+
+func listNames(names []string) string {
+	if len(names) <= checkNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:checkNames], ", "), len(names)-checkNames)
+}
+
+// printCheckUnknown reports a failure to evaluate the check and returns the
+// UNKNOWN exit code.
+func printCheckUnknown(w io.Writer, err error) int {
+	fmt.Fprintln(w, checkLine(checkUnknown, err.Error(), ""))
+	return checkUnknown
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+var checkNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func checkSubject(name string, alerting, missing bool) subjectState {
+	return subjectState{SubjectStatus: statusapi.SubjectStatus{
+		Subject:       name,
+		LastSeen:      checkNow.Add(-5 * time.Second),
+		AllowedWindow: "30s",
+		AlertActive:   alerting,
+		Missing:       missing,
+	}}
+}
+
+func TestEvaluateCheck(t *testing.T) {
+	ok := checkSubject("ok", false, false)
+	late := checkSubject("late", false, true)
+	alerting := checkSubject("down", true, true)
+	unreachable := []unreachableMonitor{{Monitor: "west", Error: "timeout"}}
+	var manyLate []subjectState
+	for i := 1; i <= 7; i++ {
+		manyLate = append(manyLate, checkSubject(fmt.Sprintf("late%d", i), false, true))
+	}
+	cases := []struct {
+		name    string
+		resp    statusResponse
+		code    int
+		summary string
+	}{
+		{"no subjects", statusResponse{}, checkUnknown, "HEARTBEAT UNKNOWN - no matching heartbeats"},
+		{"no subjects with a monitor down", statusResponse{Unreachable: unreachable}, checkUnknown, "HEARTBEAT UNKNOWN - no matching heartbeats"},
+		{"all ok", statusResponse{Subjects: []subjectState{ok}}, checkOK, "HEARTBEAT OK - 1 heartbeat(s) ok"},
+		{"late", statusResponse{Subjects: []subjectState{ok, late}}, checkWarning, "HEARTBEAT WARNING - 1 late (late), 1 ok"},
+		{"alerting", statusResponse{Subjects: []subjectState{ok, alerting}}, checkCritical, "HEARTBEAT CRITICAL - 1 alerting (down), 1 ok"},
+		{"alerting beats late", statusResponse{Subjects: []subjectState{late, alerting, ok}}, checkCritical, "HEARTBEAT CRITICAL - 1 alerting (down), 1 late (late), 1 ok"},
+		{"monitor unreachable", statusResponse{Subjects: []subjectState{ok}, Unreachable: unreachable}, checkWarning, "HEARTBEAT WARNING - 1 monitor(s) unreachable (west), 1 ok"},
+		{"alerting beats unreachable", statusResponse{Subjects: []subjectState{alerting}, Unreachable: unreachable}, checkCritical, "HEARTBEAT CRITICAL - 1 alerting (down), 1 monitor(s) unreachable (west), 0 ok"},
+		{"many late", statusResponse{Subjects: manyLate}, checkWarning, "HEARTBEAT WARNING - 7 late (late1, late2, late3, late4, late5 and 2 more), 0 ok"},
+		{"monitor labels", statusResponse{Subjects: []subjectState{{Monitor: "east", SubjectStatus: late.SubjectStatus}}}, checkWarning, "HEARTBEAT WARNING - 1 late (east/late), 0 ok"},
+	}
+	for _, tc := range cases {
+		tc.resp.ObservedAt = checkNow
+		code, line := evaluateCheck(tc.resp)
+		if code != tc.code {
+			t.Errorf("%s: code = %d, want %d (%s)", tc.name, code, tc.code, line)
+		}
+		summary, _, _ := strings.Cut(line, " | ")
+		if summary != tc.summary {
+			t.Errorf("%s: summary = %q, want %q", tc.name, summary, tc.summary)
+		}
+	}
+}
+
+func TestEvaluateCheckPerfdata(t *testing.T) {
+	resp := statusResponse{ObservedAt: checkNow, Subjects: []subjectState{checkSubject("a", false, false), checkSubject("b", true, true)}}
+	_, line := evaluateCheck(resp)
+	_, perfdata, ok := strings.Cut(line, " | ")
+	if !ok {
+		t.Fatalf("expected perfdata in %q", line)
+	}
+	if want := "'a'=5s;30;30;0 'b'=5s;30;30;0"; perfdata != want {
+		t.Fatalf("perfdata = %q, want %q", perfdata, want)
+	}
+}
+
+func TestSubjectPerfdata(t *testing.T) {
+	cases := []struct {
+		name    string
+		subject subjectState
+		want    string
+	}{
+		{"seconds", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "api", LastSeen: checkNow.Add(-90 * time.Second), AllowedWindow: "1m0s"}}, "'api'=90s;60;60;0"},
+		{"fractional", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "api", LastSeen: checkNow.Add(-1500 * time.Microsecond), AllowedWindow: "2.5s"}}, "'api'=0.002s;2.5;2.5;0"},
+		{"never seen", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "api", AllowedWindow: "10s"}}, "'api'=U;10;10;0"},
+		{"unparsable window", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "api", LastSeen: checkNow.Add(-time.Second)}}, "'api'=1s;;;0"},
+		{"clock skew", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "api", LastSeen: checkNow.Add(time.Second), AllowedWindow: "10s"}}, "'api'=0s;10;10;0"},
+		{"quotes", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "bob's job", LastSeen: checkNow, AllowedWindow: "10s"}}, "'bob''s job'=0s;10;10;0"},
+		{"spaces and dots", subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "db backup.daily", LastSeen: checkNow, AllowedWindow: "10s"}}, "'db backup.daily'=0s;10;10;0"},
+		{"monitor", subjectState{Monitor: "east", SubjectStatus: statusapi.SubjectStatus{Subject: "api", LastSeen: checkNow, AllowedWindow: "10s"}}, "'east/api'=0s;10;10;0"},
+	}
+	for _, tc := range cases {
+		if got := subjectPerfdata(checkNow, tc.subject); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestListNames(t *testing.T) {
+	names := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("s%d", i+1)
+		}
+		return out
+	}
+	cases := []struct {
+		names []string
+		want  string
+	}{
+		{nil, ""},
+		{names(1), "s1"},
+		{names(checkNames), "s1, s2, s3, s4, s5"},
+		{names(checkNames + 1), "s1, s2, s3, s4, s5 and 1 more"},
+		{names(12), "s1, s2, s3, s4, s5 and 7 more"},
+	}
+	for _, tc := range cases {
+		if got := listNames(tc.names); got != tc.want {
+			t.Errorf("listNames(%d names) = %q, want %q", len(tc.names), got, tc.want)
+		}
+	}
+}
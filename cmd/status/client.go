@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// httpOptions are the TLS and authentication settings for the monitors'
+// HTTP status servers; the token or user and password also authenticate
+// NATS requests. Monitors listed in a -monitors file may carry their own
+// token or user and password.
+type httpOptions struct {
+	Token    string
+	User     string
+	Password string
+
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+}
+
+func (o *httpOptions) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Token, "token", envDefault("STATUS_TOKEN", ""), "Bearer token for the monitors' status servers and NATS service")
+	fs.StringVar(&o.User, "user", envDefault("STATUS_USER", ""), "Basic auth user for the monitors' status servers and NATS service")
+	fs.StringVar(&o.Password, "password", envDefault("STATUS_PASSWORD", ""), "Basic auth password for -user")
+	fs.StringVar(&o.TLSCA, "tls-ca", envDefault("STATUS_TLS_CA", ""), "CA certificate file used to verify HTTPS monitors")
+	fs.StringVar(&o.TLSCert, "tls-cert", envDefault("STATUS_TLS_CLIENT_CERT", ""), "Client certificate file for monitors requiring mutual TLS")
+	fs.StringVar(&o.TLSKey, "tls-key", envDefault("STATUS_TLS_CLIENT_KEY", ""), "Client key file for -tls-cert")
+}
+
+func (o httpOptions) validate() error {
+	if o.Token != "" && o.User != "" {
+		return errors.New("only one of -token or -user may be set")
+	}
+	if o.Password != "" && o.User == "" {
+		return errors.New("-password requires -user")
+	}
+	if (o.TLSCert == "") != (o.TLSKey == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	return nil
+}
+
+// httpClient returns the client shared by every monitor. timeout bounds
+// dialing and waiting for response headers, so it also applies to the
+// long-lived event stream.
+func (o httpOptions) httpClient(timeout time.Duration) (*http.Client, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: timeout}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+	}
+	if o.TLSCA != "" || o.TLSCert != "" {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if o.TLSCA != "" {
+			pem, err := os.ReadFile(o.TLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("read -tls-ca: %w", err)
+			}
+			tlsCfg.RootCAs = x509.NewCertPool()
+			if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", o.TLSCA)
+			}
+		}
+		if o.TLSCert != "" {
+			cert, err := tls.LoadX509KeyPair(o.TLSCert, o.TLSKey)
+			if err != nil {
+				return nil, fmt.Errorf("load client certificate: %w", err)
+			}
+			tlsCfg.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// authorization returns the Authorization header for the -token or -user
+// flags, sent with NATS requests; it is empty without credentials.
+func (o httpOptions) authorization() string {
+	switch {
+	case o.Token != "":
+		return "Bearer " + o.Token
+	case o.User != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(o.User+":"+o.Password))
+	}
+	return ""
+}
+
+// clientOptions returns the options for querying m, whose own credentials
+// take precedence over the flags.
+func (o httpOptions) clientOptions(m namedMonitor, hc *http.Client) []statusapi.ClientOption {
+	opts := []statusapi.ClientOption{statusapi.WithHTTPClient(hc)}
+	token, user, password := o.Token, o.User, o.Password
+	if m.Token != "" || m.User != "" {
+		token, user, password = m.Token, m.User, m.Password
+	}
+	switch {
+	case token != "":
+		opts = append(opts, statusapi.WithBearerToken(token))
+	case user != "":
+		opts = append(opts, statusapi.WithBasicAuth(user, password))
+	}
+	return opts
+}
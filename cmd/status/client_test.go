@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestHTTPOptionsAuthorization(t *testing.T) {
+	cases := []struct {
+		name string
+		opts httpOptions
+		want string
+	}{
+		{"none", httpOptions{}, ""},
+		{"token", httpOptions{Token: "s3cret"}, "Bearer s3cret"},
+		{"basic", httpOptions{User: "ops", Password: "pw"}, "Basic b3BzOnB3"},
+	}
+	for _, tc := range cases {
+		if got := tc.opts.authorization(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
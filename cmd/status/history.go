@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func printHistory(resp statusapi.History, w io.Writer) {
+	fmt.Fprintf(w, "History for %s\n", resp.Subject)
+	if len(resp.Entries) == 0 {
+		fmt.Fprintln(w, "No history recorded yet.")
+		return
+	}
+	fmt.Fprintln(w)
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tEVENT\tHOST\tDETAILS")
+	var lastBeat time.Time
+	for _, e := range resp.Entries {
+		var details []string
+		switch e.Kind {
+		case "beat":
+			if !lastBeat.IsZero() {
+				details = append(details, fmt.Sprintf("gap %s", e.At.Sub(lastBeat).Round(time.Millisecond)))
+			}
+			if e.Seq > 0 {
+				details = append(details, fmt.Sprintf("seq %d", e.Seq))
+			}
+			if !e.GeneratedAt.IsZero() {
+				details = append(details, fmt.Sprintf("skew %s", e.At.Sub(e.GeneratedAt).Round(time.Millisecond)))
+			}
+			lastBeat = e.At
+		case "alert", "resolved", "expired":
+			if e.MissFor != "" {
+				details = append(details, fmt.Sprintf("missed %s", e.MissFor))
+			}
+			if e.MissCount > 0 {
+				details = append(details, fmt.Sprintf("%d beats", e.MissCount))
+			}
+		case "notice":
+			details = append(details, e.Reason)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.At.Format(time.RFC3339), strings.ToUpper(e.Kind), fallback(e.Host, "-"), fallback(strings.Join(details, ", "), "-"))
+	}
+	_ = tw.Flush()
+
+	out := buf.String()
+	if shouldColor(w) {
+		out = colorizeHistory(out)
+	}
+	fmt.Fprint(w, out)
+}
+
+func colorizeHistory(out string) string {
+	colors := map[string]int{"ALERT": 31, "EXPIRED": 31, "NOTICE": 33, "RESOLVED": 32}
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if code, ok := colors[fields[1]]; ok {
+			lines[i] = strings.Replace(line, fields[1], applyColor(fields[1], true, code), 1)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestHTTPSourceHistoryLimit(t *testing.T) {
+	var gotPath, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.EscapedPath(), r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"subject":"heartbeat.api","entries":[{"kind":"beat"}]}`))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		limit     int
+		wantQuery string
+	}{
+		{0, ""},
+		{-1, ""},
+		{20, "limit=20"},
+	}
+	src := testHTTPSource(t, srv.URL+"/")
+	for _, tc := range cases {
+		resp, err := src.history(context.Background(), "heartbeat.api", tc.limit)
+		if err != nil {
+			t.Errorf("limit %d: unexpected error: %v", tc.limit, err)
+			continue
+		}
+		if gotPath != "/subjects/heartbeat.api/history" || gotQuery != tc.wantQuery {
+			t.Errorf("limit %d: expected query %q, requested %s?%s", tc.limit, tc.wantQuery, gotPath, gotQuery)
+		}
+		if resp.Subject != "heartbeat.api" || len(resp.Entries) != 1 {
+			t.Errorf("limit %d: unexpected response %+v", tc.limit, resp)
+		}
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	printHistory(statusapi.History{
+		Subject: "heartbeat.api",
+		Entries: []statusapi.HistoryEntry{
+			{Kind: "beat", At: at, GeneratedAt: at.Add(-2 * time.Second), Host: "web-1", Seq: 41},
+			{Kind: "beat", At: at.Add(10 * time.Second), Host: "web-1", Seq: 42},
+			{Kind: "alert", At: at.Add(time.Minute), MissFor: "50s", MissCount: 5},
+			{Kind: "notice", At: at.Add(2 * time.Minute), Reason: "clock skew 2s exceeds 1s"},
+		},
+	}, &buf)
+
+	cases := []string{
+		"History for heartbeat.api",
+		"BEAT    web-1  seq 41, skew 2s",
+		"BEAT    web-1  gap 10s, seq 42",
+		"ALERT   -      missed 50s, 5 beats",
+		"NOTICE  -      clock skew 2s exceeds 1s",
+	}
+	for _, want := range cases {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	printHistory(statusapi.History{Subject: "heartbeat.api"}, &buf)
+	if !strings.Contains(buf.String(), "No history recorded yet.") {
+		t.Errorf("expected empty history message, got %q", buf.String())
+	}
+}
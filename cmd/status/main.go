@@ -3,80 +3,304 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/natsconn"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
 )
 
+// statusResponse is a status document as shown by the command: the
+// monitor's, or several monitors' merged with the ones that could not be
+// reached.
 type statusResponse struct {
-	ObservedAt time.Time      `json:"observed_at"`
-	Subjects   []subjectState `json:"subjects"`
+	Version     int                  `json:"version"`
+	ObservedAt  time.Time            `json:"observed_at"`
+	Subjects    []subjectState       `json:"subjects"`
+	Unreachable []unreachableMonitor `json:"unreachable,omitempty"`
+}
+
+// unreachableMonitor is a monitor that did not answer a multi-monitor query.
+type unreachableMonitor struct {
+	Monitor string `json:"monitor"`
+	Error   string `json:"error"`
 }
 
+// subjectState is a subject as reported by one monitor, labelled with that
+// monitor when several are queried.
 type subjectState struct {
-	Subject       string    `json:"subject"`
-	Description   string    `json:"description"`
-	Host          string    `json:"host,omitempty"`
-	LastSeen      time.Time `json:"last_seen"`
-	Interval      string    `json:"interval"`
-	Grace         *string   `json:"grace,omitempty"`
-	AllowedWindow string    `json:"allowed_window"`
-	Missing       bool      `json:"missing"`
-	MissFor       string    `json:"miss_for,omitempty"`
-	MissCount     int       `json:"miss_count,omitempty"`
-	AlertActive   bool      `json:"alert_active"`
+	Monitor      string `json:"monitor,omitempty"`
+	Disagreement bool   `json:"disagreement,omitempty"`
+	statusapi.SubjectStatus
+}
+
+func fromStatus(status statusapi.Status) statusResponse {
+	resp := statusResponse{Version: status.Version, ObservedAt: status.ObservedAt}
+	for _, s := range status.Subjects {
+		resp.Subjects = append(resp.Subjects, subjectState{SubjectStatus: s})
+	}
+	return resp
+}
+
+// source fetches monitor data, either from one monitor's HTTP status
+// endpoint or from every monitor serving the NATS micro service.
+type source interface {
+	status(ctx context.Context) (statusResponse, error)
+	history(ctx context.Context, subject string, limit int) (statusapi.History, error)
+	report(ctx context.Context, q statusapi.ReportQuery) (statusapi.Report, error)
+	silence(ctx context.Context, subject string, d time.Duration) (time.Time, error)
+	ack(ctx context.Context, subject string) error
+	forget(ctx context.Context, subject string) error
 }
 
 func main() {
-	statusURL := flag.String("url", envDefault("STATUS_URL", "http://127.0.0.1:8080/"), "Status endpoint URL")
-	timeout := flag.Duration("timeout", envDuration("STATUS_TIMEOUT", 3*time.Second), "HTTP request timeout")
+	var urls urlList
+	flag.Var(&urls, "url", "Status endpoint URL, optionally as name=url; repeat to merge several monitors (default $STATUS_URL or http://127.0.0.1:8080/)")
+	monitorsPath := flag.String("monitors", envDefault("STATUS_MONITORS", ""), "YAML file listing named monitors to query together")
+	timeout := flag.Duration("timeout", envDuration("STATUS_TIMEOUT", 3*time.Second), "Request timeout")
+	watchMode := flag.Bool("watch", envBool("STATUS_WATCH", false), "Stream live updates from the monitor and redraw the table in place")
+	limit := flag.Int("limit", envInt("STATUS_LIMIT", 0), "With history, show only the newest N entries (0 for all)")
+	checkMode := flag.Bool("check", envBool("STATUS_CHECK", false), "Run as a Nagios/Icinga plugin: print one line with perfdata and exit 0 OK, 1 WARNING, 2 CRITICAL or 3 UNKNOWN")
+	var connOpts natsconn.Options
+	connOpts.RegisterFlags(flag.CommandLine)
+	var httpOpts httpOptions
+	httpOpts.registerFlags(flag.CommandLine)
+	var v view
+	flag.StringVar(&v.format, "o", envDefault("STATUS_OUTPUT", "table"), "Output format: table, wide, json, yaml or csv")
+	flag.BoolVar(&v.alerting, "alerting", envBool("STATUS_ALERTING", false), "Only show subjects with a firing alert")
+	flag.StringVar(&v.subject, "subject", envDefault("STATUS_SUBJECT", ""), "Only show subjects matching this glob (e.g. 'heartbeat.db.*')")
+	flag.StringVar(&v.host, "host", envDefault("STATUS_HOST", ""), "Only show subjects published from a host matching this glob")
+	flag.StringVar(&v.sortBy, "sort", envDefault("STATUS_SORT", "subject"), "Sort by subject, status, last-seen or host")
+	flag.BoolVar(&v.reverse, "reverse", envBool("STATUS_REVERSE", false), "Reverse the sort order")
+	serviceSubject := flag.String("service-subject", envDefault("STATUS_SERVICE_SUBJECT", "heartbeat-monitor"), "Subject prefix of the monitors' NATS service endpoints (used with -nats-url)")
+	adminSubject := flag.String("admin-subject", envDefault("STATUS_ADMIN_SUBJECT", ""), "Subject prefix of the monitors' NATS admin requests, for forgetting subjects from the TUI over -nats-url")
+	flag.Usage = usage
 	flag.Parse()
 
+	// plugins must report every failure as UNKNOWN
+	fail := func(format string, args ...interface{}) {
+		if *checkMode {
+			os.Exit(printCheckUnknown(os.Stdout, fmt.Errorf(format, args...)))
+		}
+		log.Fatalf(format, args...)
+	}
+
+	if err := v.validate(); err != nil {
+		fail("%v", err)
+	}
+	if *checkMode && (*watchMode || flag.NArg() > 0 || v.format != "table") {
+		fail("-check cannot be combined with -watch, -o or a command")
+	}
+	useNATS, err := natsRequested(flag.CommandLine)
+	if err != nil {
+		fail("%v", err)
+	}
+	var src source
+	var monitors []namedMonitor
+	if useNATS {
+		if *watchMode {
+			log.Fatal("-watch needs the HTTP event stream; use -url")
+		}
+		if err := httpOpts.validate(); err != nil {
+			fail("%v", err)
+		}
+		nc, err := connectNATS(connOpts, *timeout)
+		if err != nil {
+			fail("connect to nats: %v", err)
+		}
+		defer nc.Close()
+		src = natsSource{
+			nc:      nc,
+			subject: strings.TrimSuffix(*serviceSubject, "."),
+			admin:   strings.TrimSuffix(*adminSubject, "."),
+			auth:    httpOpts.authorization(),
+		}
+	} else {
+		if len(urls) == 0 && *monitorsPath == "" {
+			for _, u := range strings.Split(envDefault("STATUS_URL", "http://127.0.0.1:8080/"), ",") {
+				urls = append(urls, strings.TrimSpace(u))
+			}
+		}
+		monitors, err = parseMonitors(urls, *monitorsPath)
+		if err != nil {
+			fail("%v", err)
+		}
+		hc, err := httpOpts.httpClient(*timeout)
+		if err != nil {
+			fail("%v", err)
+		}
+		switch len(monitors) {
+		case 0:
+			fail("no monitors listed in %s", *monitorsPath)
+		case 1:
+			src, err = newHTTPSource(monitors[0].URL, httpOpts.clientOptions(monitors[0], hc)...)
+		default:
+			if *watchMode {
+				log.Fatal("-watch follows a single monitor; pass one -url")
+			}
+			src, err = newMultiSource(monitors, httpOpts, hc)
+		}
+		if err != nil {
+			fail("%v", err)
+		}
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "history":
+			if len(args) != 2 {
+				usage()
+				os.Exit(2)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+			defer cancel()
+			resp, err := src.history(ctx, args[1], *limit)
+			if err != nil {
+				log.Fatalf("fetch history: %v", err)
+			}
+			printHistory(resp, os.Stdout)
+			return
+		case "report":
+			if err := runReport(src, *timeout, args[1:], os.Stdout); err != nil {
+				log.Fatalf("report: %v", err)
+			}
+			return
+		case "silence":
+			if err := runSilence(src, *timeout, args[1:], os.Stdout); err != nil {
+				log.Fatalf("silence: %v", err)
+			}
+			return
+		case "ack":
+			if err := runAck(src, *timeout, args[1:], os.Stdout); err != nil {
+				log.Fatalf("ack: %v", err)
+			}
+			return
+		case "tui":
+			if *watchMode || !v.tabular() {
+				log.Fatal("tui cannot be combined with -watch or a non-table -o")
+			}
+			if err := runTUI(src, *timeout, v, args[1:]); err != nil {
+				log.Fatalf("tui: %v", err)
+			}
+			return
+		default:
+			usage()
+			os.Exit(2)
+		}
+	}
+
+	if *watchMode {
+		if !v.tabular() {
+			log.Fatal("-watch only supports table and wide output")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := watch(ctx, src.(httpSource).client, v, os.Stdout); err != nil {
+			log.Fatalf("watch: %v", err)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	resp, err := fetchStatus(ctx, *statusURL)
+	resp, err := src.status(ctx)
 	if err != nil {
-		log.Fatalf("fetch status: %v", err)
+		fail("fetch status: %v", err)
+	}
+
+	if *checkMode {
+		code, line := evaluateCheck(v.apply(resp))
+		fmt.Println(line)
+		os.Exit(code)
 	}
 
-	printStatus(resp, os.Stdout)
+	if err := v.write(v.apply(resp), os.Stdout); err != nil {
+		log.Fatalf("write status: %v", err)
+	}
 }
 
-func fetchStatus(ctx context.Context, url string) (statusResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return statusResponse{}, fmt.Errorf("build request: %w", err)
+// natsRequested reports whether to query monitors over NATS: -nats-url was
+// given on the command line, or NATS_URL is set and neither -url/STATUS_URL
+// nor -monitors/STATUS_MONITORS is.
+func natsRequested(fs *flag.FlagSet) (bool, error) {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if (set["url"] || set["monitors"]) && set["nats-url"] {
+		return false, errors.New("use either -url/-monitors or -nats-url")
 	}
+	if set["nats-url"] {
+		return true, nil
+	}
+	return os.Getenv("NATS_URL") != "" && !set["url"] && !set["monitors"] && os.Getenv("STATUS_URL") == "" && os.Getenv("STATUS_MONITORS") == "", nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [history <subject> | report [report flags] | silence <subject> <duration> | ack <subject> | tui [-refresh d]]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command, prints the status table (or follows it with -watch). Use -o")
+	fmt.Fprintln(out, "for JSON, YAML or CSV and -alerting, -subject, -host and -sort to narrow it down.")
+	fmt.Fprintln(out, "With -nats-url, every monitor serving the NATS status service is queried and")
+	fmt.Fprintln(out, "their replies are merged. Several -url flags or -monitors query each monitor over")
+	fmt.Fprintln(out, "HTTP and list its view of every subject in a MONITOR column.")
+	fmt.Fprintln(out, "  history <subject>  print the subject's recent beats and notifications")
+	fmt.Fprintln(out, "  report             print uptime per subject (see report -h)")
+	fmt.Fprintln(out, "  silence <subject> <duration>")
+	fmt.Fprintln(out, "                     suppress the subject's notifications for duration (0 lifts it)")
+	fmt.Fprintln(out, "  ack <subject>      stop repeating the subject's alert until it resolves")
+	fmt.Fprintln(out, "  tui                full-screen view to browse, filter and inspect subjects")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+// httpSource queries a single monitor's HTTP status endpoint.
+type httpSource struct {
+	client *statusapi.Client
+}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+func newHTTPSource(url string, opts ...statusapi.ClientOption) (httpSource, error) {
+	client, err := statusapi.NewClient(url, opts...)
 	if err != nil {
-		return statusResponse{}, fmt.Errorf("request status: %w", err)
+		return httpSource{}, err
 	}
-	defer res.Body.Close()
+	return httpSource{client: client}, nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
-		return statusResponse{}, fmt.Errorf("unexpected status %s: %s", res.Status, strings.TrimSpace(string(body)))
+func (s httpSource) status(ctx context.Context) (statusResponse, error) {
+	status, err := s.client.Status(ctx)
+	if err != nil {
+		return statusResponse{}, err
 	}
+	return fromStatus(status), nil
+}
 
-	var status statusResponse
-	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
-		return statusResponse{}, fmt.Errorf("decode response: %w", err)
-	}
+func (s httpSource) history(ctx context.Context, subject string, limit int) (statusapi.History, error) {
+	return s.client.History(ctx, subject, limit)
+}
+
+func (s httpSource) report(ctx context.Context, q statusapi.ReportQuery) (statusapi.Report, error) {
+	return s.client.Report(ctx, q)
+}
 
-	return status, nil
+func (s httpSource) forget(ctx context.Context, subject string) error {
+	return s.client.Forget(ctx, subject)
 }
 
-func printStatus(resp statusResponse, w io.Writer) {
+// writeStatus renders the status table; wide adds interval, sequence,
+// restart and cadence columns.
+func writeStatus(resp statusResponse, w io.Writer, colorize, wide bool) {
 	if resp.ObservedAt.IsZero() {
 		resp.ObservedAt = time.Now()
 	}
@@ -84,19 +308,71 @@ func printStatus(resp statusResponse, w io.Writer) {
 
 	if len(resp.Subjects) == 0 {
 		fmt.Fprintln(w, "No heartbeats observed yet.")
+		writeUnreachable(resp, w, colorize)
 		return
 	}
 
 	alerting := 0
-	fmt.Fprintln(w)
-
-	var buf bytes.Buffer
-	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(tw, "STATUS\tSUBJECT\tDESCRIPTION\tHOST\tLAST SEEN\tDETAILS")
 	for _, s := range resp.Subjects {
 		if s.AlertActive {
 			alerting++
 		}
+	}
+	fmt.Fprintln(w)
+
+	out := strings.Join(statusRows(resp.Subjects, wide), "\n") + "\n"
+	if colorize {
+		out = colorizeStatuses(out)
+	}
+
+	fmt.Fprint(w, out)
+	if multiMonitor(resp.Subjects) {
+		subjects, monitors := map[string]bool{}, map[string]bool{}
+		for _, s := range resp.Subjects {
+			subjects[s.Subject], monitors[s.Monitor] = true, true
+		}
+		fmt.Fprintf(w, "\n%d alert(s) firing across %d subject(s) on %d monitor(s)\n", alerting, len(subjects), len(monitors))
+	} else {
+		fmt.Fprintf(w, "\n%d alert(s) firing across %d subject(s)\n", alerting, len(resp.Subjects))
+	}
+	writeUnreachable(resp, w, colorize)
+}
+
+func writeUnreachable(resp statusResponse, w io.Writer, colorize bool) {
+	if len(resp.Unreachable) == 0 {
+		return
+	}
+	fmt.Fprintln(w, applyColor("\nUnreachable monitors:", colorize, 31))
+	for _, u := range resp.Unreachable {
+		fmt.Fprintf(w, "  %s: %s\n", u.Monitor, u.Error)
+	}
+}
+
+// multiMonitor reports whether the subjects come from several monitors and
+// so need a MONITOR column.
+func multiMonitor(subjects []subjectState) bool {
+	for _, s := range subjects {
+		if s.Monitor != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRows renders the status table as aligned lines, header first.
+func statusRows(subjects []subjectState, wide bool) []string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	multi := multiMonitor(subjects)
+	header := "STATUS\tSUBJECT\tDESCRIPTION\tHOST\tLAST SEEN\tSKEW"
+	if multi {
+		header = "STATUS\tMONITOR\tSUBJECT\tDESCRIPTION\tHOST\tLAST SEEN\tSKEW"
+	}
+	if wide {
+		header += "\tINTERVAL\tWINDOW\tSEQ\tRESTARTS\tLOST\tP95\tUPTIME"
+	}
+	fmt.Fprintln(tw, header+"\tDETAILS")
+	for _, s := range subjects {
 		status, details := summarizeSubject(s)
 
 		lastSeen := "-"
@@ -109,17 +385,46 @@ func printStatus(resp statusResponse, w io.Writer) {
 			host = "-"
 		}
 
-		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", status, s.Subject, s.Description, host, lastSeen, details)
+		skew := fallback(s.Skew, "-")
+		if s.SkewExceeded {
+			skew += "!"
+		}
+
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", status, s.Subject, s.Description, host, lastSeen, skew)
+		if multi {
+			row = fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s", status, s.Monitor, s.Subject, s.Description, host, lastSeen, skew)
+		}
+		if wide {
+			row += "\t" + strings.Join(wideColumns(s), "\t")
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", row, details)
 	}
 	_ = tw.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
 
-	out := buf.String()
-	if shouldColor(w) {
-		out = colorizeStatuses(out)
+func wideColumns(s subjectState) []string {
+	seq := "-"
+	if s.Sequence > 0 {
+		seq = strconv.FormatUint(s.Sequence, 10)
+	}
+	p95 := "-"
+	if s.Cadence != nil {
+		p95 = s.Cadence.P95
+	}
+	uptime := "-"
+	if len(s.Uptime) > 0 {
+		uptime = fmt.Sprintf("%s (%s)", formatPercent(s.Uptime[0].UptimePercent), s.Uptime[0].Window)
+	}
+	return []string{
+		fallback(s.Interval, "-"),
+		fallback(s.AllowedWindow, "-"),
+		seq,
+		strconv.Itoa(s.Restarts),
+		strconv.FormatUint(s.LostBeats, 10),
+		p95,
+		uptime,
 	}
-
-	fmt.Fprint(w, out)
-	fmt.Fprintf(w, "\n%d alert(s) firing across %d subject(s)\n", alerting, len(resp.Subjects))
 }
 
 func summarizeSubject(s subjectState) (string, string) {
@@ -139,10 +444,37 @@ func summarizeSubject(s subjectState) (string, string) {
 			details += fmt.Sprintf(" (%d beats)", s.MissCount)
 		}
 	}
+	if s.HostConflict {
+		details += fmt.Sprintf(", multiple hosts (%s)", joinHosts(s.Hosts))
+	} else if s.Duplicate {
+		details += ", multiple publishers"
+	}
+	if s.Unverified {
+		details += ", unverified"
+	}
+	if s.Disagreement {
+		details += ", monitors disagree"
+	}
+	if s.Cadence != nil && s.Cadence.DriftExceeded {
+		details += fmt.Sprintf(", drifting (p95 %s)", s.Cadence.P95)
+	}
+	for _, h := range s.Hosts {
+		if h.AlertActive {
+			details += fmt.Sprintf(", %s missing", h.Host)
+		}
+	}
 
 	return status, details
 }
 
+func joinHosts(hosts []statusapi.HostStatus) string {
+	names := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		names = append(names, h.Host)
+	}
+	return strings.Join(names, ", ")
+}
+
 func fallback(v, defaultVal string) string {
 	if strings.TrimSpace(v) == "" {
 		return defaultVal
@@ -166,11 +498,30 @@ func envDuration(key string, fallback time.Duration) time.Duration {
 	return fallback
 }
 
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func envBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		return v == "1" || v == "true" || v == "TRUE" || v == "yes" || v == "on"
+	}
+	return fallback
+}
+
 func shouldColor(w io.Writer) bool {
 	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
 		return false
 	}
+	return isTerminal(w)
+}
 
+func isTerminal(w io.Writer) bool {
 	f, ok := w.(*os.File)
 	if !ok {
 		return false
@@ -200,20 +551,20 @@ func colorizeStatuses(out string) string {
 		if spaceIdx <= 0 {
 			continue
 		}
-		status := line[:spaceIdx]
-		rest := line[spaceIdx:]
-
-		switch status {
-		case "ALERT!":
-			status = applyColor(status, true, 31)
-		case "LATE":
-			status = applyColor(status, true, 33)
-		case "OK":
-			status = applyColor(status, true, 32)
-		default:
-			// leave as-is
-		}
-		lines[i] = status + rest
+		lines[i] = colorStatus(line[:spaceIdx]) + line[spaceIdx:]
 	}
 	return strings.Join(lines, "\n")
 }
+
+func colorStatus(status string) string {
+	switch status {
+	case "ALERT!":
+		return applyColor(status, true, 31)
+	case "LATE":
+		return applyColor(status, true, 33)
+	case "OK":
+		return applyColor(status, true, 32)
+	default:
+		return status
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/config"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// namedMonitor is one monitor's status endpoint, labelled in the MONITOR
+// column, with optional credentials overriding -token and -user.
+type namedMonitor struct {
+	Name     string `yaml:"name"`
+	URL      string `yaml:"url"`
+	Token    string `yaml:"token"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// monitorsFile is the format of the -monitors file.
+type monitorsFile struct {
+	Monitors []namedMonitor `yaml:"monitors"`
+}
+
+// urlList collects repeated -url flags.
+type urlList []string
+
+func (l *urlList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *urlList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+var monitorName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// parseMonitors resolves the monitors named in the -monitors file and the
+// -url values, given as "url" or "name=url". Unnamed monitors are labelled
+// with their host.
+func parseMonitors(urls []string, path string) ([]namedMonitor, error) {
+	var monitors []namedMonitor
+	if path != "" {
+		var f monitorsFile
+		if err := config.Load(path, &f); err != nil {
+			return nil, err
+		}
+		for i, m := range f.Monitors {
+			if m.Name == "" || m.URL == "" {
+				return nil, fmt.Errorf("%s: monitor %d needs a name and a url", path, i+1)
+			}
+			monitors = append(monitors, m)
+		}
+	}
+	for _, raw := range urls {
+		m := namedMonitor{URL: raw}
+		if name, u, ok := strings.Cut(raw, "="); ok && monitorName.MatchString(name) {
+			m = namedMonitor{Name: name, URL: u}
+		}
+		if m.Name == "" {
+			u, err := neturl.Parse(m.URL)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("invalid monitor url %q", raw)
+			}
+			m.Name = u.Host
+		}
+		monitors = append(monitors, m)
+	}
+
+	seen := map[string]bool{}
+	for _, m := range monitors {
+		if seen[m.Name] {
+			return nil, fmt.Errorf("monitor %q is listed twice", m.Name)
+		}
+		seen[m.Name] = true
+	}
+	return monitors, nil
+}
+
+// multiSource queries several monitors' HTTP endpoints concurrently. The
+// status table lists each monitor's view of a subject on its own row; a
+// monitor that cannot be reached is reported instead of failing the query.
+type multiSource struct {
+	monitors []namedMonitor
+	sources  []httpSource
+}
+
+func newMultiSource(monitors []namedMonitor, opts httpOptions, hc *http.Client) (multiSource, error) {
+	m := multiSource{monitors: monitors}
+	for _, mon := range monitors {
+		src, err := newHTTPSource(mon.URL, opts.clientOptions(mon, hc)...)
+		if err != nil {
+			return multiSource{}, fmt.Errorf("monitor %s: %w", mon.Name, err)
+		}
+		m.sources = append(m.sources, src)
+	}
+	return m, nil
+}
+
+// each calls fn for every monitor concurrently and returns the errors by
+// monitor index.
+func (m multiSource) each(fn func(i int, src httpSource) error) []error {
+	errs := make([]error, len(m.monitors))
+	var wg sync.WaitGroup
+	for i, src := range m.sources {
+		wg.Add(1)
+		go func(i int, src httpSource) {
+			defer wg.Done()
+			errs[i] = fn(i, src)
+		}(i, src)
+	}
+	wg.Wait()
+	return errs
+}
+
+// failure combines the per-monitor errors, or returns nil when any monitor
+// succeeded.
+func (m multiSource) failure(errs []error) error {
+	var msgs []string
+	for i, err := range errs {
+		if err == nil {
+			return nil
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %v", m.monitors[i].Name, err))
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+func (m multiSource) status(ctx context.Context) (statusResponse, error) {
+	resps := make([]statusResponse, len(m.monitors))
+	errs := m.each(func(i int, src httpSource) error {
+		var err error
+		resps[i], err = src.status(ctx)
+		return err
+	})
+	if err := m.failure(errs); err != nil {
+		return statusResponse{}, fmt.Errorf("no monitor reachable: %w", err)
+	}
+
+	var found []monitorStatus
+	var unreachable []unreachableMonitor
+	for i, resp := range resps {
+		name := m.monitors[i].Name
+		if errs[i] != nil {
+			unreachable = append(unreachable, unreachableMonitor{Monitor: name, Error: errs[i].Error()})
+			continue
+		}
+		found = append(found, monitorStatus{monitor: name, resp: resp})
+	}
+	merged := mergeStatuses(found)
+	merged.Unreachable = unreachable
+	return merged, nil
+}
+
+// monitorStatus is one monitor's status response and its MONITOR label.
+type monitorStatus struct {
+	monitor string
+	resp    statusResponse
+}
+
+// mergeStatuses lists every monitor's view of each subject on its own row,
+// labelled with the monitor, and flags subjects the monitors disagree on.
+func mergeStatuses(statuses []monitorStatus) statusResponse {
+	merged := statusResponse{Version: statusapi.Version}
+	for _, st := range statuses {
+		if st.resp.ObservedAt.After(merged.ObservedAt) {
+			merged.ObservedAt = st.resp.ObservedAt
+		}
+		for _, subj := range st.resp.Subjects {
+			subj.Monitor = st.monitor
+			merged.Subjects = append(merged.Subjects, subj)
+		}
+	}
+	// monitors stay in the order they were given within each subject
+	sort.SliceStable(merged.Subjects, func(i, j int) bool {
+		return merged.Subjects[i].Subject < merged.Subjects[j].Subject
+	})
+	markDisagreements(merged.Subjects)
+	return merged
+}
+
+// markDisagreements flags every row of a subject whose monitors do not
+// agree on whether it is OK, late or alerting.
+func markDisagreements(subjects []subjectState) {
+	ranks := map[string]map[int]bool{}
+	for _, s := range subjects {
+		if ranks[s.Subject] == nil {
+			ranks[s.Subject] = map[int]bool{}
+		}
+		ranks[s.Subject][statusRank(s)] = true
+	}
+	for i, s := range subjects {
+		subjects[i].Disagreement = len(ranks[s.Subject]) > 1
+	}
+}
+
+func (m multiSource) history(ctx context.Context, subject string, limit int) (statusapi.History, error) {
+	hists := make([]statusapi.History, len(m.monitors))
+	errs := m.each(func(i int, src httpSource) error {
+		var err error
+		hists[i], err = src.history(ctx, subject, limit)
+		return err
+	})
+	if err := m.failure(errs); err != nil {
+		return statusapi.History{}, err
+	}
+	var found []statusapi.History
+	for i, hist := range hists {
+		if errs[i] == nil {
+			found = append(found, hist)
+		}
+	}
+	return newestHistory(found), nil
+}
+
+func (m multiSource) report(ctx context.Context, q statusapi.ReportQuery) (statusapi.Report, error) {
+	reports := make([]statusapi.Report, len(m.monitors))
+	errs := m.each(func(i int, src httpSource) error {
+		var err error
+		reports[i], err = src.report(ctx, q)
+		return err
+	})
+	if err := m.failure(errs); err != nil {
+		return statusapi.Report{}, err
+	}
+	var found []statusapi.Report
+	for i, report := range reports {
+		if errs[i] == nil {
+			found = append(found, report)
+		}
+	}
+	return mergeReports(found), nil
+}
+
+// forget asks every monitor to drop subject; it succeeds when at least one
+// of them was tracking it.
+func (m multiSource) forget(ctx context.Context, subject string) error {
+	return m.failure(m.each(func(_ int, src httpSource) error {
+		return src.forget(ctx, subject)
+	}))
+}
+
+// silence silences subject on every monitor; it succeeds when at least one
+// of them was tracking it.
+func (m multiSource) silence(ctx context.Context, subject string, d time.Duration) (time.Time, error) {
+	untils := make([]time.Time, len(m.monitors))
+	errs := m.each(func(i int, src httpSource) error {
+		var err error
+		untils[i], err = src.silence(ctx, subject, d)
+		return err
+	})
+	if err := m.failure(errs); err != nil {
+		return time.Time{}, err
+	}
+	var until time.Time
+	for _, u := range untils {
+		if u.After(until) {
+			until = u
+		}
+	}
+	return until, nil
+}
+
+// ack acknowledges the subject's alert on every monitor; it succeeds when
+// at least one of them was alerting.
+func (m multiSource) ack(ctx context.Context, subject string) error {
+	return m.failure(m.each(func(_ int, src httpSource) error {
+		return src.ack(ctx, subject)
+	}))
+}
+
+// newestHistory picks, from several monitors' histories of one subject,
+// the one with the most recent entry.
+func newestHistory(hists []statusapi.History) statusapi.History {
+	var best statusapi.History
+	for i, hist := range hists {
+		if i == 0 || lastEntry(hist).After(lastEntry(best)) {
+			best = hist
+		}
+	}
+	return best
+}
+
+// mergeReports combines several monitors' reports, keeping for each subject
+// the report from the monitor that has observed it the longest.
+func mergeReports(reports []statusapi.Report) statusapi.Report {
+	merged := statusapi.Report{Version: statusapi.Version}
+	bySubject := map[string]int{}
+	for _, resp := range reports {
+		merged.From, merged.To = resp.From, resp.To
+		for _, subj := range resp.Subjects {
+			if i, ok := bySubject[subj.Subject]; ok {
+				if parseDuration(subj.Observed) > parseDuration(merged.Subjects[i].Observed) {
+					merged.Subjects[i] = subj
+				}
+				continue
+			}
+			bySubject[subj.Subject] = len(merged.Subjects)
+			merged.Subjects = append(merged.Subjects, subj)
+		}
+	}
+	sortReports(merged.Subjects)
+	return merged
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestParseMonitors(t *testing.T) {
+	cases := []struct {
+		name  string
+		urls  []string
+		want  []namedMonitor
+		error string
+	}{
+		{"named", []string{"east=http://a:8080/status"}, []namedMonitor{{Name: "east", URL: "http://a:8080/status"}}, ""},
+		{"unnamed uses host", []string{"http://b:8080/status"}, []namedMonitor{{Name: "b:8080", URL: "http://b:8080/status"}}, ""},
+		{"equals in query", []string{"http://c/status?x=1"}, []namedMonitor{{Name: "c", URL: "http://c/status?x=1"}}, ""},
+		{"invalid url", []string{"not a url"}, nil, "invalid monitor url"},
+		{"duplicate name", []string{"east=http://a/", "east=http://b/"}, nil, "listed twice"},
+	}
+	for _, tc := range cases {
+		got, err := parseMonitors(tc.urls, "")
+		if tc.error != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.error) {
+				t.Errorf("%s: error = %v, want %q", tc.name, err, tc.error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMergeStatuses(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	merged := mergeStatuses([]monitorStatus{
+		{monitor: "east", resp: statusResponse{ObservedAt: t0, Subjects: []subjectState{
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "db", Missing: true}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "api"}},
+		}}},
+		{monitor: "west", resp: statusResponse{ObservedAt: t0.Add(time.Second), Subjects: []subjectState{
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "api"}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "db"}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "cron", AlertActive: true}},
+		}}},
+	})
+	if !merged.ObservedAt.Equal(t0.Add(time.Second)) {
+		t.Errorf("observed at %v, want the most recent", merged.ObservedAt)
+	}
+	var rows []string
+	for _, s := range merged.Subjects {
+		row := s.Monitor + "/" + s.Subject
+		if s.Disagreement {
+			row += "!"
+		}
+		rows = append(rows, row)
+	}
+	want := []string{"east/api", "west/api", "west/cron", "east/db!", "west/db!"}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows %v, want %v", rows, want)
+	}
+}
+
+func TestMarkDisagreements(t *testing.T) {
+	cases := []struct {
+		name     string
+		subjects []subjectState
+		want     []bool
+	}{
+		{"single view", []subjectState{{SubjectStatus: statusapi.SubjectStatus{Subject: "api", AlertActive: true}}}, []bool{false}},
+		{"agreeing", []subjectState{{SubjectStatus: statusapi.SubjectStatus{Subject: "api", Missing: true}}, {SubjectStatus: statusapi.SubjectStatus{Subject: "api", Missing: true}}}, []bool{false, false}},
+		{"ok and late", []subjectState{{SubjectStatus: statusapi.SubjectStatus{Subject: "api"}}, {SubjectStatus: statusapi.SubjectStatus{Subject: "api", Missing: true}}}, []bool{true, true}},
+		{"late and alerting", []subjectState{{SubjectStatus: statusapi.SubjectStatus{Subject: "api", Missing: true}}, {SubjectStatus: statusapi.SubjectStatus{Subject: "api", AlertActive: true, Missing: true}}}, []bool{true, true}},
+		{"other subjects unaffected", []subjectState{{SubjectStatus: statusapi.SubjectStatus{Subject: "api"}}, {SubjectStatus: statusapi.SubjectStatus{Subject: "db", AlertActive: true}}, {SubjectStatus: statusapi.SubjectStatus{Subject: "api", AlertActive: true}}}, []bool{true, false, true}},
+		{"stale flag cleared", []subjectState{{Disagreement: true, SubjectStatus: statusapi.SubjectStatus{Subject: "api"}}, {Disagreement: true, SubjectStatus: statusapi.SubjectStatus{Subject: "api"}}}, []bool{false, false}},
+	}
+	for _, tc := range cases {
+		markDisagreements(tc.subjects)
+		var got []bool
+		for _, s := range tc.subjects {
+			got = append(got, s.Disagreement)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMultiSourceStatusReportsUnreachable(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"observed_at":"2024-06-01T12:00:00Z","subjects":[{"subject":"api","alert_active":true}]}`))
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	src, err := newMultiSource([]namedMonitor{{Name: "east", URL: up.URL}, {Name: "west", URL: down.URL}}, httpOptions{}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("new source: %v", err)
+	}
+	resp, err := src.status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(resp.Subjects) != 1 || resp.Subjects[0].Monitor != "east" || !resp.Subjects[0].AlertActive {
+		t.Fatalf("unexpected subjects %+v", resp.Subjects)
+	}
+	if len(resp.Unreachable) != 1 || resp.Unreachable[0].Monitor != "west" || resp.Unreachable[0].Error == "" {
+		t.Fatalf("unexpected unreachable monitors %+v", resp.Unreachable)
+	}
+
+	src, _ = newMultiSource([]namedMonitor{{Name: "west", URL: down.URL}}, httpOptions{}, http.DefaultClient)
+	if _, err := src.status(context.Background()); err == nil || !strings.Contains(err.Error(), "no monitor reachable") {
+		t.Fatalf("expected every monitor to be unreachable, got %v", err)
+	}
+}
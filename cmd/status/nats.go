@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/venkytv/nats-heartbeat/internal/natsconn"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// replyStall is how long to wait for further monitors after a reply
+// arrives before treating the set of replies as complete.
+const replyStall = 300 * time.Millisecond
+
+// natsSource queries monitors through their NATS micro service endpoints.
+// Every running monitor answers each request and the replies are merged.
+type natsSource struct {
+	nc      *nats.Conn
+	subject string
+	admin   string // admin subject prefix, for forget
+	auth    string // Authorization header for monitors requiring credentials
+}
+
+// serviceReply is one monitor's answer to a service request.
+type serviceReply struct {
+	monitor string
+	data    []byte
+	code    string
+	err     string
+}
+
+func (s natsSource) status(ctx context.Context) (statusResponse, error) {
+	replies, err := s.requestAll(ctx, s.subject+".status", nil)
+	if err != nil {
+		return statusResponse{}, err
+	}
+	// like several -url monitors, each monitor's view of a subject gets its
+	// own row; a monitor whose reply cannot be used is listed separately
+	var found []monitorStatus
+	var failed []unreachableMonitor
+	for _, r := range replies {
+		var status statusapi.Status
+		if err := r.decode(&status, &status.Version); err != nil {
+			failed = append(failed, unreachableMonitor{Monitor: r.monitor, Error: err.Error()})
+			continue
+		}
+		found = append(found, monitorStatus{monitor: r.monitor, resp: fromStatus(status)})
+	}
+	if len(found) == 0 {
+		return statusResponse{}, errors.New(failed[0].Error)
+	}
+	merged := mergeStatuses(found)
+	merged.Unreachable = failed
+	return merged, nil
+}
+
+func (s natsSource) history(ctx context.Context, subject string, limit int) (statusapi.History, error) {
+	body := subject
+	if limit > 0 {
+		body += " " + strconv.Itoa(limit)
+	}
+	replies, err := s.requestAll(ctx, s.subject+".history", []byte(body))
+	if err != nil {
+		return statusapi.History{}, err
+	}
+	var found []statusapi.History
+	for _, r := range replies {
+		if r.code == "404" {
+			continue
+		}
+		var resp statusapi.History
+		if err := r.decode(&resp, &resp.Version); err != nil {
+			return statusapi.History{}, err
+		}
+		found = append(found, resp)
+	}
+	if len(found) == 0 {
+		return statusapi.History{}, fmt.Errorf("unknown subject %q", subject)
+	}
+	return newestHistory(found), nil
+}
+
+func (s natsSource) report(ctx context.Context, q statusapi.ReportQuery) (statusapi.Report, error) {
+	replies, err := s.requestAll(ctx, s.subject+".report", []byte(q.Values().Encode()))
+	if err != nil {
+		return statusapi.Report{}, err
+	}
+	reports := make([]statusapi.Report, 0, len(replies))
+	for _, r := range replies {
+		var resp statusapi.Report
+		if err := r.decode(&resp, &resp.Version); err != nil {
+			return statusapi.Report{}, err
+		}
+		reports = append(reports, resp)
+	}
+	return mergeReports(reports), nil
+}
+
+// forget asks every monitor to drop subject; it succeeds when at least one
+// of them was tracking it.
+func (s natsSource) forget(ctx context.Context, subject string) error {
+	if s.admin == "" {
+		return errors.New("forgetting over NATS needs -admin-subject")
+	}
+	replies, err := s.requestAll(ctx, s.admin+".forget", []byte(subject))
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, r := range replies {
+		var resp statusapi.AdminResult
+		if err := json.Unmarshal(r.data, &resp); err != nil {
+			return fmt.Errorf("decode admin reply: %w", err)
+		}
+		if resp.OK {
+			return nil
+		}
+		if !contains(errs, resp.Error) {
+			errs = append(errs, resp.Error)
+		}
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+// requestAll sends a request to subject and collects replies until no
+// further monitor answers within replyStall or ctx is done.
+func (s natsSource) requestAll(ctx context.Context, subject string, data []byte) ([]serviceReply, error) {
+	inbox := s.nc.NewRespInbox()
+	sub, err := s.nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+	msg := nats.NewMsg(subject)
+	msg.Reply, msg.Data = inbox, data
+	if s.auth != "" {
+		msg.Header.Set("Authorization", s.auth)
+	}
+	if err := s.nc.PublishMsg(msg); err != nil {
+		return nil, err
+	}
+
+	var replies []serviceReply
+	for {
+		// after the first reply, give other monitors a little longer each
+		waitCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(replies) > 0 {
+			waitCtx, cancel = context.WithTimeout(ctx, replyStall)
+		}
+		msg, err := sub.NextMsgWithContext(waitCtx)
+		cancel()
+		if err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return nil, err
+			}
+			if len(replies) == 0 {
+				return nil, fmt.Errorf("no monitor replied on %s", subject)
+			}
+			return replies, nil
+		}
+		if msg.Header.Get("Status") == "503" {
+			return nil, fmt.Errorf("no monitor is serving %s", subject)
+		}
+		replies = append(replies, serviceReply{
+			monitor: msg.Header.Get(statusapi.MonitorIDHeader),
+			data:    msg.Data,
+			code:    msg.Header.Get(micro.ErrorCodeHeader),
+			err:     msg.Header.Get(micro.ErrorHeader),
+		})
+	}
+}
+
+// decode unmarshals the reply into v and checks the API version it
+// carries in *version.
+func (r serviceReply) decode(v interface{}, version *int) error {
+	if r.err != "" {
+		return fmt.Errorf("monitor %s: %s", r.monitor, r.err)
+	}
+	if err := json.Unmarshal(r.data, v); err != nil {
+		return fmt.Errorf("decode reply from monitor %s: %w", r.monitor, err)
+	}
+	if err := statusapi.CheckVersion(*version); err != nil {
+		return fmt.Errorf("monitor %s: %w", r.monitor, err)
+	}
+	return nil
+}
+
+// connectNATS makes a single connection attempt; a one-shot command should
+// fail fast rather than retry like the long-running services.
+func connectNATS(opts natsconn.Options, timeout time.Duration) (*nats.Conn, error) {
+	extra, err := opts.NATSOptions()
+	if err != nil {
+		return nil, err
+	}
+	return nats.Connect(opts.URL, append(extra, nats.Name("heartbeat-status"), nats.Timeout(timeout))...)
+}
+
+func lastEntry(resp statusapi.History) time.Time {
+	if len(resp.Entries) == 0 {
+		return time.Time{}
+	}
+	return resp.Entries[len(resp.Entries)-1].At
+}
+
+func parseDuration(s string) time.Duration {
+	d, _ := time.ParseDuration(strings.TrimSpace(s))
+	return d
+}
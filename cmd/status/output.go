@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// view selects, orders and renders subjects for the status table and its
+// machine-readable variants.
+type view struct {
+	format   string // table, wide, json, yaml or csv
+	alerting bool
+	subject  string // glob matched against the subject name
+	host     string // glob matched against the subject's hosts
+	sortBy   string // subject, status, last-seen or host
+	reverse  bool
+}
+
+var (
+	outputFormats = []string{"table", "wide", "json", "yaml", "csv"}
+	sortKeys      = []string{"subject", "status", "last-seen", "host"}
+)
+
+func (v view) validate() error {
+	if !contains(outputFormats, v.format) {
+		return fmt.Errorf("unknown output format %q (want %s)", v.format, strings.Join(outputFormats, ", "))
+	}
+	if !contains(sortKeys, v.sortBy) {
+		return fmt.Errorf("unknown sort key %q (want %s)", v.sortBy, strings.Join(sortKeys, ", "))
+	}
+	for _, pattern := range []string{v.subject, v.host} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// tabular reports whether the format is one of the human-readable tables.
+func (v view) tabular() bool {
+	return v.format == "table" || v.format == "wide"
+}
+
+// apply filters and sorts the subjects in resp.
+func (v view) apply(resp statusResponse) statusResponse {
+	subjects := make([]subjectState, 0, len(resp.Subjects))
+	for _, s := range resp.Subjects {
+		if v.matches(s) {
+			subjects = append(subjects, s)
+		}
+	}
+
+	less := func(a, b subjectState) bool { return a.Subject < b.Subject }
+	switch v.sortBy {
+	case "status":
+		less = func(a, b subjectState) bool {
+			if ra, rb := statusRank(a), statusRank(b); ra != rb {
+				return ra < rb
+			}
+			return a.Subject < b.Subject
+		}
+	case "last-seen":
+		less = func(a, b subjectState) bool {
+			if !a.LastSeen.Equal(b.LastSeen) {
+				return a.LastSeen.Before(b.LastSeen)
+			}
+			return a.Subject < b.Subject
+		}
+	case "host":
+		less = func(a, b subjectState) bool {
+			if a.Host != b.Host {
+				return a.Host < b.Host
+			}
+			return a.Subject < b.Subject
+		}
+	}
+	sort.SliceStable(subjects, func(i, j int) bool {
+		if v.reverse {
+			return less(subjects[j], subjects[i])
+		}
+		return less(subjects[i], subjects[j])
+	})
+
+	resp.Subjects = subjects
+	return resp
+}
+
+func (v view) matches(s subjectState) bool {
+	if v.alerting && !s.AlertActive {
+		return false
+	}
+	if v.subject != "" {
+		if ok, _ := path.Match(v.subject, s.Subject); !ok {
+			return false
+		}
+	}
+	if v.host != "" {
+		hosts := []string{s.Host}
+		for _, h := range s.Hosts {
+			hosts = append(hosts, h.Host)
+		}
+		for _, h := range hosts {
+			if ok, _ := path.Match(v.host, h); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// statusRank orders alerting subjects first, then late ones.
+func statusRank(s subjectState) int {
+	switch {
+	case s.AlertActive:
+		return 0
+	case s.Missing:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// write renders resp, already filtered, in the view's format.
+func (v view) write(resp statusResponse, w io.Writer) error {
+	switch v.format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	case "yaml":
+		return writeYAML(resp, w)
+	case "csv":
+		return writeStatusCSV(resp, w)
+	default:
+		writeStatus(resp, w, shouldColor(w), v.format == "wide")
+		return nil
+	}
+}
+
+// writeYAML renders v as YAML using its JSON field names.
+func writeYAML(v interface{}, w io.Writer) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func writeStatusCSV(resp statusResponse, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	multi := multiMonitor(resp.Subjects)
+	header := []string{"status", "subject", "description", "host", "last_seen", "skew", "interval", "allowed_window", "missing", "miss_for", "miss_count", "alert_active"}
+	if multi {
+		header = append(header, "monitor", "disagreement")
+	}
+	_ = cw.Write(header)
+	for _, s := range resp.Subjects {
+		status, _ := summarizeSubject(s)
+		status = strings.ToLower(strings.TrimSuffix(status, "!"))
+		lastSeen := ""
+		if !s.LastSeen.IsZero() {
+			lastSeen = s.LastSeen.Format(time.RFC3339)
+		}
+		record := []string{
+			status,
+			s.Subject,
+			s.Description,
+			s.Host,
+			lastSeen,
+			s.Skew,
+			s.Interval,
+			s.AllowedWindow,
+			strconv.FormatBool(s.Missing),
+			s.MissFor,
+			strconv.Itoa(s.MissCount),
+			strconv.FormatBool(s.AlertActive),
+		}
+		if multi {
+			record = append(record, s.Monitor, strconv.FormatBool(s.Disagreement))
+		}
+		_ = cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+var outputSeen = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// outputSubjects is a small fleet: one alerting, one late, two healthy, one
+// tracked per host.
+func outputSubjects() statusResponse {
+	return statusResponse{
+		Version:    statusapi.Version,
+		ObservedAt: outputSeen,
+		Subjects: []subjectState{
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "db.backup", Host: "db-1", LastSeen: outputSeen.Add(-time.Hour), AlertActive: true, Missing: true}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "api", Host: "web-2", LastSeen: outputSeen.Add(-time.Minute), Missing: true}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "worker", Host: "batch-1", LastSeen: outputSeen.Add(-time.Second)}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "cron", Host: "web-1", LastSeen: outputSeen.Add(-2 * time.Second)}},
+			{SubjectStatus: statusapi.SubjectStatus{Subject: "web", Host: "web-3", LastSeen: outputSeen.Add(-3 * time.Second), Hosts: []statusapi.HostStatus{
+				{Host: "web-3", LastSeen: outputSeen.Add(-3 * time.Second)},
+				{Host: "edge-1", LastSeen: outputSeen.Add(-time.Minute), Missing: true},
+			}}},
+		},
+	}
+}
+
+func subjectNames(resp statusResponse) []string {
+	names := make([]string, 0, len(resp.Subjects))
+	for _, s := range resp.Subjects {
+		names = append(names, s.Subject)
+	}
+	return names
+}
+
+func TestViewApply(t *testing.T) {
+	cases := []struct {
+		name string
+		view view
+		want []string
+	}{
+		{"default order", view{sortBy: "subject"}, []string{"api", "cron", "db.backup", "web", "worker"}},
+		{"reverse", view{sortBy: "subject", reverse: true}, []string{"worker", "web", "db.backup", "cron", "api"}},
+		{"status", view{sortBy: "status"}, []string{"db.backup", "api", "cron", "web", "worker"}},
+		{"status reversed", view{sortBy: "status", reverse: true}, []string{"worker", "web", "cron", "api", "db.backup"}},
+		{"last seen", view{sortBy: "last-seen"}, []string{"db.backup", "api", "web", "cron", "worker"}},
+		{"host", view{sortBy: "host"}, []string{"worker", "db.backup", "cron", "api", "web"}},
+		{"alerting", view{sortBy: "subject", alerting: true}, []string{"db.backup"}},
+		{"subject glob", view{sortBy: "subject", subject: "w*"}, []string{"web", "worker"}},
+		{"subject glob without match", view{sortBy: "subject", subject: "nope*"}, []string{}},
+		{"host glob", view{sortBy: "subject", host: "web-*"}, []string{"api", "cron", "web"}},
+		{"host glob over per-host hosts", view{sortBy: "subject", host: "edge-*"}, []string{"web"}},
+		{"combined filters", view{sortBy: "subject", subject: "*r*", host: "web-*"}, []string{"cron"}},
+	}
+	for _, tc := range cases {
+		got := subjectNames(tc.view.apply(outputSubjects()))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestViewMatches(t *testing.T) {
+	perHost := subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "web", Host: "web-3", Hosts: []statusapi.HostStatus{{Host: "web-3"}, {Host: "edge-1"}}}}
+	alerting := subjectState{SubjectStatus: statusapi.SubjectStatus{Subject: "db.backup", AlertActive: true}}
+	cases := []struct {
+		name    string
+		view    view
+		subject subjectState
+		want    bool
+	}{
+		{"no filters", view{}, perHost, true},
+		{"subject glob", view{subject: "we?"}, perHost, true},
+		{"subject glob mismatch", view{subject: "api*"}, perHost, false},
+		{"reported host", view{host: "web-3"}, perHost, true},
+		{"per-host host", view{host: "edge-*"}, perHost, true},
+		{"unknown host", view{host: "db-*"}, perHost, false},
+		{"alerting only, healthy", view{alerting: true}, perHost, false},
+		{"alerting only, alerting", view{alerting: true}, alerting, true},
+		{"host filter without hosts", view{host: "db-*"}, alerting, false},
+	}
+	for _, tc := range cases {
+		if got := tc.view.matches(tc.subject); got != tc.want {
+			t.Errorf("%s: matches = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestViewValidate(t *testing.T) {
+	cases := []struct {
+		view view
+		ok   bool
+	}{
+		{view{format: "table", sortBy: "subject"}, true},
+		{view{format: "csv", sortBy: "last-seen", subject: "db.*", host: "web-?"}, true},
+		{view{format: "xml", sortBy: "subject"}, false},
+		{view{format: "json", sortBy: "age"}, false},
+		{view{format: "json", sortBy: "subject", subject: "[a-"}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.view.validate(); (err == nil) != tc.ok {
+			t.Errorf("validate(%+v) = %v, want ok %t", tc.view, err, tc.ok)
+		}
+	}
+}
+
+func TestViewWriteJSON(t *testing.T) {
+	resp := view{sortBy: "subject", alerting: true}.apply(outputSubjects())
+	var buf bytes.Buffer
+	if err := (view{format: "json"}).write(resp, &buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got statusResponse
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	if !reflect.DeepEqual(got, resp) {
+		t.Fatalf("json round trip changed the response:\n got %+v\nwant %+v", got, resp)
+	}
+}
+
+func TestViewWriteYAML(t *testing.T) {
+	resp := view{sortBy: "subject", alerting: true}.apply(outputSubjects())
+	var buf bytes.Buffer
+	if err := (view{format: "yaml"}).write(resp, &buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got map[string]interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	subjects, ok := got["subjects"].([]interface{})
+	if !ok || len(subjects) != 1 {
+		t.Fatalf("expected one subject, got %s", buf.String())
+	}
+	subject := subjects[0].(map[string]interface{})
+	// keys follow the JSON field names
+	if subject["subject"] != "db.backup" || subject["alert_active"] != true || subject["host"] != "db-1" {
+		t.Fatalf("unexpected subject: %v", subject)
+	}
+}
+
+func TestViewWriteCSV(t *testing.T) {
+	cases := []struct {
+		name  string
+		resp  statusResponse
+		width int
+		rows  [][]string // status, subject and, with several monitors, monitor
+	}{
+		{
+			name:  "single monitor",
+			resp:  view{sortBy: "status"}.apply(outputSubjects()),
+			width: 12,
+			rows:  [][]string{{"alert", "db.backup"}, {"late", "api"}, {"ok", "cron"}, {"ok", "web"}, {"ok", "worker"}},
+		},
+		{
+			name: "several monitors",
+			resp: statusResponse{Subjects: []subjectState{
+				{Monitor: "east", Disagreement: true, SubjectStatus: statusapi.SubjectStatus{Subject: "api", AlertActive: true}},
+				{Monitor: "west", Disagreement: true, SubjectStatus: statusapi.SubjectStatus{Subject: "api"}},
+			}},
+			width: 14,
+			rows:  [][]string{{"alert", "api", "east"}, {"ok", "api", "west"}},
+		},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		if err := (view{format: "csv"}).write(tc.resp, &buf); err != nil {
+			t.Fatalf("%s: write: %v", tc.name, err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: read csv: %v", tc.name, err)
+		}
+		if len(records) != len(tc.rows)+1 {
+			t.Fatalf("%s: expected a header and %d rows, got %v", tc.name, len(tc.rows), records)
+		}
+		if len(records[0]) != tc.width || records[0][0] != "status" || records[0][1] != "subject" {
+			t.Fatalf("%s: unexpected header %v", tc.name, records[0])
+		}
+		for i, want := range tc.rows {
+			record := records[i+1]
+			if record[0] != want[0] || record[1] != want[1] {
+				t.Errorf("%s: row %d = %v, want status %s subject %s", tc.name, i, record, want[0], want[1])
+			}
+			if len(want) > 2 && (record[12] != want[2] || record[13] != "true") {
+				t.Errorf("%s: row %d = %v, want monitor %s flagged as disagreeing", tc.name, i, record, want[2])
+			}
+		}
+	}
+	var buf bytes.Buffer
+	resp := statusResponse{Subjects: []subjectState{{SubjectStatus: statusapi.SubjectStatus{Subject: "api", LastSeen: outputSeen, MissCount: 3, Missing: true}}}}
+	if err := writeStatusCSV(resp, &buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	records, _ := csv.NewReader(&buf).ReadAll()
+	if got := records[1]; got[4] != "2024-06-01T12:00:00Z" || got[8] != "true" || got[10] != "3" {
+		t.Fatalf("unexpected csv record %v", got)
+	}
+}
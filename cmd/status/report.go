@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// runReport implements "status report": uptime per subject over a trailing
+// window or an explicit time range.
+func runReport(src source, timeout time.Duration, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	window := fs.String("window", "", "Trailing window to report on, e.g. 24h, 7d or 30d (default 24h unless -from is set)")
+	from := fs.String("from", "", "Start of the range (RFC 3339)")
+	to := fs.String("to", "", "End of the range (RFC 3339, default now)")
+	asCSV := fs.Bool("csv", false, "Write CSV instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *window != "" && (*from != "" || *to != "") {
+		return fmt.Errorf("use either -window or -from/-to")
+	}
+
+	q := statusapi.ReportQuery{Window: *window}
+	for _, t := range []struct {
+		flag  string
+		value string
+		dst   *time.Time
+	}{{"from", *from, &q.From}, {"to", *to, &q.To}} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return fmt.Errorf("invalid -%s %q: want RFC 3339", t.flag, t.value)
+		}
+		*t.dst = parsed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := src.report(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	if *asCSV {
+		return writeReportCSV(resp, w)
+	}
+	printReport(resp, w)
+	return nil
+}
+
+func printReport(resp statusapi.Report, w io.Writer) {
+	fmt.Fprintf(w, "Uptime from %s to %s\n", resp.From.Format(time.RFC3339), resp.To.Format(time.RFC3339))
+	if len(resp.Subjects) == 0 {
+		fmt.Fprintln(w, "No subjects observed in this range.")
+		return
+	}
+	fmt.Fprintln(w)
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBJECT\tDESCRIPTION\tUPTIME\tDOWNTIME\tOUTAGES\tOBSERVED")
+	for _, s := range resp.Subjects {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Subject, fallback(s.Description, "-"), formatPercent(s.UptimePercent), s.Downtime, s.Outages, s.Observed)
+	}
+	_ = tw.Flush()
+	fmt.Fprint(w, buf.String())
+}
+
+func writeReportCSV(resp statusapi.Report, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"subject", "description", "from", "to", "uptime_percent", "downtime_seconds", "outages", "observed_seconds"})
+	for _, s := range resp.Subjects {
+		_ = cw.Write([]string{
+			s.Subject,
+			s.Description,
+			resp.From.Format(time.RFC3339),
+			resp.To.Format(time.RFC3339),
+			strconv.FormatFloat(s.UptimePercent, 'f', 4, 64),
+			durationSeconds(s.Downtime),
+			strconv.Itoa(s.Outages),
+			durationSeconds(s.Observed),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func sortReports(reports []statusapi.SubjectReport) {
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Subject < reports[j].Subject
+	})
+}
+
+func formatPercent(p float64) string {
+	if p < 100 && p >= 99.995 {
+		// never round a real outage up to a perfect score
+		return "99.99%"
+	}
+	return strconv.FormatFloat(p, 'f', 2, 64) + "%"
+}
+
+func durationSeconds(s string) string {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', 0, 64)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestRunReportRange(t *testing.T) {
+	var gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/report" {
+			http.NotFound(w, r)
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"from":"2024-06-01T00:00:00Z","to":"2024-07-01T00:00:00Z","subjects":[{"subject":"heartbeat.api","uptime_percent":99.5,"downtime":"3h36m0s","outages":2,"observed":"720h0m0s"}]}`))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		name      string
+		args      []string
+		wantQuery string
+		wantErr   string
+	}{
+		{name: "default", wantQuery: ""},
+		{name: "window", args: []string{"-window", "7d"}, wantQuery: "window=7d"},
+		{name: "range", args: []string{"-from", "2024-06-01T00:00:00Z", "-to", "2024-07-01T00:00:00Z"}, wantQuery: "from=2024-06-01T00%3A00%3A00Z&to=2024-07-01T00%3A00%3A00Z"},
+		{name: "open range", args: []string{"-from", "2024-06-01T00:00:00Z"}, wantQuery: "from=2024-06-01T00%3A00%3A00Z"},
+		{name: "invalid time", args: []string{"-from", "yesterday"}, wantErr: "want RFC 3339"},
+		{name: "window and range", args: []string{"-window", "7d", "-from", "2024-06-01T00:00:00Z"}, wantErr: "either -window or -from/-to"},
+		{name: "extra argument", args: []string{"heartbeat.api"}, wantErr: "unexpected arguments"},
+	}
+	src := testHTTPSource(t, srv.URL+"/")
+	for _, tc := range cases {
+		gotQuery = "unset"
+		var out bytes.Buffer
+		err := runReport(src, time.Second, tc.args, &out)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if gotQuery != tc.wantQuery {
+			t.Errorf("%s: expected query %q, got %q", tc.name, tc.wantQuery, gotQuery)
+		}
+		if !strings.HasPrefix(out.String(), "Uptime from 2024-06-01T00:00:00Z to 2024-07-01T00:00:00Z\n") {
+			t.Errorf("%s: expected the range header, got:\n%s", tc.name, out.String())
+		}
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	resp := statusapi.Report{
+		From: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Subjects: []statusapi.SubjectReport{
+			{Subject: "heartbeat.api", Description: "API", Uptime: statusapi.Uptime{UptimePercent: 99.5, Downtime: "3h36m0s", Outages: 2, Observed: "720h0m0s"}},
+			{Subject: "heartbeat.db", Uptime: statusapi.Uptime{UptimePercent: 100, Downtime: "0s", Observed: "24h0m0s"}},
+		},
+	}
+	var out bytes.Buffer
+	printReport(resp, &out)
+	cases := []string{
+		"SUBJECT        DESCRIPTION  UPTIME   DOWNTIME  OUTAGES  OBSERVED",
+		"heartbeat.api  API          99.50%   3h36m0s   2        720h0m0s",
+		"heartbeat.db   -            100.00%  0s        0        24h0m0s",
+	}
+	for _, want := range cases {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	printReport(statusapi.Report{From: resp.From, To: resp.To}, &out)
+	if !strings.Contains(out.String(), "No subjects observed in this range.") {
+		t.Errorf("expected empty report message, got %q", out.String())
+	}
+}
+
+func TestWriteReportCSV(t *testing.T) {
+	resp := statusapi.Report{
+		From:     time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+		Subjects: []statusapi.SubjectReport{{Subject: "heartbeat.api", Description: "API, public", Uptime: statusapi.Uptime{UptimePercent: 99.5, Downtime: "7m12s", Outages: 1, Observed: "24h0m0s"}}},
+	}
+	var out bytes.Buffer
+	if err := writeReportCSV(resp, &out); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	want := "subject,description,from,to,uptime_percent,downtime_seconds,outages,observed_seconds\n" +
+		"heartbeat.api,\"API, public\",2024-06-01T00:00:00Z,2024-06-02T00:00:00Z,99.5000,432,1,86400\n"
+	if out.String() != want {
+		t.Fatalf("unexpected csv:\n%s", out.String())
+	}
+}
+
+func TestFormatPercent(t *testing.T) {
+	cases := []struct {
+		in   float64
+		want string
+	}{
+		{100, "100.00%"},
+		{99.999, "99.99%"},
+		{99.5, "99.50%"},
+		{0, "0.00%"},
+	}
+	for _, tc := range cases {
+		if got := formatPercent(tc.in); got != tc.want {
+			t.Errorf("formatPercent(%v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
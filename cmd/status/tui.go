@@ -0,0 +1,868 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+	"golang.org/x/term"
+)
+
+// tuiResizePoll is how often the TUI checks the terminal size.
+const tuiResizePoll = 250 * time.Millisecond
+
+type tuiMode int
+
+const (
+	tuiList tuiMode = iota
+	tuiDetail
+	tuiFilter
+	tuiConfirm
+	tuiSilence
+)
+
+// tui is the state of the full-screen "status tui" view. It is only touched
+// from the event loop in runTUI; fetches run in goroutines and hand their
+// results back through updates.
+type tui struct {
+	src      source
+	timeout  time.Duration
+	v        view
+	colorize bool
+
+	width, height int
+	mode          tuiMode
+	resp          statusResponse
+	visible       []subjectState
+	fetchErr      error
+	fetching      bool
+
+	selected string // rowKey under the cursor
+	cursor   int
+	offset   int
+
+	detail     string // rowKey shown in the detail view
+	history    statusapi.History
+	historyErr error
+	scroll     int
+
+	input   string // filter or silence duration being edited
+	message string
+	updates chan func(*tui)
+}
+
+// runTUI implements "status tui": a full-screen, keyboard-driven view of the
+// subjects that refreshes from src until the user quits.
+func runTUI(src source, timeout time.Duration, v view, args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	refresh := fs.Duration("refresh", envDuration("STATUS_TUI_REFRESH", 2*time.Second), "How often to refresh the subject list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *refresh <= 0 {
+		return errors.New("-refresh must be positive")
+	}
+
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("tui needs an interactive terminal")
+	}
+	oldState, err := term.MakeRaw(in)
+	if err != nil {
+		return fmt.Errorf("enter raw mode: %w", err)
+	}
+	defer term.Restore(in, oldState)
+	// alternate screen, hidden cursor
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	t := &tui{
+		src:      src,
+		timeout:  timeout,
+		v:        v,
+		colorize: os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb",
+		updates:  make(chan func(*tui), 4),
+	}
+	t.width, t.height, _ = term.GetSize(out)
+
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+	resize := time.NewTicker(tuiResizePoll)
+	defer resize.Stop()
+
+	t.refresh()
+	dirty := true
+	for {
+		if dirty {
+			t.render(os.Stdout)
+		}
+		dirty = true
+		select {
+		case <-ctx.Done():
+			return nil
+		case key, ok := <-keys:
+			if !ok || t.handleKey(key) {
+				return nil
+			}
+		case update := <-t.updates:
+			update(t)
+		case <-ticker.C:
+			t.refresh()
+		case <-resize.C:
+			width, height, err := term.GetSize(out)
+			dirty = err == nil && (width != t.width || height != t.height)
+			if dirty {
+				t.width, t.height = width, height
+			}
+		}
+	}
+}
+
+// refresh fetches the subject list, unless a fetch is already in flight,
+// and the history of the open subject.
+func (t *tui) refresh() {
+	if t.detail != "" {
+		t.loadHistory(t.detail)
+	}
+	if t.fetching {
+		return
+	}
+	t.fetching = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+		defer cancel()
+		resp, err := t.src.status(ctx)
+		t.updates <- func(t *tui) {
+			t.fetching = false
+			t.fetchErr = err
+			if err == nil {
+				t.resp = resp
+				t.applyView()
+			}
+		}
+	}()
+}
+
+func (t *tui) loadHistory(key string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+		defer cancel()
+		hist, err := t.src.history(ctx, keySubject(key), 0)
+		t.updates <- func(t *tui) {
+			if t.detail == key {
+				t.history, t.historyErr = hist, err
+			}
+		}
+	}()
+}
+
+// applyView recomputes the visible subjects and keeps the cursor on the
+// selected subject, or at the same position when it went away.
+func (t *tui) applyView() {
+	t.visible = t.v.apply(t.resp).Subjects
+	for i, s := range t.visible {
+		if rowKey(s) == t.selected {
+			t.cursor = i
+			return
+		}
+	}
+	t.moveCursor(0)
+}
+
+func (t *tui) moveCursor(delta int) {
+	t.cursor += delta
+	if t.cursor >= len(t.visible) {
+		t.cursor = len(t.visible) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+	t.selected = ""
+	if t.cursor < len(t.visible) {
+		t.selected = rowKey(t.visible[t.cursor])
+	}
+}
+
+// current returns the subject open in the detail view.
+func (t *tui) current() (subjectState, bool) {
+	for _, s := range t.resp.Subjects {
+		if rowKey(s) == t.detail {
+			return s, true
+		}
+	}
+	return subjectState{}, false
+}
+
+// handleKey applies one keystroke and reports whether to quit.
+func (t *tui) handleKey(key string) bool {
+	if key == "ctrl-c" {
+		return true
+	}
+	switch t.mode {
+	case tuiFilter:
+		t.filterKey(key)
+		return false
+	case tuiSilence:
+		t.silenceKey(key)
+		return false
+	case tuiConfirm:
+		t.leavePrompt()
+		if key == "y" || key == "Y" {
+			t.forget(t.target())
+		} else {
+			t.message = "forget cancelled"
+		}
+		return false
+	}
+
+	t.message = ""
+	page := t.rows()
+	if t.mode == tuiDetail {
+		switch key {
+		case "q":
+			return true
+		case "esc", "left", "backspace", "h":
+			t.mode, t.detail = tuiList, ""
+		case "up", "k":
+			t.scrollBy(-1)
+		case "down", "j":
+			t.scrollBy(1)
+		case "pgup":
+			t.scrollBy(-page)
+		case "pgdn", " ":
+			t.scrollBy(page)
+		case "home", "g":
+			t.scroll = 0
+		case "r":
+			t.refresh()
+		case "d":
+			t.confirmForget()
+		case "A":
+			t.ack(t.target())
+		case "z":
+			t.promptSilence()
+		}
+		return false
+	}
+
+	switch key {
+	case "q":
+		return true
+	case "up", "k":
+		t.moveCursor(-1)
+	case "down", "j":
+		t.moveCursor(1)
+	case "pgup":
+		t.moveCursor(-page)
+	case "pgdn", " ":
+		t.moveCursor(page)
+	case "home", "g":
+		t.moveCursor(-len(t.visible))
+	case "end", "G":
+		t.moveCursor(len(t.visible))
+	case "enter", "right", "l":
+		if t.selected != "" {
+			t.mode, t.detail, t.scroll = tuiDetail, t.selected, 0
+			t.history, t.historyErr = statusapi.History{}, nil
+			t.loadHistory(t.detail)
+		}
+	case "/":
+		t.mode, t.input = tuiFilter, t.v.subject
+	case "a":
+		t.v.alerting = !t.v.alerting
+		t.applyView()
+	case "s":
+		t.v.sortBy = sortKeys[(indexOf(sortKeys, t.v.sortBy)+1)%len(sortKeys)]
+		t.applyView()
+	case "S":
+		t.v.reverse = !t.v.reverse
+		t.applyView()
+	case "w":
+		if t.v.format == "wide" {
+			t.v.format = "table"
+		} else {
+			t.v.format = "wide"
+		}
+	case "r":
+		t.refresh()
+	case "d":
+		t.confirmForget()
+	case "A":
+		if t.target() != "" {
+			t.ack(t.target())
+		}
+	case "z":
+		t.promptSilence()
+	}
+	return false
+}
+
+func (t *tui) filterKey(key string) {
+	switch key {
+	case "enter":
+		if _, err := path.Match(t.input, ""); err != nil {
+			t.message = fmt.Sprintf("invalid pattern %q: %v", t.input, err)
+			return
+		}
+		t.v.subject = t.input
+		t.mode = tuiList
+		t.applyView()
+	case "esc":
+		t.mode = tuiList
+	default:
+		t.editInput(key)
+	}
+}
+
+func (t *tui) silenceKey(key string) {
+	switch key {
+	case "enter":
+		d, err := time.ParseDuration(t.input)
+		if err != nil {
+			t.message = fmt.Sprintf("invalid duration %q", t.input)
+			return
+		}
+		t.leavePrompt()
+		t.silence(t.target(), d)
+	case "esc":
+		t.leavePrompt()
+	default:
+		t.editInput(key)
+	}
+}
+
+// editInput applies a keystroke to the text being edited in a prompt.
+func (t *tui) editInput(key string) {
+	switch key {
+	case "backspace":
+		if _, size := utf8.DecodeLastRuneInString(t.input); size > 0 {
+			t.input = t.input[:len(t.input)-size]
+		}
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			t.input += key
+		}
+	}
+}
+
+// leavePrompt returns from a prompt to the view it was opened from.
+func (t *tui) leavePrompt() {
+	t.mode = tuiList
+	if t.detail != "" {
+		t.mode = tuiDetail
+	}
+}
+
+func (t *tui) scrollBy(delta int) {
+	t.scroll += delta
+	if t.scroll < 0 {
+		t.scroll = 0
+	}
+}
+
+// target is the subject a forget, ack or silence applies to: the open
+// subject in the detail view, the one under the cursor otherwise.
+func (t *tui) target() string {
+	if t.detail != "" {
+		return keySubject(t.detail)
+	}
+	return keySubject(t.selected)
+}
+
+func (t *tui) confirmForget() {
+	if t.target() == "" {
+		return
+	}
+	t.mode = tuiConfirm
+}
+
+func (t *tui) promptSilence() {
+	if t.target() == "" {
+		return
+	}
+	t.mode, t.input = tuiSilence, ""
+}
+
+// forget asks the monitor to drop subject and refreshes once it has.
+func (t *tui) forget(subject string) {
+	t.act("forget", "forgetting", subject, func(ctx context.Context) (string, error) {
+		return fmt.Sprintf("forgot %s", subject), t.src.forget(ctx, subject)
+	}, func(t *tui) {
+		if keySubject(t.detail) == subject {
+			t.mode, t.detail = tuiList, ""
+		}
+	})
+}
+
+func (t *tui) ack(subject string) {
+	t.act("ack", "acknowledging", subject, func(ctx context.Context) (string, error) {
+		return fmt.Sprintf("acknowledged %s", subject), t.src.ack(ctx, subject)
+	}, nil)
+}
+
+func (t *tui) silence(subject string, d time.Duration) {
+	t.act("silence", "silencing", subject, func(ctx context.Context) (string, error) {
+		until, err := t.src.silence(ctx, subject, d)
+		if until.IsZero() {
+			return fmt.Sprintf("silence lifted for %s", subject), err
+		}
+		return fmt.Sprintf("silenced %s until %s", subject, until.Local().Format("15:04:05")), err
+	}, nil)
+}
+
+// act runs an admin operation on subject in the background, then reports
+// its outcome, applies done on success and refreshes.
+func (t *tui) act(name, doing, subject string, op func(context.Context) (string, error), done func(*tui)) {
+	t.message = fmt.Sprintf("%s %s...", doing, subject)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+		defer cancel()
+		msg, err := op(ctx)
+		t.updates <- func(t *tui) {
+			if err != nil {
+				t.message = fmt.Sprintf("%s %s: %v", name, subject, err)
+				return
+			}
+			t.message = msg
+			if done != nil {
+				done(t)
+			}
+			t.refresh()
+		}
+	}()
+}
+
+// rows is how many lines fit between the title and the footer.
+func (t *tui) rows() int {
+	if n := t.height - 4; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// render draws a full frame: title, body, message line and key help.
+func (t *tui) render(w io.Writer) {
+	var body []string
+	title := t.title()
+	if t.detail != "" {
+		body = t.detailBody()
+	} else {
+		body = t.listBody()
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	line := func(s string) {
+		buf.WriteString(s)
+		buf.WriteString("\x1b[K\r\n")
+	}
+	line(t.paint(truncate(title, t.width), 1))
+	line("")
+	for _, l := range body {
+		line(l)
+	}
+	for i := len(body); i < t.rows(); i++ {
+		line("")
+	}
+	line(t.footerMessage())
+	buf.WriteString(t.paint(truncate(t.help(), t.width), 2))
+	buf.WriteString("\x1b[K\x1b[J")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (t *tui) title() string {
+	if t.detail != "" {
+		title := "Heartbeat " + keySubject(t.detail)
+		if monitor, _, _ := strings.Cut(t.detail, "\x00"); monitor != "" {
+			title += " on " + monitor
+		}
+		return title
+	}
+	observed := "waiting for status"
+	if !t.resp.ObservedAt.IsZero() {
+		observed = "observed " + t.resp.ObservedAt.Local().Format("15:04:05")
+	}
+	alerting := 0
+	for _, s := range t.resp.Subjects {
+		if s.AlertActive {
+			alerting++
+		}
+	}
+	parts := []string{
+		fmt.Sprintf("Heartbeats  %s  %d subject(s), %d alerting", observed, len(t.resp.Subjects), alerting),
+		"sort " + t.v.sortBy,
+	}
+	if t.v.reverse {
+		parts[1] += " (reversed)"
+	}
+	if t.v.alerting {
+		parts = append(parts, "alerting only")
+	}
+	if t.v.subject != "" {
+		parts = append(parts, "subject "+t.v.subject)
+	}
+	if t.v.host != "" {
+		parts = append(parts, "host "+t.v.host)
+	}
+	return strings.Join(parts, "  |  ")
+}
+
+// listBody renders the table header and the rows that fit, scrolled so the
+// cursor stays visible.
+func (t *tui) listBody() []string {
+	if t.resp.Subjects == nil && t.fetchErr == nil {
+		return []string{"Loading..."}
+	}
+	if len(t.visible) == 0 {
+		if len(t.resp.Subjects) == 0 {
+			return []string{"No heartbeats observed yet."}
+		}
+		return []string{"No subjects match the filter."}
+	}
+
+	table := statusRows(t.visible, t.v.format == "wide")
+	header, rows := table[0], table[1:]
+	page := t.rows() - 1 // below the header
+	if page < 1 {
+		page = 1
+	}
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+page {
+		t.offset = t.cursor - page + 1
+	}
+	if t.offset > len(rows)-page {
+		t.offset = len(rows) - page
+	}
+	if t.offset < 0 {
+		t.offset = 0
+	}
+
+	lines := []string{t.paint(truncate(header, t.width), 1)}
+	for i := t.offset; i < len(rows) && i < t.offset+page; i++ {
+		row := truncate(rows[i], t.width)
+		switch {
+		case i == t.cursor:
+			row = "\x1b[7m" + pad(row, t.width) + "\x1b[0m"
+		case t.colorize:
+			row = colorizeStatuses(row)
+		}
+		lines = append(lines, row)
+	}
+	return lines
+}
+
+// detailBody renders the subject's fields followed by its history, scrolled
+// by the user.
+func (t *tui) detailBody() []string {
+	s, ok := t.current()
+	if !ok {
+		return []string{"Subject is no longer tracked by the monitor."}
+	}
+
+	lines := subjectDetails(s, t.resp.ObservedAt)
+	lines = append(lines, "")
+	historyStart := len(lines)
+	switch {
+	case t.historyErr != nil:
+		lines = append(lines, fmt.Sprintf("History unavailable: %v", t.historyErr))
+	case t.history.Subject == "":
+		lines = append(lines, "Loading history...")
+	default:
+		// newest first so the latest activity is on screen
+		entries := t.history.Entries
+		reversed := make([]statusapi.HistoryEntry, len(entries))
+		for i, e := range entries {
+			reversed[len(entries)-1-i] = e
+		}
+		var buf bytes.Buffer
+		printHistory(statusapi.History{Subject: t.history.Subject, Entries: reversed}, &buf)
+		lines = append(lines, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")...)
+	}
+
+	if max := len(lines) - t.rows(); t.scroll > max {
+		t.scroll = max
+	}
+	if t.scroll < 0 {
+		t.scroll = 0
+	}
+	status, _ := summarizeSubject(s)
+	var out []string
+	for i := t.scroll; i < len(lines) && i < t.scroll+t.rows(); i++ {
+		l := truncate(lines[i], t.width)
+		switch {
+		case !t.colorize:
+		case i == 0:
+			l = strings.Replace(l, status, colorStatus(status), 1)
+		case i >= historyStart:
+			l = colorizeHistory(l)
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// subjectDetails lists everything the status API reports about one subject,
+// status first.
+func subjectDetails(s subjectState, now time.Time) []string {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	status, details := summarizeSubject(s)
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	field := func(name, value string) {
+		fmt.Fprintf(tw, "%s:\t%s\n", name, value)
+	}
+	field("Status", status+"  "+details)
+	if s.Acknowledged {
+		field("Acknowledged", "repeat alerts paused until the subject resolves")
+	}
+	if s.SilencedUntil != nil {
+		field("Silenced", "until "+s.SilencedUntil.Format(time.RFC3339))
+	}
+	field("Description", fallback(s.Description, "-"))
+	field("Host", fallback(s.Host, "-"))
+	lastSeen := "-"
+	if !s.LastSeen.IsZero() {
+		lastSeen = fmt.Sprintf("%s (%s ago)", s.LastSeen.Format(time.RFC3339), now.Sub(s.LastSeen).Round(time.Second))
+	}
+	field("Last seen", lastSeen)
+	skew := fallback(s.Skew, "-")
+	if s.SkewExceeded {
+		skew += " (exceeds limit)"
+	}
+	field("Skew", skew)
+	window := fmt.Sprintf("interval %s, window %s", fallback(s.Interval, "-"), fallback(s.AllowedWindow, "-"))
+	if s.Grace != nil {
+		window += ", grace " + *s.Grace
+	}
+	field("Schedule", window)
+	if s.Sequence > 0 || s.BootID != "" {
+		seq := fmt.Sprintf("seq %d, boot %s, %d restart(s), %d lost beat(s)", s.Sequence, fallback(s.BootID, "-"), s.Restarts, s.LostBeats)
+		if !s.LastRestart.IsZero() {
+			seq += ", last restart " + s.LastRestart.Format(time.RFC3339)
+		}
+		field("Publisher", seq)
+	}
+	if c := s.Cadence; c != nil {
+		cad := fmt.Sprintf("mean %s, p95 %s, max %s, jitter %s over %d gap(s)", c.Mean, c.P95, c.Max, c.Jitter, c.Samples)
+		if c.DriftExceeded {
+			cad += ", drifting"
+		}
+		field("Cadence", cad)
+	}
+	for _, u := range s.Uptime {
+		field("Uptime "+u.Window, fmt.Sprintf("%s, %d outage(s), %s down", formatPercent(u.UptimePercent), u.Outages, u.Downtime))
+	}
+	for _, h := range s.Hosts {
+		state := "ok"
+		if h.AlertActive {
+			state = "alerting"
+		} else if h.Missing {
+			state = "late"
+		}
+		field("Host "+h.Host, fmt.Sprintf("%s, last seen %s", state, h.LastSeen.Format(time.RFC3339)))
+	}
+	_ = tw.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func (t *tui) footerMessage() string {
+	switch t.mode {
+	case tuiFilter:
+		prompt := "Filter subjects (glob, empty for all): " + t.input + "_"
+		if t.message != "" {
+			prompt += "  " + t.message
+		}
+		return truncate(prompt, t.width)
+	case tuiConfirm:
+		return truncate(fmt.Sprintf("Forget %s? The monitor drops its state until it beats again. [y/N]", t.target()), t.width)
+	case tuiSilence:
+		prompt := fmt.Sprintf("Silence %s for (e.g. 30m, 2h; 0 lifts it): %s_", t.target(), t.input)
+		if t.message != "" {
+			prompt += "  " + t.message
+		}
+		return truncate(prompt, t.width)
+	}
+	msg := t.message
+	if msg == "" && t.fetchErr != nil {
+		msg = "fetch failed: " + t.fetchErr.Error()
+	}
+	if msg == "" && len(t.resp.Unreachable) > 0 {
+		var parts []string
+		for _, u := range t.resp.Unreachable {
+			parts = append(parts, fmt.Sprintf("%s (%s)", u.Monitor, u.Error))
+		}
+		return t.paint(truncate("unreachable: "+strings.Join(parts, ", "), t.width), 31)
+	}
+	if msg == "" {
+		return ""
+	}
+	code := 33
+	if t.fetchErr != nil && msg != t.message {
+		code = 31
+	}
+	return t.paint(truncate(msg, t.width), code)
+}
+
+func (t *tui) help() string {
+	switch t.mode {
+	case tuiFilter:
+		return "enter apply  esc cancel"
+	case tuiConfirm:
+		return "y forget  any other key cancels"
+	case tuiSilence:
+		return "enter silence  esc cancel"
+	case tuiDetail:
+		return "up/down scroll  esc back  r refresh  A ack  z silence  d forget  q quit"
+	}
+	return "up/down move  enter details  / filter  a alerting  s sort  S reverse  w wide  r refresh  A ack  z silence  d forget  q quit"
+}
+
+func (t *tui) paint(s string, code int) string {
+	if !t.colorize || s == "" {
+		return s
+	}
+	return applyColor(s, true, code)
+}
+
+// truncate cuts s to width runes.
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width])
+}
+
+func pad(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// rowKey identifies a row of the table: the subject, and the monitor that
+// reported it when several are queried.
+func rowKey(s subjectState) string {
+	return s.Monitor + "\x00" + s.Subject
+}
+
+func keySubject(key string) string {
+	_, subject, _ := strings.Cut(key, "\x00")
+	return subject
+}
+
+func indexOf(list []string, v string) int {
+	for i, item := range list {
+		if item == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// escapeKeys maps the terminal escape sequences the TUI understands, minus
+// the leading ESC, to key names.
+var escapeKeys = map[string]string{
+	"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+	"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+	"[H": "home", "[F": "end", "OH": "home", "OF": "end",
+	"[1~": "home", "[4~": "end", "[5~": "pgup", "[6~": "pgdn",
+}
+
+// readKeys decodes keystrokes from r until it fails.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// parseKeys splits one read from a raw terminal into key names: "up",
+// "enter", "esc", "ctrl-c" and so on, or the typed character itself.
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			seq := escapeSequence(b[1:])
+			if key, ok := escapeKeys[seq]; ok {
+				keys = append(keys, key)
+				b = b[1+len(seq):]
+				continue
+			}
+			if seq != "" {
+				// unknown sequence, e.g. a function key
+				b = b[1+len(seq):]
+				continue
+			}
+			keys = append(keys, "esc")
+			b = b[1:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// escapeSequence returns the CSI or SS3 sequence at the start of b, without
+// its ESC, or "" when b does not start one.
+func escapeSequence(b []byte) string {
+	if len(b) >= 2 && b[0] == 'O' {
+		return string(b[:2])
+	}
+	if len(b) < 2 || b[0] != '[' {
+		return ""
+	}
+	for i := 1; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return string(b[:i+1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// fakeSource serves a fixed status and records admin operations.
+type fakeSource struct {
+	resp     statusResponse
+	calls    []string
+	adminErr error
+}
+
+func (f *fakeSource) status(context.Context) (statusResponse, error) {
+	return f.resp, nil
+}
+
+func (f *fakeSource) history(_ context.Context, subject string, _ int) (statusapi.History, error) {
+	return statusapi.History{Subject: subject, Entries: []statusapi.HistoryEntry{{Kind: "beat", At: outputSeen}}}, nil
+}
+
+func (f *fakeSource) report(context.Context, statusapi.ReportQuery) (statusapi.Report, error) {
+	return statusapi.Report{}, nil
+}
+
+func (f *fakeSource) silence(_ context.Context, subject string, d time.Duration) (time.Time, error) {
+	f.calls = append(f.calls, "silence "+subject+" "+d.String())
+	if d == 0 {
+		return time.Time{}, f.adminErr
+	}
+	return outputSeen.Add(d), f.adminErr
+}
+
+func (f *fakeSource) ack(_ context.Context, subject string) error {
+	f.calls = append(f.calls, "ack "+subject)
+	return f.adminErr
+}
+
+func (f *fakeSource) forget(_ context.Context, subject string) error {
+	f.calls = append(f.calls, "forget "+subject)
+	return f.adminErr
+}
+
+func newTestTUI(src *fakeSource) *tui {
+	t := &tui{
+		src:     src,
+		timeout: time.Second,
+		v:       view{format: "table", sortBy: "subject"},
+		width:   200,
+		height:  20,
+		resp:    src.resp,
+		updates: make(chan func(*tui), 4),
+	}
+	t.applyView()
+	return t
+}
+
+// drain applies the next background update, as the event loop would.
+func drain(tb testing.TB, t *tui) {
+	tb.Helper()
+	select {
+	case update := <-t.updates:
+		update(t)
+	case <-time.After(time.Second):
+		tb.Fatalf("no update from the background fetch")
+	}
+}
+
+// settle applies background updates until none arrive for a while, so
+// fetches started by earlier updates are applied too.
+func settle(t *tui) {
+	for {
+		select {
+		case update := <-t.updates:
+			update(t)
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func press(t *tui, keys ...string) bool {
+	quit := false
+	for _, key := range keys {
+		quit = t.handleKey(key)
+	}
+	return quit
+}
+
+func TestTUIListKeys(t *testing.T) {
+	cases := []struct {
+		name     string
+		keys     []string
+		selected string
+		visible  []string
+	}{
+		{"initial", nil, "api", []string{"api", "cron", "db.backup", "web", "worker"}},
+		{"down", []string{"down", "j"}, "db.backup", nil},
+		{"up past the top", []string{"k", "up"}, "api", nil},
+		{"end", []string{"G"}, "worker", nil},
+		{"home", []string{"G", "g"}, "api", nil},
+		{"page down stops at the end", []string{"pgdn"}, "worker", nil},
+		{"alerting only", []string{"a"}, "db.backup", []string{"db.backup"}},
+		{"alerting toggled back keeps the cursor", []string{"a", "a"}, "db.backup", []string{"api", "cron", "db.backup", "web", "worker"}},
+		{"sort by status keeps the cursor on its subject", []string{"s"}, "api", []string{"db.backup", "api", "cron", "web", "worker"}},
+		{"reverse", []string{"S"}, "api", []string{"worker", "web", "db.backup", "cron", "api"}},
+		{"filter", []string{"/", "w", "*", "enter"}, "web", []string{"web", "worker"}},
+		{"filter edited", []string{"/", "w", "x", "backspace", "*", "enter"}, "web", []string{"web", "worker"}},
+		{"filter cancelled", []string{"/", "w", "*", "esc"}, "api", []string{"api", "cron", "db.backup", "web", "worker"}},
+		{"filter cleared", []string{"/", "w", "*", "enter", "/", "backspace", "backspace", "enter"}, "web", []string{"api", "cron", "db.backup", "web", "worker"}},
+		{"filter without match", []string{"/", "n", "o", "enter"}, "", []string{}},
+	}
+	for _, tc := range cases {
+		tt := newTestTUI(&fakeSource{resp: outputSubjects()})
+		if press(tt, tc.keys...) {
+			t.Errorf("%s: unexpected quit", tc.name)
+		}
+		if got := keySubject(tt.selected); got != tc.selected {
+			t.Errorf("%s: selected %q, want %q", tc.name, got, tc.selected)
+		}
+		if tc.visible != nil {
+			got := make([]string, 0, len(tt.visible))
+			for _, s := range tt.visible {
+				got = append(got, s.Subject)
+			}
+			if !reflect.DeepEqual(got, tc.visible) {
+				t.Errorf("%s: visible %v, want %v", tc.name, got, tc.visible)
+			}
+		}
+		if tt.mode != tuiList {
+			t.Errorf("%s: mode %d, want the list", tc.name, tt.mode)
+		}
+	}
+}
+
+func TestTUIModes(t *testing.T) {
+	tt := newTestTUI(&fakeSource{resp: outputSubjects()})
+
+	press(tt, "w")
+	if tt.v.format != "wide" {
+		t.Fatalf("expected w to switch to wide, got %q", tt.v.format)
+	}
+	press(tt, "/", "[", "enter")
+	if tt.mode != tuiFilter || !strings.Contains(tt.message, "invalid pattern") {
+		t.Fatalf("expected an invalid pattern to keep the filter open, mode %d message %q", tt.mode, tt.message)
+	}
+	press(tt, "esc")
+
+	press(tt, "enter")
+	if tt.mode != tuiDetail || keySubject(tt.detail) != "api" {
+		t.Fatalf("expected the detail view for api, mode %d detail %q", tt.mode, tt.detail)
+	}
+	drain(t, tt)
+	if tt.history.Subject != "api" || len(tt.history.Entries) != 1 {
+		t.Fatalf("expected api history to load, got %+v", tt.history)
+	}
+	press(tt, "down", "down", "up")
+	if tt.scroll != 1 {
+		t.Fatalf("expected scroll 1, got %d", tt.scroll)
+	}
+	press(tt, "esc")
+	if tt.mode != tuiList || tt.detail != "" {
+		t.Fatalf("expected esc to return to the list, mode %d detail %q", tt.mode, tt.detail)
+	}
+
+	if !press(tt, "/", "ctrl-c") {
+		t.Fatalf("expected ctrl-c to quit from the filter prompt")
+	}
+	if !press(newTestTUI(&fakeSource{}), "q") {
+		t.Fatalf("expected q to quit")
+	}
+}
+
+func TestTUIActions(t *testing.T) {
+	cases := []struct {
+		name    string
+		keys    []string
+		err     error
+		calls   []string
+		message string
+		mode    tuiMode
+	}{
+		{name: "forget confirmed", keys: []string{"d", "y"}, calls: []string{"forget api"}, message: "forgot api"},
+		{name: "forget cancelled", keys: []string{"d", "n"}, message: "forget cancelled"},
+		{name: "forget from details", keys: []string{"j", "enter", "d", "Y"}, calls: []string{"forget cron"}, message: "forgot cron"},
+		{name: "ack", keys: []string{"G", "A"}, calls: []string{"ack worker"}, message: "acknowledged worker"},
+		{name: "ack failure", keys: []string{"A"}, err: errors.New("subject is not alerting"), calls: []string{"ack api"}, message: "ack api: subject is not alerting"},
+		{name: "silence", keys: []string{"z", "2", "h", "enter"}, calls: []string{"silence api 2h0m0s"}, message: "silenced api until "},
+		{name: "silence lifted", keys: []string{"z", "0", "enter"}, calls: []string{"silence api 0s"}, message: "silence lifted for api"},
+		{name: "silence cancelled", keys: []string{"z", "1", "esc"}},
+		{name: "silence with a bad duration", keys: []string{"z", "s", "o", "o", "n", "enter"}, message: `invalid duration "soon"`, mode: tuiSilence},
+	}
+	for _, tc := range cases {
+		src := &fakeSource{resp: outputSubjects(), adminErr: tc.err}
+		tt := newTestTUI(src)
+		press(tt, tc.keys...)
+		settle(tt)
+		if !reflect.DeepEqual(src.calls, tc.calls) {
+			t.Errorf("%s: calls %v, want %v", tc.name, src.calls, tc.calls)
+		}
+		if !strings.HasPrefix(tt.message, tc.message) || (tc.message == "" && tt.message != "") {
+			t.Errorf("%s: message %q, want %q", tc.name, tt.message, tc.message)
+		}
+		if tt.mode != tc.mode {
+			t.Errorf("%s: mode %d, want %d", tc.name, tt.mode, tc.mode)
+		}
+	}
+}
+
+func TestTUIActionsAcrossMonitors(t *testing.T) {
+	resp := statusResponse{Subjects: []subjectState{
+		{Monitor: "eu", SubjectStatus: statusapi.SubjectStatus{Subject: "api", AlertActive: true}},
+		{Monitor: "us", SubjectStatus: statusapi.SubjectStatus{Subject: "api"}},
+		{Monitor: "eu", SubjectStatus: statusapi.SubjectStatus{Subject: "db"}},
+	}}
+	src := &fakeSource{resp: resp}
+	tt := newTestTUI(src)
+	press(tt, "j", "A")
+	settle(tt)
+	press(tt, "j", "enter")
+	settle(tt)
+	if tt.detail != "eu\x00db" {
+		t.Fatalf("expected the eu view of db, got %q", tt.detail)
+	}
+	press(tt, "d", "y")
+	settle(tt)
+	if want := []string{"ack api", "forget db"}; !reflect.DeepEqual(src.calls, want) {
+		t.Fatalf("calls %v, want %v", src.calls, want)
+	}
+	if tt.mode != tuiList || tt.detail != "" {
+		t.Fatalf("expected forgetting the open subject to close it, mode %d detail %q", tt.mode, tt.detail)
+	}
+}
+
+func TestTUIRenderFooter(t *testing.T) {
+	tt := newTestTUI(&fakeSource{resp: outputSubjects()})
+	press(tt, "z", "3", "0", "m")
+	var buf bytes.Buffer
+	tt.render(&buf)
+	if !strings.Contains(buf.String(), "Silence api for (e.g. 30m, 2h; 0 lifts it): 30m_") {
+		t.Fatalf("expected the silence prompt, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "enter silence  esc cancel") {
+		t.Fatalf("expected the silence help, got %q", buf.String())
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"q", []string{"q"}},
+		{"\x1b[A\x1b[B", []string{"up", "down"}},
+		{"\x1bOC\x1b[5~", []string{"right", "pgup"}},
+		{"\x1b", []string{"esc"}},
+		{"\x1b[15~x", []string{"x"}},
+		{"\r\x7f\x03", []string{"enter", "backspace", "ctrl-c"}},
+		{"\x01é", []string{"é"}},
+	}
+	for _, tc := range cases {
+		if got := parseKeys([]byte(tc.in)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseKeys(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
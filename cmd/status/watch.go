@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// watchRetry is how long -watch waits before reconnecting to the monitor.
+const watchRetry = 2 * time.Second
+
+// watchRecent is how many recent notifications -watch shows below the table.
+const watchRecent = 5
+
+// watchState is the table kept up to date from the event stream.
+type watchState struct {
+	observedAt time.Time
+	subjects   map[string]subjectState
+	recent     []string
+	err        error
+}
+
+// apply folds one event from the stream into the state.
+func (s *watchState) apply(kind string, data []byte) error {
+	switch kind {
+	case "snapshot":
+		var snapshot statusapi.Status
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("decode snapshot: %w", err)
+		}
+		if err := statusapi.CheckVersion(snapshot.Version); err != nil {
+			return err
+		}
+		s.observedAt = snapshot.ObservedAt
+		s.subjects = make(map[string]subjectState, len(snapshot.Subjects))
+		for _, subj := range snapshot.Subjects {
+			s.subjects[subj.Subject] = subjectState{SubjectStatus: subj}
+		}
+	case "update":
+		var update statusapi.Update
+		if err := json.Unmarshal(data, &update); err != nil {
+			return fmt.Errorf("decode update: %w", err)
+		}
+		if s.subjects == nil {
+			s.subjects = make(map[string]subjectState)
+		}
+		s.observedAt = update.ObservedAt
+		for _, subj := range update.Subjects {
+			s.subjects[subj.Subject] = subjectState{SubjectStatus: subj}
+		}
+		for _, name := range update.Removed {
+			delete(s.subjects, name)
+		}
+	case "alert", "resolved", "expired", "notice":
+		var evt statusapi.Event
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return fmt.Errorf("decode %s event: %w", kind, err)
+		}
+		s.recent = append(s.recent, describeEvent(evt))
+		if len(s.recent) > watchRecent {
+			s.recent = s.recent[len(s.recent)-watchRecent:]
+		}
+	}
+	return nil
+}
+
+func (s *watchState) status() statusResponse {
+	resp := statusResponse{ObservedAt: s.observedAt}
+	for _, subj := range s.subjects {
+		resp.Subjects = append(resp.Subjects, subj)
+	}
+	sort.Slice(resp.Subjects, func(i, j int) bool {
+		return resp.Subjects[i].Subject < resp.Subjects[j].Subject
+	})
+	return resp
+}
+
+func describeEvent(evt statusapi.Event) string {
+	name := fallback(evt.Description, evt.Subject)
+	at := evt.At.Format(time.RFC3339)
+	switch evt.Kind {
+	case "alert":
+		return fmt.Sprintf("%s  ALERT     %s missed %s", at, name, evt.MissFor)
+	case "resolved":
+		return fmt.Sprintf("%s  RESOLVED  %s", at, name)
+	case "expired":
+		return fmt.Sprintf("%s  EXPIRED   %s", at, name)
+	default:
+		return fmt.Sprintf("%s  NOTICE    %s: %s", at, name, evt.Reason)
+	}
+}
+
+// watch streams the monitor's /events endpoint and redraws the status table
+// on every change until ctx is cancelled, reconnecting when the stream
+// drops.
+func watch(ctx context.Context, client *statusapi.Client, v view, w io.Writer) error {
+	state := &watchState{}
+	for {
+		err := stream(ctx, client, state, func() { redraw(state, v, w) })
+		if ctx.Err() != nil {
+			return nil
+		}
+		state.err = err
+		redraw(state, v, w)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+func stream(ctx context.Context, client *statusapi.Client, state *watchState, onChange func()) error {
+	events, err := client.Events(ctx)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	r := bufio.NewReader(events)
+	for {
+		kind, data, err := readEvent(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("event stream closed by monitor")
+			}
+			return fmt.Errorf("read events: %w", err)
+		}
+		if err := state.apply(kind, data); err != nil {
+			return err
+		}
+		state.err = nil
+		onChange()
+	}
+}
+
+// readEvent reads the next Server-Sent Event from r, skipping comments.
+func readEvent(r *bufio.Reader) (string, []byte, error) {
+	kind := "message"
+	var data [][]byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0:
+			if len(data) > 0 {
+				return kind, bytes.Join(data, []byte("\n")), nil
+			}
+			kind = "message"
+		case line[0] == ':':
+		case bytes.HasPrefix(line, []byte("event:")):
+			kind = strings.TrimSpace(string(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data = append(data, bytes.TrimPrefix(line[len("data:"):], []byte(" ")))
+		}
+	}
+}
+
+// redraw repaints the table, clearing the screen first when writing to a
+// terminal.
+func redraw(state *watchState, v view, w io.Writer) {
+	var buf bytes.Buffer
+	if isTerminal(w) {
+		buf.WriteString("\x1b[H\x1b[2J")
+	} else {
+		buf.WriteString("\n")
+	}
+	if state.subjects != nil {
+		writeStatus(v.apply(state.status()), &buf, shouldColor(w), v.format == "wide")
+	}
+	if len(state.recent) > 0 {
+		fmt.Fprintln(&buf, "\nRecent events:")
+		for _, line := range state.recent {
+			fmt.Fprintf(&buf, "  %s\n", line)
+		}
+	}
+	if state.err != nil {
+		fmt.Fprintf(&buf, "\nDisconnected: %v (retrying every %s)\n", state.err, watchRetry)
+	}
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadEvent(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		wantKind string
+		wantData string
+	}{
+		{"named event", "event: update\ndata: {}\n\n", "update", "{}"},
+		{"default kind", "data: hello\n\n", "message", "hello"},
+		{"comments skipped", ": keepalive\n\nevent: alert\ndata: {\"a\":1}\n\n", "alert", `{"a":1}`},
+		{"multi-line data", "event: notice\ndata: one\ndata: two\n\n", "notice", "one\ntwo"},
+		{"crlf line endings", "event: snapshot\r\ndata:{}\r\n\r\n", "snapshot", "{}"},
+		{"kind without data is dropped", "event: update\n\ndata: next\n\n", "message", "next"},
+	}
+	for _, tc := range cases {
+		kind, data, err := readEvent(bufio.NewReader(strings.NewReader(tc.input)))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if kind != tc.wantKind || string(data) != tc.wantData {
+			t.Errorf("%s: expected %q %q, got %q %q", tc.name, tc.wantKind, tc.wantData, kind, data)
+		}
+	}
+
+	if _, _, err := readEvent(bufio.NewReader(strings.NewReader("event: update\ndata: {}"))); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF for a truncated event, got %v", err)
+	}
+}
+
+func TestWatchStateApply(t *testing.T) {
+	state := &watchState{}
+	steps := []struct {
+		kind     string
+		data     string
+		subjects []string
+		lastSeen map[string]string
+		recent   int
+		wantErr  bool
+	}{
+		{
+			kind:     "update",
+			data:     `{"observed_at":"2024-01-01T00:00:00Z","subjects":[{"subject":"early"}]}`,
+			subjects: []string{"early"},
+		},
+		{
+			kind:     "snapshot",
+			data:     `{"observed_at":"2024-01-01T00:00:01Z","subjects":[{"subject":"a","last_seen":"2024-01-01T00:00:00Z"},{"subject":"b"}]}`,
+			subjects: []string{"a", "b"},
+		},
+		{
+			kind:     "update",
+			data:     `{"observed_at":"2024-01-01T00:00:02Z","subjects":[{"subject":"a","last_seen":"2024-01-01T00:00:02Z"},{"subject":"c"}],"removed":["b"]}`,
+			subjects: []string{"a", "c"},
+			lastSeen: map[string]string{"a": "2024-01-01T00:00:02Z"},
+		},
+		{
+			kind:     "alert",
+			data:     `{"kind":"alert","subject":"c","miss_for":"1m0s","at":"2024-01-01T00:01:00Z"}`,
+			subjects: []string{"a", "c"},
+			recent:   1,
+		},
+		{
+			kind:     "keepalive-unknown",
+			data:     `{}`,
+			subjects: []string{"a", "c"},
+			recent:   1,
+		},
+		{
+			kind:    "update",
+			data:    `{not json`,
+			wantErr: true,
+		},
+	}
+	for i, step := range steps {
+		err := state.apply(step.kind, []byte(step.data))
+		if step.wantErr {
+			if err == nil {
+				t.Errorf("step %d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("step %d: unexpected error: %v", i, err)
+			continue
+		}
+		var got []string
+		for _, subj := range state.status().Subjects {
+			got = append(got, subj.Subject)
+		}
+		if strings.Join(got, ",") != strings.Join(step.subjects, ",") {
+			t.Errorf("step %d: expected subjects %v, got %v", i, step.subjects, got)
+		}
+		for subject, want := range step.lastSeen {
+			at, _ := time.Parse(time.RFC3339, want)
+			if !state.subjects[subject].LastSeen.Equal(at) {
+				t.Errorf("step %d: expected %s last seen %s, got %s", i, subject, want, state.subjects[subject].LastSeen)
+			}
+		}
+		if len(state.recent) != step.recent {
+			t.Errorf("step %d: expected %d recent events, got %v", i, step.recent, state.recent)
+		}
+	}
+}
+
+func TestWatchStateKeepsRecentEvents(t *testing.T) {
+	state := &watchState{}
+	for i := 0; i < watchRecent+3; i++ {
+		data := `{"kind":"notice","subject":"svc","reason":"r` + string(rune('0'+i)) + `"}`
+		if err := state.apply("notice", []byte(data)); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if len(state.recent) != watchRecent {
+		t.Fatalf("expected %d recent events, got %d", watchRecent, len(state.recent))
+	}
+	if !strings.HasSuffix(state.recent[watchRecent-1], "svc: r7") {
+		t.Fatalf("expected the newest event last, got %q", state.recent[watchRecent-1])
+	}
+}
+
+func TestEventsURL(t *testing.T) {
+	cases := []struct {
+		status string
+		want   string
+	}{
+		{"http://127.0.0.1:8080/", "http://127.0.0.1:8080/events"},
+		{"http://127.0.0.1:8080", "http://127.0.0.1:8080/events"},
+		{"https://mon.example/heartbeat/", "https://mon.example/heartbeat/events"},
+	}
+	for _, tc := range cases {
+		if got := testHTTPSource(t, tc.status).client.URL("events"); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.status, tc.want, got)
+		}
+	}
+}
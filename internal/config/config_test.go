@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	Name     string   `yaml:"name"`
+	Interval Duration `yaml:"interval"`
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestLoadYAMLAndJSON(t *testing.T) {
+	for name, content := range map[string]string{
+		"c.yaml": "name: api\ninterval: 15s\n",
+		"c.json": `{"name": "api", "interval": "15s"}`,
+	} {
+		var got sample
+		if err := Load(writeFile(t, name, content), &got); err != nil {
+			t.Fatalf("%s: load: %v", name, err)
+		}
+		if got.Name != "api" || time.Duration(got.Interval) != 15*time.Second {
+			t.Fatalf("%s: unexpected config %+v", name, got)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFieldsAndBadDurations(t *testing.T) {
+	var got sample
+	if err := Load(writeFile(t, "c.yaml", "nmae: api\n"), &got); err == nil {
+		t.Fatalf("expected unknown field to be rejected")
+	}
+	if err := Load(writeFile(t, "c.yaml", "interval: soon\n"), &got); err == nil {
+		t.Fatalf("expected invalid duration to be rejected")
+	}
+}
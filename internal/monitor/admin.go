@@ -0,0 +1,250 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// ErrUnknownSubject is returned when an admin operation targets a subject
+// the monitor is not tracking.
+var ErrUnknownSubject = errors.New("unknown subject")
+
+// Forget drops a subject from the in-memory cache and purges its last-seen
+// message from the prime stream, if one is configured.
+func (m *Monitor) Forget(ctx context.Context, subject string) error {
+	m.mu.Lock()
+	s, ok := m.state[subject]
+	if ok {
+		m.unschedule(s)
+		delete(m.state, subject)
+		delete(m.uptime, subject)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownSubject
+	}
+	m.logger.Info("heartbeat forgotten", "subject", subject)
+	m.events.touch()
+	m.purgeSubject(s.natsSubject)
+	m.forgetHistory(subject)
+	return nil
+}
+
+func (m *Monitor) purgeSubject(subject string) {
+	stream := m.config().PrimeStream
+	if stream == "" || m.nc == nil || subject == "" {
+		return
+	}
+	js, err := m.nc.JetStream()
+	if err != nil {
+		m.logger.Warn("purge subject failed", "subject", subject, "stream", stream, "err", err)
+		return
+	}
+	if err := js.PurgeStream(stream, &nats.StreamPurgeRequest{Subject: subject}); err != nil {
+		m.logger.Warn("purge subject failed", "subject", subject, "stream", stream, "err", err)
+		return
+	}
+	m.logger.Debug("purged subject from prime stream", "subject", subject, "stream", stream)
+}
+
+func (m *Monitor) subscribeAdmin(ctx context.Context) (*nats.Subscription, error) {
+	prefix := strings.TrimSuffix(m.config().AdminSubject, ".") + "."
+	sub, err := m.nc.Subscribe(prefix+">", func(msg *nats.Msg) {
+		var resp statusapi.AdminResult
+		if err := m.authorizeRequest(msg.Header.Get("Authorization"), RoleAdmin); err != nil {
+			resp = statusapi.AdminResult{Error: err.Error()}
+		} else {
+			resp = m.adminOp(ctx, strings.TrimPrefix(msg.Subject, prefix), msg.Data)
+		}
+		data, _ := json.Marshal(resp)
+		if err := msg.Respond(data); err != nil {
+			m.logger.Warn("admin respond failed", "subject", msg.Subject, "err", err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.logger.Info("admin subscribed", "subject", prefix+">")
+	return sub, nil
+}
+
+// adminOp runs the admin operation op with the request body data.
+func (m *Monitor) adminOp(ctx context.Context, op string, data []byte) statusapi.AdminResult {
+	switch op {
+	case "forget":
+		target := strings.TrimSpace(string(data))
+		if target == "" {
+			return statusapi.AdminResult{Error: "subject is required"}
+		}
+		if err := m.Forget(ctx, target); err != nil {
+			return statusapi.AdminResult{Subject: target, Error: err.Error()}
+		}
+		return statusapi.AdminResult{Subject: target, OK: true}
+	case "silence":
+		return m.silenceResponse(parseSilence(data))
+	case "ack":
+		return m.ackResponse(strings.TrimSpace(string(data)))
+	case "reload":
+		return m.reloadResponse()
+	}
+	return statusapi.AdminResult{Error: fmt.Sprintf("unknown admin operation %q", op)}
+}
+
+func (m *Monitor) reloadResponse() statusapi.AdminResult {
+	changes, err := m.TriggerReload()
+	if err != nil {
+		return statusapi.AdminResult{Error: err.Error()}
+	}
+	return statusapi.AdminResult{OK: true, Changes: changes}
+}
+
+// silenceResponse applies a parsed silence request.
+func (m *Monitor) silenceResponse(subject string, d time.Duration, err error) statusapi.AdminResult {
+	if err != nil {
+		return statusapi.AdminResult{Subject: subject, Error: err.Error()}
+	}
+	until, err := m.Silence(subject, d)
+	if err != nil {
+		return statusapi.AdminResult{Subject: subject, Error: err.Error()}
+	}
+	resp := statusapi.AdminResult{Subject: subject, OK: true}
+	if !until.IsZero() {
+		resp.SilencedUntil = &until
+	}
+	return resp
+}
+
+func (m *Monitor) ackResponse(subject string) statusapi.AdminResult {
+	if subject == "" {
+		return statusapi.AdminResult{Error: "subject is required"}
+	}
+	if err := m.Ack(subject); err != nil {
+		return statusapi.AdminResult{Subject: subject, Error: err.Error()}
+	}
+	return statusapi.AdminResult{Subject: subject, OK: true}
+}
+
+// adminStatus maps an admin operation error to an HTTP status code.
+func adminStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownSubject):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotAlerting):
+		return http.StatusConflict
+	case errors.Is(err, errAuthRequired):
+		return http.StatusUnauthorized
+	case errors.Is(err, errAdminRequired):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (m *Monitor) subjectsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, op := splitSubjectPath(r.URL.Path)
+		if subject == "" {
+			http.NotFound(w, r)
+			return
+		}
+		switch op {
+		case "history":
+			m.historyHandler(w, r, subject)
+			return
+		case "silence", "ack":
+			m.subjectOpHandler(w, r, subject, op)
+			return
+		}
+		if r.Method == http.MethodGet {
+			info, ok := m.subjectInfo(subject)
+			if !ok {
+				m.writeJSON(w, http.StatusNotFound, statusapi.AdminResult{Subject: subject, Error: ErrUnknownSubject.Error()})
+				return
+			}
+			m.writeJSON(w, http.StatusOK, info)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := http.StatusOK
+		resp := statusapi.AdminResult{Subject: subject, OK: true}
+		if err := m.Forget(r.Context(), subject); err != nil {
+			status = adminStatus(err)
+			resp = statusapi.AdminResult{Subject: subject, Error: err.Error()}
+		}
+		m.writeJSON(w, status, resp)
+	})
+}
+
+// subjectOpHandler serves POST /subjects/<subject>/silence?for=<duration>
+// and POST /subjects/<subject>/ack.
+func (m *Monitor) subjectOpHandler(w http.ResponseWriter, r *http.Request, subject, op string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	resp := statusapi.AdminResult{Subject: subject, OK: true}
+	switch op {
+	case "silence":
+		var d time.Duration
+		if v := r.URL.Query().Get("for"); v != "" {
+			if d, err = time.ParseDuration(v); err != nil {
+				m.writeJSON(w, http.StatusBadRequest, statusapi.AdminResult{Subject: subject, Error: "invalid duration: " + err.Error()})
+				return
+			}
+		}
+		var until time.Time
+		if until, err = m.Silence(subject, d); !until.IsZero() {
+			resp.SilencedUntil = &until
+		}
+	case "ack":
+		err = m.Ack(subject)
+	}
+	if err != nil {
+		m.writeJSON(w, adminStatus(err), statusapi.AdminResult{Subject: subject, Error: err.Error()})
+		return
+	}
+	m.writeJSON(w, http.StatusOK, resp)
+}
+
+func (m *Monitor) reloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := m.reloadResponse()
+		status := http.StatusOK
+		if !resp.OK {
+			status = http.StatusInternalServerError
+		}
+		m.writeJSON(w, status, resp)
+	})
+}
+
+func (m *Monitor) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		m.logger.Warn("admin response encode failed", "err", err)
+	}
+}
@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSubjectsHandlerForgetsSubject(t *testing.T) {
+	m := New(nil, nil, Config{})
+	m.state["svc"] = &state{subject: "svc", lastSeen: time.Now(), interval: time.Second}
+
+	rec := httptest.NewRecorder()
+	m.subjectsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/subjects/svc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if _, ok := m.state["svc"]; ok {
+		t.Fatalf("expected svc to be forgotten")
+	}
+
+	rec = httptest.NewRecorder()
+	m.subjectsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/subjects/svc", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown subject, got %d", rec.Code)
+	}
+}
+
+func TestForgetUnknownSubject(t *testing.T) {
+	m := New(nil, nil, Config{})
+	if err := m.Forget(context.Background(), "missing"); !errors.Is(err, ErrUnknownSubject) {
+		t.Fatalf("expected ErrUnknownSubject, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package monitor
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// Errors returned to clients without the credentials an operation needs.
+var (
+	errAuthRequired  = errors.New("authentication required")
+	errAdminRequired = errors.New("admin role required")
+)
+
+// Role is what a status server client may do.
+type Role int
+
+const (
+	// RoleRead may read status, history, reports, metrics, events and the
+	// dashboard.
+	RoleRead Role = iota + 1
+	// RoleAdmin may also forget, silence and acknowledge subjects and
+	// reload the config.
+	RoleAdmin
+)
+
+// ParseRole parses "read" or "admin".
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case "read":
+		return RoleRead, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("unknown role %q (want read or admin)", s)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// Credential grants Role to status server clients presenting a bearer
+// Token, basic auth User and Password, or a verified client certificate
+// whose subject common name is CommonName. Exactly one of them is set.
+type Credential struct {
+	Role       Role
+	Token      string
+	User       string
+	Password   string
+	CommonName string
+}
+
+// ParseCredentials reads credentials of the form "<role> token <token>",
+// "<role> basic <user> <password>" or "<role> cert <common name>", one per
+// line. Blank lines and lines starting with "#" are ignored.
+func ParseCredentials(r io.Reader) ([]Credential, error) {
+	var creds []Credential
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected role, kind and secret", line)
+		}
+		role, err := ParseRole(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		cred := Credential{Role: role}
+		switch kind := fields[1]; {
+		case kind == "token" && len(fields) == 3:
+			cred.Token = fields[2]
+		case kind == "basic" && len(fields) == 4:
+			cred.User, cred.Password = fields[2], fields[3]
+		case kind == "cert":
+			cred.CommonName = strings.Join(fields[2:], " ")
+		default:
+			return nil, fmt.Errorf("line %d: expected token <token>, basic <user> <password> or cert <common name>", line)
+		}
+		creds = append(creds, cred)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// LoadCredentials reads status server credentials from path.
+func LoadCredentials(path string) ([]Credential, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCredentials(f)
+}
+
+// authorize wraps the status server's handler. Without credentials every
+// request is allowed; otherwise reads need RoleRead and anything else
+// (forget, reload, silence, ack) needs RoleAdmin. Credentials are re-read
+// on every request, so reloads take effect immediately.
+func (m *Monitor) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		creds := m.config().StatusAuth
+		if len(creds) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		role, ok := authenticate(creds, r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", challenge(creds))
+			m.writeJSON(w, http.StatusUnauthorized, statusapi.AdminResult{Error: errAuthRequired.Error()})
+			return
+		}
+		if role < requiredRole(r) {
+			m.writeJSON(w, http.StatusForbidden, statusapi.AdminResult{Error: errAdminRequired.Error()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeRequest checks the Authorization header of a NATS request
+// (service or admin) against the status server credentials, so operations
+// over NATS need the same role as over HTTP. Client certificates only
+// apply to HTTPS, so NATS clients must present a token or basic auth.
+func (m *Monitor) authorizeRequest(authorization string, need Role) error {
+	creds := m.config().StatusAuth
+	if len(creds) == 0 {
+		return nil
+	}
+	role, ok := authenticate(creds, &http.Request{Header: http.Header{"Authorization": {authorization}}})
+	switch {
+	case !ok:
+		return errAuthRequired
+	case role < need:
+		return errAdminRequired
+	}
+	return nil
+}
+
+// authenticate returns the highest role any credential presented with r
+// grants.
+func authenticate(creds []Credential, r *http.Request) (Role, bool) {
+	token := ""
+	if h := r.Header.Get("Authorization"); len(h) > len("Bearer ") && strings.EqualFold(h[:len("Bearer ")], "Bearer ") {
+		token = h[len("Bearer "):]
+	}
+	user, password, basic := r.BasicAuth()
+	commonName := ""
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		commonName = r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+
+	var best Role
+	for _, c := range creds {
+		var match bool
+		switch {
+		case c.Token != "":
+			match = token != "" && secretEqual(token, c.Token)
+		case c.User != "":
+			match = basic && secretEqual(user, c.User) && secretEqual(password, c.Password)
+		case c.CommonName != "":
+			match = commonName == c.CommonName
+		}
+		if match && c.Role > best {
+			best = c.Role
+		}
+	}
+	return best, best != 0
+}
+
+func requiredRole(r *http.Request) Role {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return RoleRead
+	}
+	return RoleAdmin
+}
+
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// challenge asks browsers for basic auth when any basic credential exists.
+func challenge(creds []Credential) string {
+	for _, c := range creds {
+		if c.User != "" {
+			return `Basic realm="heartbeat-monitor"`
+		}
+	}
+	return `Bearer realm="heartbeat-monitor"`
+}
+
+// statusTLSConfig builds the status server's TLS config, or returns nil when
+// TLS is off. With StatusClientCA set, clients must present a certificate
+// signed by it.
+func statusTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.StatusTLSCert == "" && cfg.StatusTLSKey == "" {
+		if cfg.StatusClientCA != "" {
+			return nil, errors.New("status client CA requires a TLS certificate and key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.StatusTLSCert, cfg.StatusTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("load status TLS certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if cfg.StatusClientCA != "" {
+		pem, err := os.ReadFile(cfg.StatusClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("read status client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.StatusClientCA)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
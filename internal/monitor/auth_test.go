@@ -0,0 +1,221 @@
+package monitor
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCredentials(t *testing.T) {
+	creds, err := ParseCredentials(strings.NewReader("# comment\n\nread token s3cret\nadmin basic ops hunter2\nadmin cert ops team\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []Credential{
+		{Role: RoleRead, Token: "s3cret"},
+		{Role: RoleAdmin, User: "ops", Password: "hunter2"},
+		{Role: RoleAdmin, CommonName: "ops team"},
+	}
+	if len(creds) != len(want) {
+		t.Fatalf("expected %d credentials, got %+v", len(want), creds)
+	}
+	for i := range want {
+		if creds[i] != want[i] {
+			t.Errorf("credential %d = %+v, want %+v", i, creds[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"owner token x\n", "read token\n", "read basic ops\n", "read key x\n"} {
+		if _, err := ParseCredentials(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestStatusServerRoles(t *testing.T) {
+	m := New(nil, nil, Config{StatusAuth: []Credential{
+		{Role: RoleRead, Token: "reader"},
+		{Role: RoleAdmin, Token: "admin"},
+		{Role: RoleRead, User: "viewer", Password: "pw"},
+	}})
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		auth   func(*http.Request)
+		want   int
+	}{
+		{"anonymous read", http.MethodGet, "/", nil, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/", bearer("nope"), http.StatusUnauthorized},
+		{"reader reads", http.MethodGet, "/", bearer("reader"), http.StatusOK},
+		{"basic reads", http.MethodGet, "/metrics", basic("viewer", "pw"), http.StatusOK},
+		{"wrong password", http.MethodGet, "/", basic("viewer", "nope"), http.StatusUnauthorized},
+		{"reader cannot forget", http.MethodDelete, "/subjects/svc", bearer("reader"), http.StatusForbidden},
+		{"reader cannot reload", http.MethodPost, "/reload", bearer("reader"), http.StatusForbidden},
+		{"admin forgets", http.MethodDelete, "/subjects/svc", bearer("admin"), http.StatusNotFound},
+		{"admin reads", http.MethodGet, "/report", bearer("admin"), http.StatusOK},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, srv.URL+tc.path, nil)
+		if tc.auth != nil {
+			tc.auth(req)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, res.StatusCode, tc.want)
+		}
+		if res.StatusCode == http.StatusUnauthorized && !strings.HasPrefix(res.Header.Get("WWW-Authenticate"), "Basic ") {
+			t.Errorf("%s: expected a basic auth challenge, got %q", tc.name, res.Header.Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestStatusServerWithoutCredentialsIsOpen(t *testing.T) {
+	m := New(nil, nil, Config{})
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/subjects/svc", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected unknown subject, got status %d", res.StatusCode)
+	}
+}
+
+func TestStatusServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := testCertificate(t, "test CA", nil, nil)
+	server, serverKey := testCertificate(t, "monitor", ca, caKey)
+	client, clientKey := testCertificate(t, "ops", ca, caKey)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", server.Raw)
+	writeKey(t, filepath.Join(dir, "server-key.pem"), serverKey)
+
+	cfg := Config{
+		StatusTLSCert:  filepath.Join(dir, "server.pem"),
+		StatusTLSKey:   filepath.Join(dir, "server-key.pem"),
+		StatusClientCA: filepath.Join(dir, "ca.pem"),
+		StatusAuth:     []Credential{{Role: RoleAdmin, CommonName: "ops"}},
+	}
+	tlsCfg, err := statusTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("tls config: %v", err)
+	}
+	m := New(nil, nil, cfg)
+	srv := httptest.NewUnstartedServer(m.Handler())
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}},
+	}}}
+	res, err := withCert.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("request with client certificate: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the client certificate to grant access, got status %d", res.StatusCode)
+	}
+
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if res, err := withoutCert.Get(srv.URL + "/"); err == nil {
+		res.Body.Close()
+		t.Fatalf("expected the handshake to fail without a client certificate")
+	}
+}
+
+func TestStatusTLSConfigRequiresCertificate(t *testing.T) {
+	if _, err := statusTLSConfig(Config{StatusClientCA: "ca.pem"}); err == nil {
+		t.Fatalf("expected error for client CA without a certificate")
+	}
+	if cfg, err := statusTLSConfig(Config{}); cfg != nil || err != nil {
+		t.Fatalf("expected TLS to be off, got %v, %v", cfg, err)
+	}
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func basic(user, password string) func(*http.Request) {
+	return func(r *http.Request) { r.SetBasicAuth(user, password) }
+}
+
+// testCertificate issues a certificate for cn signed by parent, or a
+// self-signed CA when parent is nil.
+func testCertificate(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func writeKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	writePEM(t, path, "EC PRIVATE KEY", der)
+}
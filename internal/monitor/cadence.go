@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+const (
+	// maxCadenceSamples bounds the inter-arrival gaps kept per subject.
+	maxCadenceSamples = 100
+	// minCadenceSamples is how many gaps are needed before drift is judged.
+	minCadenceSamples = 10
+)
+
+// cadence keeps the latest inter-arrival gaps of a subject in a ring.
+type cadence struct {
+	gaps []time.Duration
+	next int
+}
+
+func (c *cadence) add(gap time.Duration) {
+	if len(c.gaps) < maxCadenceSamples {
+		c.gaps = append(c.gaps, gap)
+		return
+	}
+	c.gaps[c.next] = gap
+	c.next = (c.next + 1) % maxCadenceSamples
+}
+
+type cadenceStats struct {
+	samples int
+	mean    time.Duration
+	p95     time.Duration
+	max     time.Duration
+	// jitter is the mean absolute difference between the gaps and the
+	// declared interval.
+	jitter time.Duration
+}
+
+func (c *cadence) stats(interval time.Duration) (cadenceStats, bool) {
+	if len(c.gaps) == 0 {
+		return cadenceStats{}, false
+	}
+	sorted := append([]time.Duration(nil), c.gaps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum, deviation time.Duration
+	for _, gap := range sorted {
+		sum += gap
+		deviation += absDuration(gap - interval)
+	}
+	n := time.Duration(len(sorted))
+	// nearest-rank percentile
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return cadenceStats{
+		samples: len(sorted),
+		mean:    sum / n,
+		p95:     sorted[rank],
+		max:     sorted[len(sorted)-1],
+		jitter:  deviation / n,
+	}, true
+}
+
+// interArrival returns the gap since the previous beat from the same
+// publisher. Gaps that do not reflect the publisher's cadence (first beat of
+// an instance, lost beats, or the beat ending an outage) are skipped. It
+// must be called before trackInstance and observe.
+func (s *state) interArrival(msg heartbeat.Message, receivedAt time.Time) (time.Duration, bool) {
+	if s.alertActive {
+		return 0, false
+	}
+	if msg.BootID == "" {
+		return receivedAt.Sub(s.lastSeen), true
+	}
+	prev, ok := s.boots[msg.BootID]
+	if !ok || (msg.Sequence != 0 && msg.Sequence != prev.seq+1) {
+		return 0, false
+	}
+	return receivedAt.Sub(prev.lastSeen), true
+}
+
+// checkDrift sends a notice when the subject's p95 inter-arrival time
+// deviates from its declared interval by more than MaxDrift. Callers must
+// hold m.mu.
+func (m *Monitor) checkDrift(ctx context.Context, s *state) {
+	maxDrift := m.config().MaxDrift
+	if maxDrift <= 0 || s.interval <= 0 {
+		s.driftExceeded = false
+		return
+	}
+	stats, ok := s.cadence.stats(s.interval)
+	if !ok || stats.samples < minCadenceSamples {
+		return
+	}
+
+	drift := float64(absDuration(stats.p95-s.interval)) / float64(s.interval)
+	exceeded := drift > maxDrift
+	if exceeded == s.driftExceeded {
+		return
+	}
+	s.driftExceeded = exceeded
+	if !exceeded {
+		m.logger.Info("heartbeat cadence back within drift threshold", "subject", s.subject, "p95", stats.p95, "interval", s.interval)
+		return
+	}
+
+	m.logger.Warn("heartbeat cadence drifting", "subject", s.subject, "p95", stats.p95, "interval", s.interval, "max_drift", maxDrift)
+	evt := s.event()
+	evt.Reason = fmt.Sprintf("cadence drifting: p95 inter-arrival %s vs declared interval %s", stats.p95.Round(time.Millisecond), s.interval)
+	m.notice(ctx, evt)
+}
+
+func (s *state) cadenceStatus() *statusapi.Cadence {
+	stats, ok := s.cadence.stats(s.interval)
+	if !ok {
+		return nil
+	}
+	round := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+	return &statusapi.Cadence{
+		Samples:       stats.samples,
+		Mean:          round(stats.mean),
+		P95:           round(stats.p95),
+		Max:           round(stats.max),
+		Jitter:        round(stats.jitter),
+		DriftExceeded: s.driftExceeded,
+	}
+}
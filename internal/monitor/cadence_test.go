@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+func TestCadenceStats(t *testing.T) {
+	var c cadence
+	if _, ok := c.stats(time.Second); ok {
+		t.Fatalf("expected no stats without samples")
+	}
+	for i := 0; i < 19; i++ {
+		c.add(time.Second)
+	}
+	c.add(3 * time.Second)
+
+	stats, ok := c.stats(time.Second)
+	if !ok {
+		t.Fatalf("expected stats")
+	}
+	if stats.samples != 20 || stats.mean != 1100*time.Millisecond || stats.max != 3*time.Second {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.p95 != time.Second {
+		t.Fatalf("expected p95 1s, got %s", stats.p95)
+	}
+	if stats.jitter != 100*time.Millisecond {
+		t.Fatalf("expected jitter 100ms, got %s", stats.jitter)
+	}
+
+	for i := 0; i < maxCadenceSamples; i++ {
+		c.add(2 * time.Second)
+	}
+	if stats, _ := c.stats(time.Second); stats.samples != maxCadenceSamples || stats.max != 2*time.Second {
+		t.Fatalf("expected old samples to be overwritten, got %+v", stats)
+	}
+}
+
+func TestInterArrivalSkipsGapsAcrossInstancesAndLoss(t *testing.T) {
+	now := time.Now()
+	msg := heartbeat.Message{Subject: "svc", GeneratedAt: now, Interval: time.Second, BootID: "a", Sequence: 1}
+	st := newState(msg)
+	st.trackInstance(msg, now)
+	st.observe(msg, now)
+
+	next := msg
+	next.Sequence = 2
+	if gap, ok := st.interArrival(next, now.Add(1200*time.Millisecond)); !ok || gap != 1200*time.Millisecond {
+		t.Fatalf("expected 1.2s gap, got %s %t", gap, ok)
+	}
+
+	lost := msg
+	lost.Sequence = 4
+	if _, ok := st.interArrival(lost, now.Add(3*time.Second)); ok {
+		t.Fatalf("expected gap with lost beats to be skipped")
+	}
+
+	restarted := msg
+	restarted.BootID = "b"
+	if _, ok := st.interArrival(restarted, now.Add(3*time.Second)); ok {
+		t.Fatalf("expected first beat of a new instance to be skipped")
+	}
+
+	st.alertActive = true
+	if _, ok := st.interArrival(next, now.Add(time.Minute)); ok {
+		t.Fatalf("expected the beat ending an outage to be skipped")
+	}
+}
+
+func TestCheckDriftSendsNotice(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{MaxDrift: 0.25})
+	s := &state{subject: "svc", description: "svc", interval: time.Second}
+
+	for i := 0; i < minCadenceSamples-1; i++ {
+		s.cadence.add(2 * time.Second)
+	}
+	m.checkDrift(context.Background(), s)
+	if s.driftExceeded {
+		t.Fatalf("expected drift to wait for enough samples")
+	}
+
+	s.cadence.add(2 * time.Second)
+	m.checkDrift(context.Background(), s)
+	if !s.driftExceeded {
+		t.Fatalf("expected drift to be flagged")
+	}
+	waitFor(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.notices) == 1 && strings.Contains(rec.notices[0].Reason, "cadence drifting")
+	})
+
+	for i := 0; i < maxCadenceSamples; i++ {
+		s.cadence.add(time.Second)
+	}
+	m.checkDrift(context.Background(), s)
+	if s.driftExceeded {
+		t.Fatalf("expected drift to clear")
+	}
+}
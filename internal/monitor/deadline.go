@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"container/heap"
+	"time"
+)
+
+// pruneEvery is how often scan drops outages that fell out of every uptime
+// window; outages are kept for days, so pruning them every poll is wasted
+// work on large fleets.
+const pruneEvery = time.Minute
+
+// deadlines is a min-heap of tracked subjects ordered by when scan next has
+// to evaluate them, so a poll only touches the subjects that are due rather
+// than every subject.
+type deadlines []*state
+
+func (d deadlines) Len() int           { return len(d) }
+func (d deadlines) Less(i, j int) bool { return d[i].checkAt.Before(d[j].checkAt) }
+
+func (d deadlines) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+	d[i].queueIndex = i
+	d[j].queueIndex = j
+}
+
+func (d *deadlines) Push(x interface{}) {
+	s := x.(*state)
+	s.queueIndex = len(*d)
+	*d = append(*d, s)
+}
+
+func (d *deadlines) Pop() interface{} {
+	old := *d
+	s := old[len(old)-1]
+	old[len(old)-1] = nil
+	*d = old[:len(old)-1]
+	return s
+}
+
+// queued reports whether s is in the heap.
+func (d deadlines) queued(s *state) bool {
+	return s.queueIndex < len(d) && d[s.queueIndex] == s
+}
+
+// nextCheck returns when scan next needs to evaluate s: when it goes
+// overdue, recovers, is due a repeat alert or expires, and with PerHost the
+// same for each of its hosts. An acknowledged alert does not repeat; the
+// zero time means nothing is due until the next beat. Callers must hold
+// m.mu.
+func (m *Monitor) nextCheck(s *state, now time.Time) time.Time {
+	cfg := m.config()
+	allowed := s.allowedWindow()
+	next := checkTime(s.lastSeen, s.alertActive, s.lastAlert, allowed, cfg.RepeatEvery, !s.acked, now)
+	if cfg.ExpireAfter > 0 {
+		next = earliest(next, s.lastSeen.Add(cfg.ExpireAfter))
+	}
+	if cfg.PerHost {
+		expiry := hostExpiry(cfg)
+		covered := hostsCovered(s)
+		for _, h := range s.hosts {
+			// hosts covered by the subject's alert are not paged for, but
+			// alerting ones still resolve
+			if !covered || h.alertActive {
+				next = earliest(next, checkTime(h.lastSeen, h.alertActive, h.lastAlert, allowed, cfg.RepeatEvery, true, now))
+			}
+			next = earliest(next, h.lastSeen.Add(expiry))
+		}
+	}
+	return next
+}
+
+// checkTime returns when a subject or host last seen at lastSeen next
+// changes state: when it goes overdue, or while alerting, at once if it has
+// recovered and otherwise when the alert repeats, or never (the zero time)
+// without repeat.
+func checkTime(lastSeen time.Time, alertActive bool, lastAlert time.Time, allowed, repeatEvery time.Duration, repeat bool, now time.Time) time.Time {
+	overdue := lastSeen.Add(allowed)
+	if !alertActive {
+		return overdue
+	}
+	if !now.After(overdue) {
+		return now
+	}
+	if !repeat {
+		return time.Time{}
+	}
+	return lastAlert.Add(repeatEvery)
+}
+
+// earliest returns the earlier of a and b, where the zero time means never.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// schedule queues s for its next check, or moves it if it is already
+// queued; a subject with nothing due is left out of the queue. Callers must
+// hold m.mu.
+func (m *Monitor) schedule(s *state, now time.Time) {
+	s.checkAt = m.nextCheck(s, now)
+	if s.checkAt.IsZero() {
+		m.unschedule(s)
+		return
+	}
+	if m.queue.queued(s) {
+		heap.Fix(&m.queue, s.queueIndex)
+		return
+	}
+	heap.Push(&m.queue, s)
+}
+
+// unschedule drops s from the queue. Callers must hold m.mu.
+func (m *Monitor) unschedule(s *state) {
+	if m.queue.queued(s) {
+		heap.Remove(&m.queue, s.queueIndex)
+	}
+}
+
+// rescheduleAll rebuilds the queue from m.state, for settings changes that
+// move every deadline. Callers must hold m.mu.
+func (m *Monitor) rescheduleAll(now time.Time) {
+	m.queue = make(deadlines, 0, len(m.state))
+	for _, s := range m.state {
+		if s.checkAt = m.nextCheck(s, now); s.checkAt.IsZero() {
+			continue
+		}
+		s.queueIndex = len(m.queue)
+		m.queue = append(m.queue, s)
+	}
+	heap.Init(&m.queue)
+}
+
+// popDue removes and returns the subjects due by now. Callers must hold
+// m.mu and reschedule the subjects they keep.
+func (m *Monitor) popDue(now time.Time) []*state {
+	var due []*state
+	for len(m.queue) > 0 && !m.queue[0].checkAt.After(now) {
+		due = append(due, heap.Pop(&m.queue).(*state))
+	}
+	return due
+}
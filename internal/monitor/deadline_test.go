@@ -0,0 +1,250 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+func TestScanFollowsDeadlines(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{Prefix: "heartbeat", RepeatEvery: time.Minute, ExpireAfter: 5 * time.Minute})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	beat := func(subject string, at time.Time) {
+		data, err := heartbeat.Message{Subject: subject, GeneratedAt: at, Interval: 10 * time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat." + subject, Data: data}, at)
+	}
+	scanAt := func(at time.Time) {
+		m.clock = fixedClock(at)
+		m.scan(ctx)
+	}
+	beat("quiet", start)
+	beat("busy", start)
+
+	m.mu.Lock()
+	if m.queue[0].checkAt != start.Add(10*time.Second) {
+		t.Fatalf("expected the first check when the beats go overdue, got %s", m.queue[0].checkAt)
+	}
+	m.mu.Unlock()
+
+	for at := start.Add(5 * time.Second); at.Before(start.Add(3 * time.Minute)); at = at.Add(5 * time.Second) {
+		beat("busy", at)
+		scanAt(at)
+	}
+	if len(rec.alerts) != 3 {
+		t.Fatalf("expected an alert and two repeats for quiet, got %+v", rec.alerts)
+	}
+	for _, evt := range rec.alerts {
+		if evt.Subject != "quiet" {
+			t.Fatalf("expected alerts for quiet only, got %+v", rec.alerts)
+		}
+	}
+
+	scanAt(start.Add(6 * time.Minute))
+	if len(rec.expired) != 1 || rec.expired[0].Subject != "quiet" {
+		t.Fatalf("expected quiet to expire, got %+v", rec.expired)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) != 1 || m.queue[0].subject != "busy" {
+		t.Fatalf("expected only busy left in the queue, got %d entries", len(m.queue))
+	}
+}
+
+func TestReloadReschedulesRepeats(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{Prefix: "heartbeat", RepeatEvery: time.Hour})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	data, _ := heartbeat.Message{Subject: "svc", GeneratedAt: start, Interval: 10 * time.Second}.Marshal()
+	m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, start)
+
+	m.clock = fixedClock(start.Add(time.Minute))
+	m.scan(ctx)
+	m.Reload(Config{Prefix: "heartbeat", RepeatEvery: time.Minute}, rec)
+	m.clock = fixedClock(start.Add(2 * time.Minute))
+	m.scan(ctx)
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected the shorter repeat interval to apply at once, got %+v", rec.alerts)
+	}
+}
+
+func TestAckedAlertLeavesQueue(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{Prefix: "heartbeat", RepeatEvery: time.Minute})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	data, _ := heartbeat.Message{Subject: "svc", GeneratedAt: start, Interval: 10 * time.Second}.Marshal()
+	m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, start)
+
+	m.clock = fixedClock(start.Add(time.Minute))
+	m.scan(ctx)
+	if err := m.Ack("svc"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	m.mu.Lock()
+	queued := len(m.queue)
+	m.mu.Unlock()
+	if queued != 0 {
+		t.Fatalf("expected an acknowledged alert with nothing else due to leave the queue, %d queued", queued)
+	}
+	m.clock = fixedClock(start.Add(time.Hour))
+	m.scan(ctx)
+	if len(rec.alerts) != 1 {
+		t.Fatalf("expected no repeats after the ack, got %+v", rec.alerts)
+	}
+
+	// the next beat resolves the alert and queues the subject again
+	data, _ = heartbeat.Message{Subject: "svc", GeneratedAt: start.Add(time.Hour), Interval: 10 * time.Second}.Marshal()
+	m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, start.Add(time.Hour))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) != 1 || m.queue[0].checkAt != start.Add(time.Hour+10*time.Second) {
+		t.Fatalf("expected svc to be queued for its next deadline, got %d entries", len(m.queue))
+	}
+}
+
+func TestScanFollowsHostDeadlines(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{Prefix: "heartbeat", PerHost: true})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	beat := func(host string, at time.Time) {
+		data, err := heartbeat.Message{Subject: "svc", Host: host, GeneratedAt: at, Interval: 10 * time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, at)
+	}
+	beat("host-a", start)
+	beat("host-b", start.Add(time.Second))
+
+	// host-a keeps the subject alive while host-b goes quiet
+	at := start
+	for i := 0; i < 6; i++ {
+		at = at.Add(5 * time.Second)
+		beat("host-a", at)
+		m.clock = fixedClock(at)
+		m.scan(ctx)
+	}
+	if len(rec.alerts) != 1 || rec.alerts[0].Host != "host-b" {
+		t.Fatalf("expected an alert for host-b, got %+v", rec.alerts)
+	}
+
+	beat("host-b", at.Add(time.Second))
+	m.clock = fixedClock(at.Add(2 * time.Second))
+	m.scan(ctx)
+	if len(rec.resolved) != 1 || rec.resolved[0].Host != "host-b" {
+		t.Fatalf("expected host-b to resolve on the next scan, got %+v", rec.resolved)
+	}
+	if rec.resolved[0].MissCount != 3 {
+		t.Fatalf("expected host-b to report 3 missed beats, got %d", rec.resolved[0].MissCount)
+	}
+}
+
+// benchmarkSubjects is the fleet size the scan benchmarks run against.
+const benchmarkSubjects = 100000
+
+// benchmarkBeat returns subject i's heartbeat generated at.
+func benchmarkBeat(b *testing.B, i int, at time.Time) *nats.Msg {
+	subject := fmt.Sprintf("svc-%d", i)
+	data, err := heartbeat.Message{Subject: subject, GeneratedAt: at, Interval: time.Minute}.Marshal()
+	if err != nil {
+		b.Fatalf("marshal: %v", err)
+	}
+	return &nats.Msg{Subject: "heartbeat." + subject, Data: data}
+}
+
+// benchmarkMonitor returns a monitor tracking n healthy subjects that beat
+// every minute.
+func benchmarkMonitor(b *testing.B, n int) (*Monitor, time.Time) {
+	b.Helper()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	m := New(nil, nil, Config{Prefix: "heartbeat", HistorySize: 1})
+	for i := 0; i < n; i++ {
+		m.handleMessage(context.Background(), benchmarkBeat(b, i, start), start)
+	}
+	return m, start
+}
+
+// BenchmarkScan measures a poll over 100k subjects of which none are due.
+func BenchmarkScan(b *testing.B) {
+	m, start := benchmarkMonitor(b, benchmarkSubjects)
+	m.clock = fixedClock(start.Add(time.Second))
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.scan(ctx)
+	}
+}
+
+// BenchmarkScanDue measures a poll over 100k subjects where 1% have gone
+// overdue since the previous poll and 1% have recovered.
+func BenchmarkScanDue(b *testing.B) {
+	m, start := benchmarkMonitor(b, benchmarkSubjects)
+	ctx := context.Background()
+	now := start
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		now = now.Add(2 * time.Minute)
+		for j := 0; j < benchmarkSubjects; j++ {
+			if j%100 != i%100 {
+				m.handleMessage(ctx, benchmarkBeat(b, j, now), now)
+			}
+		}
+		m.clock = fixedClock(now.Add(time.Second))
+		// outages are pruned once a minute; BenchmarkPruneAvailability
+		// covers that
+		m.lastPrune = now
+		b.StartTimer()
+		m.scan(ctx)
+	}
+}
+
+// BenchmarkPruneAvailability measures the once-a-minute outage pruning
+// over 100k subjects.
+func BenchmarkPruneAvailability(b *testing.B) {
+	m, start := benchmarkMonitor(b, benchmarkSubjects)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.mu.Lock()
+		m.pruneAvailability(start.Add(time.Minute))
+		m.mu.Unlock()
+	}
+}
+
+// BenchmarkHandleMessageDuringScan measures heartbeat handling for 100k
+// subjects while another goroutine polls as fast as it can, which shows how
+// long beats wait for scan to release the lock.
+func BenchmarkHandleMessageDuringScan(b *testing.B) {
+	m, start := benchmarkMonitor(b, benchmarkSubjects)
+	m.clock = fixedClock(start.Add(time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			m.scan(ctx)
+		}
+	}()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		at := start.Add(time.Duration(i/benchmarkSubjects+1) * time.Millisecond)
+		m.handleMessage(ctx, benchmarkBeat(b, i%benchmarkSubjects, at), at)
+	}
+	b.StopTimer()
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// Event stream kinds sent on /events. Notification kinds mirror the
+// notifier methods.
+const (
+	eventSnapshot = "snapshot"
+	eventUpdate   = "update"
+	eventAlert    = notifier.KindAlert
+	eventResolved = notifier.KindResolved
+	eventExpired  = notifier.KindExpired
+	eventNotice   = notifier.KindNotice
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment so
+// proxies do not close it.
+const eventKeepAlive = 15 * time.Second
+
+// eventThrottle is the minimum gap between update events on one stream.
+// Every accepted heartbeat signals a change, so a busy monitor sends at most
+// one diff per period carrying everything that changed since the last.
+const eventThrottle = time.Second
+
+// eventHub fans notifications and state-change signals out to /events
+// clients. Slow clients miss notifications rather than blocking the monitor.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+}
+
+type eventSub struct {
+	events  chan statusapi.Event
+	changed chan struct{}
+}
+
+func (h *eventHub) subscribe() *eventSub {
+	sub := &eventSub{
+		events:  make(chan statusapi.Event, 64),
+		changed: make(chan struct{}, 1),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs == nil {
+		h.subs = make(map[*eventSub]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *eventHub) unsubscribe(sub *eventSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+func (h *eventHub) publish(evt statusapi.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		select {
+		case sub.events <- evt:
+		default:
+		}
+	}
+}
+
+// touch tells clients that subject state changed and a fresh diff is due.
+func (h *eventHub) touch() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		select {
+		case sub.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// emit forwards a notification to /events clients and records it in the
+// subject's history.
+func (m *Monitor) emit(kind string, evt notifier.Event) {
+	now := m.clock.Now()
+	m.recordEvent(kind, evt, now)
+	out := statusapi.Event{
+		Kind:        kind,
+		Subject:     evt.Subject,
+		Description: evt.Description,
+		Host:        evt.Host,
+		LastSeen:    evt.LastSeen,
+		MissCount:   evt.MissCount,
+		Reason:      evt.Reason,
+		At:          now,
+	}
+	if evt.Interval > 0 {
+		out.Interval = evt.Interval.String()
+	}
+	if evt.MissFor > 0 {
+		out.MissFor = evt.MissFor.String()
+	}
+	m.events.publish(out)
+}
+
+// eventsHandler streams status changes and notifications as Server-Sent
+// Events: a snapshot on connect, then update events with changed subjects,
+// at most one per eventThrottle, and one event per alert, resolve, expiry
+// or notice.
+func (m *Monitor) eventsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		sub := m.events.subscribe()
+		defer m.events.unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		observedAt := m.clock.Now()
+		subjects := m.snapshot(observedAt)
+		prev := indexSubjects(subjects)
+		if err := writeEvent(w, eventSnapshot, statusapi.Status{Version: statusapi.Version, ObservedAt: observedAt, Subjects: subjects}); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		var (
+			lastUpdate time.Time
+			throttled  <-chan time.Time
+		)
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case evt := <-sub.events:
+				err = writeEvent(w, evt.Kind, evt)
+			case <-sub.changed:
+				if throttled == nil {
+					throttled = time.After(eventThrottle - time.Since(lastUpdate))
+				}
+				continue
+			case <-throttled:
+				throttled = nil
+				lastUpdate = time.Now()
+				observedAt := m.clock.Now()
+				next := m.snapshot(observedAt)
+				changed, removed := diffSubjects(prev, next)
+				prev = indexSubjects(next)
+				if len(changed) == 0 && len(removed) == 0 {
+					continue
+				}
+				err = writeEvent(w, eventUpdate, statusapi.Update{ObservedAt: observedAt, Subjects: changed, Removed: removed})
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+			}
+			if err != nil {
+				m.logger.Debug("event stream closed", "err", err)
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, kind string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, data)
+	return err
+}
+
+func indexSubjects(subjects []statusapi.SubjectStatus) map[string]statusapi.SubjectStatus {
+	out := make(map[string]statusapi.SubjectStatus, len(subjects))
+	for _, s := range subjects {
+		out[s.Subject] = s
+	}
+	return out
+}
+
+// diffSubjects returns the subjects in next that are new or differ from
+// prev, and the names of subjects in prev missing from next. Fields that
+// only move with the clock are ignored.
+func diffSubjects(prev map[string]statusapi.SubjectStatus, next []statusapi.SubjectStatus) (changed []statusapi.SubjectStatus, removed []string) {
+	seen := make(map[string]bool, len(next))
+	for _, s := range next {
+		seen[s.Subject] = true
+		if old, ok := prev[s.Subject]; !ok || !reflect.DeepEqual(withoutElapsed(old), withoutElapsed(s)) {
+			changed = append(changed, s)
+		}
+	}
+	for name := range prev {
+		if !seen[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// withoutElapsed clears the fields of s that change as time passes rather
+// than when the subject's state does.
+func withoutElapsed(s statusapi.SubjectStatus) statusapi.SubjectStatus {
+	s.MissFor = ""
+	s.MissCount = 0
+	s.Uptime = nil
+	return s
+}
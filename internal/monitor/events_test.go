@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestDiffSubjects(t *testing.T) {
+	prev := indexSubjects([]statusapi.SubjectStatus{
+		{Subject: "a", Interval: "1s"},
+		{Subject: "b", Interval: "1s"},
+		{Subject: "c", Interval: "1s"},
+	})
+	next := []statusapi.SubjectStatus{
+		{Subject: "a", Interval: "1s"},
+		{Subject: "b", Interval: "1s", AlertActive: true},
+		{Subject: "d", Interval: "1s"},
+	}
+
+	changed, removed := diffSubjects(prev, next)
+	if len(changed) != 2 || changed[0].Subject != "b" || changed[1].Subject != "d" {
+		t.Fatalf("unexpected changed subjects: %+v", changed)
+	}
+	if len(removed) != 1 || removed[0] != "c" {
+		t.Fatalf("unexpected removed subjects: %v", removed)
+	}
+}
+
+func TestEventsHandlerStreamsSnapshotAndUpdates(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat"})
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	events := bufio.NewReader(res.Body)
+
+	kind, _ := readEvent(t, events)
+	if kind != eventSnapshot {
+		t.Fatalf("expected snapshot first, got %q", kind)
+	}
+
+	start := time.Now()
+	beat := func(at time.Time) {
+		data, err := heartbeat.Message{Subject: "svc", GeneratedAt: at, Interval: time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, at)
+	}
+	beat(start)
+
+	kind, payload := readEvent(t, events)
+	if kind != eventUpdate {
+		t.Fatalf("expected update, got %q", kind)
+	}
+	var update statusapi.Update
+	if err := json.Unmarshal(payload, &update); err != nil {
+		t.Fatalf("decode update: %v", err)
+	}
+	if len(update.Subjects) != 1 || update.Subjects[0].Subject != "svc" {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+
+	// beats inside the throttle period are coalesced into one update that
+	// carries the latest last-seen time
+	beat(start.Add(time.Millisecond))
+	beat(start.Add(2 * time.Millisecond))
+	kind, payload = readEvent(t, events)
+	if kind != eventUpdate {
+		t.Fatalf("expected update, got %q", kind)
+	}
+	update = statusapi.Update{}
+	if err := json.Unmarshal(payload, &update); err != nil {
+		t.Fatalf("decode update: %v", err)
+	}
+	if len(update.Subjects) != 1 || !update.Subjects[0].LastSeen.Equal(start.Add(2*time.Millisecond)) {
+		t.Fatalf("expected one update with the latest beat, got %+v", update)
+	}
+
+	m.mu.Lock()
+	m.state["svc"].lastSeen = time.Now().Add(-time.Minute)
+	m.schedule(m.state["svc"], time.Now())
+	m.mu.Unlock()
+	m.scan(ctx)
+
+	// the update and the alert are independent signals and may arrive in
+	// either order
+	got := map[string][]byte{}
+	for i := 0; i < 2; i++ {
+		kind, payload := readEvent(t, events)
+		got[kind] = payload
+	}
+	if _, ok := got[eventUpdate]; !ok {
+		t.Fatalf("expected update after scan, got %v", got)
+	}
+	payload, ok := got[eventAlert]
+	if !ok {
+		t.Fatalf("expected alert, got %v", got)
+	}
+	var alert statusapi.Event
+	if err := json.Unmarshal(payload, &alert); err != nil {
+		t.Fatalf("decode alert: %v", err)
+	}
+	if alert.Subject != "svc" || alert.MissCount == 0 {
+		t.Fatalf("unexpected alert: %+v", alert)
+	}
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) (string, []byte) {
+	t.Helper()
+	var kind string
+	var data []byte
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if kind != "" {
+				return kind, data
+			}
+		case strings.HasPrefix(line, "event: "):
+			kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = []byte(strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func TestDiffSubjectsIgnoresElapsedTime(t *testing.T) {
+	prev := indexSubjects([]statusapi.SubjectStatus{
+		{Subject: "a", Missing: true, MissFor: "1m0s", MissCount: 6, Uptime: []statusapi.UptimeWindow{{Window: "1h", Uptime: statusapi.Uptime{UptimePercent: 90}}}},
+	})
+	next := []statusapi.SubjectStatus{
+		{Subject: "a", Missing: true, MissFor: "1m1s", MissCount: 7, Uptime: []statusapi.UptimeWindow{{Window: "1h", Uptime: statusapi.Uptime{UptimePercent: 89.9}}}},
+	}
+	if changed, _ := diffSubjects(prev, next); len(changed) != 0 {
+		t.Fatalf("expected no changes from the clock moving on, got %+v", changed)
+	}
+}
+
+func TestEventsTouchOnAcceptedBeatsAndStateChanges(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat", RepeatEvery: time.Hour})
+	ctx := context.Background()
+	sub := m.events.subscribe()
+	defer m.events.unsubscribe(sub)
+	touched := func() bool {
+		select {
+		case <-sub.changed:
+			return true
+		default:
+			return false
+		}
+	}
+	start := time.Now().Add(-5 * time.Second)
+	beat := func(at time.Time) {
+		data, err := heartbeat.Message{Subject: "svc", GeneratedAt: at, Interval: 10 * time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, at)
+	}
+
+	beat(start)
+	if !touched() {
+		t.Fatalf("expected a new subject to touch the event stream")
+	}
+	beat(start.Add(time.Second))
+	if !touched() {
+		t.Fatalf("expected an accepted beat to touch the event stream")
+	}
+	beat(start.Add(time.Second))
+	if touched() {
+		t.Fatalf("expected a stale beat not to touch the event stream")
+	}
+
+	m.scan(ctx)
+	if touched() {
+		t.Fatalf("expected a quiet poll not to touch the event stream")
+	}
+	m.mu.Lock()
+	m.state["svc"].lastSeen = time.Now().Add(-time.Minute)
+	m.schedule(m.state["svc"], time.Now())
+	m.mu.Unlock()
+	m.scan(ctx)
+	if !touched() {
+		t.Fatalf("expected the alert to touch the event stream")
+	}
+	m.scan(ctx)
+	if touched() {
+		t.Fatalf("expected a poll of a still-missing subject not to touch the event stream")
+	}
+}
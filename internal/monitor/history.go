@@ -0,0 +1,299 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+const (
+	defaultHistorySize    = 100
+	defaultHistorySubject = "heartbeat-history"
+
+	historyBeat = "beat"
+)
+
+// history is a fixed-size ring buffer of entries, oldest overwritten first.
+type history struct {
+	entries []statusapi.HistoryEntry
+	start   int
+}
+
+func newHistory(size int) *history {
+	return &history{entries: make([]statusapi.HistoryEntry, 0, size)}
+}
+
+func (h *history) add(e statusapi.HistoryEntry) {
+	if len(h.entries) < cap(h.entries) {
+		h.entries = append(h.entries, e)
+		return
+	}
+	h.entries[h.start] = e
+	h.start = (h.start + 1) % len(h.entries)
+}
+
+// list returns the entries oldest first.
+func (h *history) list() []statusapi.HistoryEntry {
+	out := make([]statusapi.HistoryEntry, 0, len(h.entries))
+	out = append(out, h.entries[h.start:]...)
+	return append(out, h.entries[:h.start]...)
+}
+
+// lastBeat returns the most recent beat entry, if any.
+func (h *history) lastBeat() (statusapi.HistoryEntry, bool) {
+	entries := h.list()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == historyBeat {
+			return entries[i], true
+		}
+	}
+	return statusapi.HistoryEntry{}, false
+}
+
+// beatTimes returns the receive times of up to n of the latest beats,
+// oldest first.
+func (h *history) beatTimes(n int) []time.Time {
+	var out []time.Time
+	entries := h.list()
+	for i := len(entries) - 1; i >= 0 && len(out) < n; i-- {
+		if entries[i].Kind == historyBeat {
+			out = append(out, entries[i].At)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// recordBeat adds an accepted heartbeat to its subject's history. Beats
+// already recorded (e.g. replayed from the prime stream after the history
+// was restored) are skipped.
+func (m *Monitor) recordBeat(hb heartbeat.Message, receivedAt time.Time) {
+	m.historyMu.Lock()
+	if h, ok := m.history[hb.Subject]; ok {
+		if last, ok := h.lastBeat(); ok && last.GeneratedAt.Equal(hb.GeneratedAt) && last.Seq == hb.Sequence {
+			m.historyMu.Unlock()
+			return
+		}
+	}
+	m.historyMu.Unlock()
+
+	m.record(statusapi.HistoryEntry{
+		Kind:        historyBeat,
+		Subject:     hb.Subject,
+		At:          receivedAt,
+		GeneratedAt: hb.GeneratedAt,
+		Host:        hb.Host,
+		Seq:         hb.Sequence,
+		BootID:      hb.BootID,
+	})
+}
+
+// recordEvent adds a notification to its subject's history.
+func (m *Monitor) recordEvent(kind string, evt notifier.Event, at time.Time) {
+	entry := statusapi.HistoryEntry{
+		Kind:      kind,
+		Subject:   evt.Subject,
+		At:        at,
+		Host:      evt.Host,
+		MissCount: evt.MissCount,
+		Reason:    evt.Reason,
+	}
+	if evt.MissFor > 0 {
+		entry.MissFor = evt.MissFor.String()
+	}
+	m.record(entry)
+}
+
+func (m *Monitor) record(e statusapi.HistoryEntry) {
+	m.historyMu.Lock()
+	h, ok := m.history[e.Subject]
+	if !ok {
+		h = newHistory(m.historySize)
+		m.history[e.Subject] = h
+	}
+	h.add(e)
+	m.historyMu.Unlock()
+
+	m.persistHistory(e)
+}
+
+func (m *Monitor) subjectHistory(subject string) ([]statusapi.HistoryEntry, bool) {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+	h, ok := m.history[subject]
+	if !ok {
+		return nil, false
+	}
+	return h.list(), true
+}
+
+func (m *Monitor) recentBeats(subject string) []time.Time {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+	h, ok := m.history[subject]
+	if !ok {
+		return nil
+	}
+	return h.beatTimes(maxRecentBeats)
+}
+
+func (m *Monitor) forgetHistory(subject string) {
+	m.historyMu.Lock()
+	delete(m.history, subject)
+	m.historyMu.Unlock()
+
+	cfg := m.config()
+	if cfg.HistoryStream == "" || m.nc == nil {
+		return
+	}
+	js, err := m.nc.JetStream()
+	if err == nil {
+		err = js.PurgeStream(cfg.HistoryStream, &nats.StreamPurgeRequest{Subject: m.historySubject(subject)})
+	}
+	if err != nil {
+		m.logger.Warn("purge history failed", "subject", subject, "stream", cfg.HistoryStream, "err", err)
+	}
+}
+
+func (m *Monitor) historySubject(subject string) string {
+	return m.config().HistorySubject + "." + subject
+}
+
+// persistHistory publishes e to the history stream, if one is configured.
+// Publishing is fire-and-forget so a slow or unavailable JetStream never
+// holds up heartbeat processing.
+func (m *Monitor) persistHistory(e statusapi.HistoryEntry) {
+	if m.config().HistoryStream == "" || m.nc == nil {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		m.logger.Warn("encode history entry failed", "subject", e.Subject, "err", err)
+		return
+	}
+	if err := m.nc.Publish(m.historySubject(e.Subject), data); err != nil {
+		m.logger.Warn("persist history failed", "subject", e.Subject, "err", err)
+	}
+}
+
+// loadHistory creates the history stream if needed and restores the
+// persisted entries into memory.
+func (m *Monitor) loadHistory(ctx context.Context) error {
+	cfg := m.config()
+	js, err := m.nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	subjects := cfg.HistorySubject + ".>"
+	if _, err := js.StreamInfo(cfg.HistoryStream); errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:              cfg.HistoryStream,
+			Subjects:          []string{subjects},
+			MaxMsgsPerSubject: int64(m.historySize),
+			Storage:           nats.FileStorage,
+		})
+		if err != nil {
+			return fmt.Errorf("create history stream: %w", err)
+		}
+		m.logger.Info("created history stream", "stream", cfg.HistoryStream, "subject", subjects)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	sub, err := js.SubscribeSync(subjects, nats.BindStream(cfg.HistoryStream), nats.OrderedConsumer(), nats.DeliverAll())
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	loaded := 0
+	for {
+		msg, err := sub.NextMsgWithContext(timeoutCtx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return err
+		}
+		var e statusapi.HistoryEntry
+		if err := json.Unmarshal(msg.Data, &e); err != nil || e.Subject == "" {
+			m.logger.Warn("skipping invalid history entry", "subject", msg.Subject, "err", err)
+		} else if m.owns(m.natsSubjectFor(e.Subject)) {
+			m.historyMu.Lock()
+			h, ok := m.history[e.Subject]
+			if !ok {
+				h = newHistory(m.historySize)
+				m.history[e.Subject] = h
+			}
+			h.add(e)
+			m.historyMu.Unlock()
+			loaded++
+		}
+		if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+			break
+		}
+	}
+	m.mu.Lock()
+	m.historyMu.Lock()
+	for subject, h := range m.history {
+		m.restoreAvailability(subject, h.list())
+	}
+	m.historyMu.Unlock()
+	m.mu.Unlock()
+
+	m.logger.Info("history restored", "stream", cfg.HistoryStream, "entries", loaded)
+	return nil
+}
+
+func (m *Monitor) historyHandler(w http.ResponseWriter, r *http.Request, subject string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries, ok := m.subjectHistory(subject)
+	if !ok {
+		m.writeJSON(w, http.StatusNotFound, statusapi.AdminResult{Subject: subject, Error: ErrUnknownSubject.Error()})
+		return
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit < len(entries) {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+	m.writeJSON(w, http.StatusOK, statusapi.History{Version: statusapi.Version, Subject: subject, Entries: entries})
+}
+
+// splitSubjectPath splits "/subjects/<subject>[/<op>]" into the subject
+// and the trailing operation.
+func splitSubjectPath(path string) (subject, op string) {
+	subject = strings.TrimPrefix(path, "/subjects/")
+	for _, op := range []string{"history", "silence", "ack"} {
+		if trimmed := strings.TrimSuffix(subject, "/"+op); trimmed != subject {
+			return trimmed, op
+		}
+	}
+	return subject, ""
+}
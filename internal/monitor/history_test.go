@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestHistoryRingKeepsNewestEntries(t *testing.T) {
+	h := newHistory(3)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		h.add(statusapi.HistoryEntry{Kind: historyBeat, At: start.Add(time.Duration(i) * time.Second)})
+	}
+	h.add(statusapi.HistoryEntry{Kind: eventAlert, At: start.Add(10 * time.Second)})
+
+	entries := h.list()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if !entries[0].At.Equal(start.Add(3*time.Second)) || entries[2].Kind != eventAlert {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	beats := h.beatTimes(5)
+	if len(beats) != 2 || !beats[0].Equal(start.Add(3*time.Second)) || !beats[1].Equal(start.Add(4*time.Second)) {
+		t.Fatalf("unexpected beat times: %v", beats)
+	}
+}
+
+func TestHistoryRecordsBeatsAndTransitions(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat"})
+	ctx := context.Background()
+	now := time.Now()
+
+	beat := func(at time.Time, seq uint64) {
+		data, err := heartbeat.Message{Subject: "svc", GeneratedAt: at, Interval: time.Second, Sequence: seq, BootID: "boot"}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, at)
+	}
+	beat(now, 1)
+	m.mu.Lock()
+	m.state["svc"].lastSeen = now.Add(-time.Minute)
+	m.schedule(m.state["svc"], now)
+	m.mu.Unlock()
+	m.scan(ctx)
+	beat(now.Add(time.Second), 2)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subjects/svc/history", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp statusapi.History
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var kinds []string
+	for _, e := range resp.Entries {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []string{historyBeat, eventAlert, historyBeat, eventResolved}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, kinds)
+		}
+	}
+	if resp.Entries[0].Seq != 1 || resp.Entries[2].Seq != 2 {
+		t.Fatalf("unexpected beat entries: %+v", resp.Entries)
+	}
+
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subjects/svc/history?limit=1", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Kind != eventResolved {
+		t.Fatalf("expected only the latest entry, got %+v", resp.Entries)
+	}
+
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subjects/other/history", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown subject, got %d", rec.Code)
+	}
+}
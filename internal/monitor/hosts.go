@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// defaultHostExpiry is how long PerHost keeps a silent host when
+// ExpireAfter is unset, so renamed, replaced or scaled-down hosts stop
+// alerting.
+const defaultHostExpiry = 24 * time.Hour
+
+// hostState tracks one host publishing a subject.
+type hostState struct {
+	lastSeen    time.Time
+	alertActive bool
+	missCount   int
+	lastAlert   time.Time
+}
+
+// hostWindow is how long a host counts as publishing a subject after its
+// last heartbeat.
+func (m *Monitor) hostWindow(s *state) time.Duration {
+	if window := m.config().HostWindow; window > 0 {
+		return window
+	}
+	return 2 * s.allowedWindow()
+}
+
+// hostExpiry is how long PerHost keeps a host that stopped publishing.
+func hostExpiry(cfg *Config) time.Duration {
+	if cfg.ExpireAfter > 0 {
+		return cfg.ExpireAfter
+	}
+	return defaultHostExpiry
+}
+
+// hostsCovered reports whether a dead host of s is already covered by the
+// subject's own alert: the subject is alerting, or the host is its only one.
+func hostsCovered(s *state) bool {
+	return s.alertActive || len(s.hosts) < 2
+}
+
+// trackHost records a heartbeat from host and flags subjects published from
+// more than one host within the host window. Callers must hold m.mu.
+func (m *Monitor) trackHost(ctx context.Context, s *state, host string, receivedAt time.Time) {
+	if host == "" {
+		return
+	}
+	if s.hosts == nil {
+		s.hosts = make(map[string]*hostState)
+	}
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostState{}
+		s.hosts[host] = h
+	}
+	if h.alertActive && s.interval > 0 {
+		// scan only revisits the host once it is due, so count the missed
+		// beats for its resolve notification here
+		h.missCount = int(receivedAt.Sub(h.lastSeen) / s.interval)
+	}
+	h.lastSeen = receivedAt
+
+	window := m.hostWindow(s)
+	var live []string
+	for name, other := range s.hosts {
+		if receivedAt.Sub(other.lastSeen) <= window {
+			live = append(live, name)
+			continue
+		}
+		if !m.config().PerHost {
+			delete(s.hosts, name)
+		}
+	}
+
+	conflict := len(live) > 1
+	if conflict == s.hostConflict {
+		return
+	}
+	s.hostConflict = conflict
+	if !conflict {
+		m.logger.Info("single host restored", "subject", s.subject, "host", host)
+		return
+	}
+
+	sort.Strings(live)
+	m.counters.hostConflicts.Add(1)
+	m.logger.Warn("multiple hosts publishing subject", "subject", s.subject, "hosts", live)
+	evt := s.event()
+	evt.Host = host
+	evt.Reason = fmt.Sprintf("published from multiple hosts: %s", strings.Join(live, ", "))
+	m.notice(ctx, evt)
+}
+
+// scanHosts evaluates liveness per (subject, host) pair when PerHost is
+// enabled. Hosts covered by the subject's own alert are not paged for, and
+// hosts silent for longer than the host expiry are dropped. Callers must
+// hold m.mu and evaluate the subject first.
+func (m *Monitor) scanHosts(now time.Time, s *state) (toAlert, toResolve []notifier.Event) {
+	cfg := m.config()
+	allowed := s.allowedWindow()
+	expiry := hostExpiry(cfg)
+	covered := hostsCovered(s)
+	for name, h := range s.hosts {
+		elapsed := now.Sub(h.lastSeen)
+		evt := s.event()
+		evt.Description = fmt.Sprintf("%s (%s)", s.description, name)
+		evt.Host = name
+		evt.LastSeen = h.lastSeen
+		evt.MissFor = elapsed
+
+		if elapsed > expiry {
+			delete(s.hosts, name)
+			m.logger.Info("host expired", "subject", s.subject, "host", name, "elapsed", elapsed)
+			continue
+		}
+
+		if elapsed <= allowed {
+			if h.alertActive {
+				evt.MissCount = h.missCount
+				toResolve = append(toResolve, evt)
+				h.alertActive = false
+				h.missCount = 0
+				h.lastAlert = time.Time{}
+				m.logger.Debug("host heartbeat recovered", "subject", s.subject, "host", name, "elapsed", elapsed)
+			}
+			continue
+		}
+
+		h.missCount = int(elapsed / s.interval)
+		evt.MissCount = h.missCount
+		if covered {
+			// the subject alert pages for this host; push back the
+			// repeat of an earlier host alert instead of sending it
+			if h.alertActive && now.Sub(h.lastAlert) >= cfg.RepeatEvery {
+				h.lastAlert = now
+			}
+			continue
+		}
+		if !h.alertActive {
+			toAlert = append(toAlert, evt)
+			h.alertActive = true
+			h.lastAlert = now
+			m.logger.Debug("host heartbeat missed threshold", "subject", s.subject, "host", name, "elapsed", elapsed, "allowed", allowed)
+		} else if now.Sub(h.lastAlert) >= cfg.RepeatEvery {
+			toAlert = append(toAlert, evt)
+			h.lastAlert = now
+			m.logger.Debug("host heartbeat still missing, repeating alert", "subject", s.subject, "host", name, "elapsed", elapsed)
+		}
+	}
+	return toAlert, toResolve
+}
+
+func (m *Monitor) hostStatuses(now time.Time, s *state) []statusapi.HostStatus {
+	if len(s.hosts) == 0 {
+		return nil
+	}
+	allowed := s.allowedWindow()
+	out := make([]statusapi.HostStatus, 0, len(s.hosts))
+	for name, h := range s.hosts {
+		out = append(out, statusapi.HostStatus{
+			Host:        name,
+			LastSeen:    h.lastSeen,
+			Missing:     now.Sub(h.lastSeen) > allowed,
+			AlertActive: h.alertActive,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Host < out[j].Host
+	})
+	return out
+}
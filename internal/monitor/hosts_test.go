@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrackHostFlagsConflicts(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{})
+	now := time.Now()
+	s := &state{subject: "svc", description: "svc", interval: time.Second}
+
+	m.trackHost(context.Background(), s, "host-a", now)
+	if s.hostConflict {
+		t.Fatalf("expected no conflict with a single host")
+	}
+	m.trackHost(context.Background(), s, "host-b", now.Add(500*time.Millisecond))
+	if !s.hostConflict {
+		t.Fatalf("expected conflict with two live hosts")
+	}
+
+	// host-a falls outside the 2s window
+	m.trackHost(context.Background(), s, "host-b", now.Add(5*time.Second))
+	if s.hostConflict {
+		t.Fatalf("expected conflict to clear once host-a goes quiet")
+	}
+	if _, ok := s.hosts["host-a"]; ok {
+		t.Fatalf("expected host-a to be pruned without per-host liveness")
+	}
+}
+
+func TestScanHostsAlertsForDeadHost(t *testing.T) {
+	m := New(nil, nil, Config{PerHost: true})
+	now := time.Now()
+	s := &state{
+		subject:     "svc",
+		description: "svc",
+		interval:    time.Second,
+		lastSeen:    now,
+		hosts: map[string]*hostState{
+			"host-a": {lastSeen: now},
+			"host-b": {lastSeen: now.Add(-5 * time.Second)},
+		},
+	}
+
+	alerts, resolves := m.scanHosts(now, s)
+	if len(alerts) != 1 || alerts[0].Host != "host-b" {
+		t.Fatalf("expected alert for host-b, got %+v", alerts)
+	}
+	if len(resolves) != 0 {
+		t.Fatalf("expected no resolves, got %+v", resolves)
+	}
+
+	s.hosts["host-b"].lastSeen = now.Add(time.Second)
+	_, resolves = m.scanHosts(now.Add(time.Second), s)
+	if len(resolves) != 1 || resolves[0].Host != "host-b" {
+		t.Fatalf("expected resolve for host-b, got %+v", resolves)
+	}
+}
+
+func TestPerHostSingleHostPagesOnce(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{PerHost: true, RepeatEvery: time.Hour})
+	now := time.Now()
+
+	m.mu.Lock()
+	m.state["svc"] = &state{
+		subject:     "svc",
+		description: "svc",
+		interval:    10 * time.Second,
+		lastSeen:    now.Add(-time.Minute),
+		hosts: map[string]*hostState{
+			"host-a": {lastSeen: now.Add(-time.Minute)},
+		},
+	}
+	m.schedule(m.state["svc"], now)
+	m.mu.Unlock()
+
+	m.scan(context.Background())
+	m.scan(context.Background())
+
+	if len(rec.alerts) != 1 || rec.alerts[0].Description != "svc" {
+		t.Fatalf("expected a single subject alert, got %+v", rec.alerts)
+	}
+}
+
+func TestPerHostSkipsHostsWhileSubjectAlerts(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{PerHost: true, RepeatEvery: time.Hour})
+	now := time.Now()
+
+	// both hosts die together
+	m.mu.Lock()
+	m.state["svc"] = &state{
+		subject:     "svc",
+		description: "svc",
+		interval:    10 * time.Second,
+		lastSeen:    now.Add(-time.Minute),
+		hosts: map[string]*hostState{
+			"host-a": {lastSeen: now.Add(-time.Minute)},
+			"host-b": {lastSeen: now.Add(-time.Minute - time.Second)},
+		},
+	}
+	m.schedule(m.state["svc"], now)
+	m.mu.Unlock()
+
+	m.scan(context.Background())
+
+	if len(rec.alerts) != 1 || rec.alerts[0].Description != "svc" {
+		t.Fatalf("expected only the subject alert, got %+v", rec.alerts)
+	}
+}
+
+func TestPerHostExpiresSilentHosts(t *testing.T) {
+	m := New(nil, nil, Config{PerHost: true})
+	now := time.Now()
+	s := &state{
+		subject:     "svc",
+		description: "svc",
+		interval:    time.Second,
+		lastSeen:    now,
+		hosts: map[string]*hostState{
+			"host-a": {lastSeen: now},
+			"host-b": {lastSeen: now.Add(-defaultHostExpiry - time.Second), alertActive: true},
+		},
+	}
+
+	alerts, resolves := m.scanHosts(now, s)
+	if len(alerts) != 0 || len(resolves) != 0 {
+		t.Fatalf("expected expired host not to alert, got %+v / %+v", alerts, resolves)
+	}
+	if _, ok := s.hosts["host-b"]; ok {
+		t.Fatalf("expected host-b to be dropped after %s", defaultHostExpiry)
+	}
+}
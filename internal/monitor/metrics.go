@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// counters tracks monitor-wide event totals exposed on /metrics.
+type counters struct {
+	received            atomic.Uint64
+	decodeErrors        atomic.Uint64
+	stale               atomic.Uint64
+	restarts            atomic.Uint64
+	lostBeats           atomic.Uint64
+	duplicatePublishers atomic.Uint64
+	hostConflicts       atomic.Uint64
+	alerts              atomic.Uint64
+	resolves            atomic.Uint64
+	expiries            atomic.Uint64
+	notices             atomic.Uint64
+	silenced            atomic.Uint64
+	verified            atomic.Uint64
+	unsigned            atomic.Uint64
+	invalidSignatures   atomic.Uint64
+	subjectMismatches   atomic.Uint64
+	otherShard          atomic.Uint64
+	rebalances          atomic.Uint64
+}
+
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+func (m *Monitor) metrics() []metric {
+	c := &m.counters
+	counter := func(name, help string, v *atomic.Uint64) metric {
+		return metric{name: name, help: help, kind: "counter", value: func() float64 { return float64(v.Load()) }}
+	}
+	return []metric{
+		counter("heartbeat_messages_received_total", "Heartbeat messages received.", &c.received),
+		counter("heartbeat_decode_errors_total", "Heartbeat messages that failed to decode.", &c.decodeErrors),
+		counter("heartbeat_stale_rejected_total", "Delayed or replayed heartbeats rejected.", &c.stale),
+		counter("heartbeat_restarts_total", "Publisher restarts detected via boot ID changes.", &c.restarts),
+		counter("heartbeat_lost_total", "Heartbeats missing from publisher sequences.", &c.lostBeats),
+		counter("heartbeat_duplicate_publishers_total", "Times concurrent publishers were detected on one subject.", &c.duplicatePublishers),
+		counter("heartbeat_host_conflicts_total", "Times a subject was published from several hosts.", &c.hostConflicts),
+		counter("heartbeat_alerts_total", "Alert notifications sent, including repeats.", &c.alerts),
+		counter("heartbeat_resolves_total", "Resolved notifications sent.", &c.resolves),
+		counter("heartbeat_expiries_total", "Subjects expired after going missing.", &c.expiries),
+		counter("heartbeat_notices_total", "Notices sent (clock skew, duplicate publishers, host conflicts).", &c.notices),
+		counter("heartbeat_silenced_total", "Notifications suppressed because their subject was silenced.", &c.silenced),
+		counter("heartbeat_subject_mismatches_total", "Heartbeats dropped because their payload subject did not match the NATS subject.", &c.subjectMismatches),
+		counter("heartbeat_signatures_verified_total", "Heartbeats with a valid trusted signature.", &c.verified),
+		counter("heartbeat_unsigned_total", "Heartbeats missing a required signature.", &c.unsigned),
+		counter("heartbeat_invalid_signatures_total", "Heartbeats with an invalid or untrusted signature.", &c.invalidSignatures),
+		counter("heartbeat_messages_other_shard_total", "Heartbeat messages skipped because another shard member owns the subject.", &c.otherShard),
+		counter("heartbeat_shard_rebalances_total", "Times the shard ring was rebuilt after members joined or left.", &c.rebalances),
+		{name: "heartbeat_shard_members", help: "Monitors in this monitor's shard group (0 when sharding is off).", kind: "gauge", value: func() float64 {
+			if ring := m.ring.Load(); ring != nil {
+				return float64(len(ring.members))
+			}
+			return 0
+		}},
+	}
+}
+
+type subjectMetrics struct {
+	subject             string
+	restarts            int
+	lostBeats           uint64
+	duplicatePublishers bool
+	hostConflict        bool
+	alertActive         bool
+	interval            time.Duration
+	cadence             cadenceStats
+	hasCadence          bool
+}
+
+func (m *Monitor) subjectMetrics() []subjectMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]subjectMetrics, 0, len(m.state))
+	for _, s := range m.state {
+		stats, hasCadence := s.cadence.stats(s.interval)
+		out = append(out, subjectMetrics{
+			subject:             s.subject,
+			restarts:            s.restarts,
+			lostBeats:           s.lostBeats,
+			duplicatePublishers: s.duplicatePublishers,
+			hostConflict:        s.hostConflict,
+			alertActive:         s.alertActive,
+			interval:            s.interval,
+			cadence:             stats,
+			hasCadence:          hasCadence,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].subject < out[j].subject
+	})
+	return out
+}
+
+func (m *Monitor) metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, m.metrics(), m.subjectMetrics())
+	})
+}
+
+// writeMetrics renders metrics in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, metrics []metric, subjects []subjectMetrics) {
+	for _, mt := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", mt.name, mt.help, mt.name, mt.kind, mt.name, mt.value())
+	}
+
+	perSubject := []struct {
+		name  string
+		help  string
+		kind  string
+		value func(subjectMetrics) float64
+		// cadence series are only written for subjects with samples
+		cadence bool
+	}{
+		{"heartbeat_subject_alert_active", "Whether an alert is firing for the subject.", "gauge", func(s subjectMetrics) float64 { return boolValue(s.alertActive) }, false},
+		{"heartbeat_subject_restarts_total", "Publisher restarts detected for the subject.", "counter", func(s subjectMetrics) float64 { return float64(s.restarts) }, false},
+		{"heartbeat_subject_lost_total", "Heartbeats missing from the subject's sequence.", "counter", func(s subjectMetrics) float64 { return float64(s.lostBeats) }, false},
+		{"heartbeat_subject_duplicate_publishers", "Whether several processes publish the subject.", "gauge", func(s subjectMetrics) float64 { return boolValue(s.duplicatePublishers) }, false},
+		{"heartbeat_subject_host_conflict", "Whether several hosts publish the subject.", "gauge", func(s subjectMetrics) float64 { return boolValue(s.hostConflict) }, false},
+		{"heartbeat_subject_interval_seconds", "Declared heartbeat interval.", "gauge", func(s subjectMetrics) float64 { return s.interval.Seconds() }, false},
+		{"heartbeat_subject_interarrival_mean_seconds", "Mean time between recent heartbeats.", "gauge", func(s subjectMetrics) float64 { return s.cadence.mean.Seconds() }, true},
+		{"heartbeat_subject_interarrival_p95_seconds", "95th percentile time between recent heartbeats.", "gauge", func(s subjectMetrics) float64 { return s.cadence.p95.Seconds() }, true},
+		{"heartbeat_subject_interarrival_max_seconds", "Longest time between recent heartbeats.", "gauge", func(s subjectMetrics) float64 { return s.cadence.max.Seconds() }, true},
+		{"heartbeat_subject_jitter_seconds", "Mean deviation of recent inter-arrival times from the declared interval.", "gauge", func(s subjectMetrics) float64 { return s.cadence.jitter.Seconds() }, true},
+	}
+	for _, mt := range perSubject {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", mt.name, mt.help, mt.name, mt.kind)
+		for _, s := range subjects {
+			if mt.cadence && !s.hasCadence {
+				continue
+			}
+			fmt.Fprintf(w, "%s{subject=%q} %g\n", mt.name, s.subject, mt.value(s))
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
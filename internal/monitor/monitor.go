@@ -12,12 +12,15 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
 
+	"github.com/venkytv/nats-heartbeat/internal/natsconn"
 	"github.com/venkytv/nats-heartbeat/internal/notifier"
 	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
 )
 
 type Config struct {
@@ -28,26 +31,129 @@ type Config struct {
 	Logger      *slog.Logger
 	RepeatEvery time.Duration
 	StatusAddr  string
+	// StatusTLSCert and StatusTLSKey serve the status server over HTTPS
+	// when set.
+	StatusTLSCert string
+	StatusTLSKey  string
+	// StatusClientCA requires status server clients to present a
+	// certificate signed by this CA (mutual TLS). Requires StatusTLSCert.
+	StatusClientCA string
+	// StatusAuth restricts the status server to clients presenting one of
+	// these credentials; reads need RoleRead and admin operations RoleAdmin.
+	// Empty allows every request.
+	StatusAuth []Credential
+	// Clock overrides the time source; nil uses the system clock.
+	Clock Clock
+	// ExpireAfter removes subjects that have been missing for longer than
+	// this duration. Zero disables expiry.
+	ExpireAfter time.Duration
+	// MaxSkew raises a notice when the difference between a heartbeat's
+	// receive time and its generated_at exceeds this duration. Zero disables
+	// skew notices.
+	MaxSkew time.Duration
+	// HostWindow is how long a host counts as publishing a subject after its
+	// last heartbeat; subjects with several live hosts are flagged. Zero
+	// uses twice the subject's allowed window.
+	HostWindow time.Duration
+	// PerHost additionally evaluates liveness for each (subject, host) pair
+	// so a dead instance is not masked by a live one.
+	PerHost bool
+	// TrustRules require heartbeats on matching subjects to be signed by one
+	// of the listed keys. Subjects without a matching rule are not verified.
+	TrustRules []TrustRule
+	// RejectUnverified drops heartbeats that fail verification instead of
+	// accepting them flagged as unverified.
+	RejectUnverified bool
+	// AdminSubject enables NATS request-reply admin operations under
+	// "<AdminSubject>.>" when set.
+	AdminSubject string
+	// EventSubject publishes every notification as JSON on
+	// "<EventSubject>.<kind>.<subject>" in addition to the notifier. The
+	// monitor ignores messages under it, so it may sit inside Prefix
+	// (e.g. "heartbeat.events").
+	EventSubject string
+	// EventStream publishes events through JetStream into this stream,
+	// created if missing. Requires EventSubject.
+	EventStream string
+	// ServiceSubject registers the monitor as a NATS micro service named
+	// ServiceName with read-only status endpoints under "<ServiceSubject>."
+	// when set.
+	ServiceSubject string
+	// HistorySize is how many beats and notifications are kept per subject.
+	// Defaults to 100.
+	HistorySize int
+	// HistoryStream persists history to this JetStream stream, created if
+	// missing, and restores it on startup. Empty keeps history in memory
+	// only.
+	HistoryStream string
+	// HistorySubject is the subject prefix history entries are published
+	// under. Defaults to "heartbeat-history"; keep it outside Prefix.
+	HistorySubject string
+	// UptimeWindows are the trailing windows reported per subject in the
+	// status API. Defaults to 24h, 7d and 30d; the longest also bounds how
+	// long outages are kept.
+	UptimeWindows []time.Duration
+	// ShardGroup splits the subject space between every monitor started
+	// with the same ShardGroup by consistent hashing on the subject. Members
+	// announce themselves on "<ShardGroup>.members" (keep it outside
+	// Prefix) and rebalance as monitors join and leave. Empty monitors
+	// every subject.
+	ShardGroup string
+	// ShardMember names this monitor in its shard group; names must be
+	// unique and stable across restarts. Defaults to the hostname.
+	ShardMember string
+	// ShardHeartbeat is how often a sharded monitor announces itself.
+	// Members that miss three announcements leave the ring. Defaults to 2s.
+	ShardHeartbeat time.Duration
+	// MaxDrift raises a notice when a subject's p95 inter-arrival time
+	// deviates from its declared interval by more than this fraction (e.g.
+	// 0.25 for 25%). Zero disables drift notices.
+	MaxDrift float64
 }
 
 type Monitor struct {
-	cfg      Config
-	nc       *nats.Conn
-	notifier notifier.Notifier
-	logger   *slog.Logger
+	nc     *nats.Conn
+	logger *slog.Logger
+	clock  Clock
+
+	// settings holds the reloadable Config and notifier; see Reload.
+	settings atomic.Pointer[settings]
+	reloaded chan struct{}
+	reloadMu sync.Mutex
+	reloadFn ReloadFunc
 
 	mu    sync.Mutex
 	state map[string]*state
+	// queue orders state by next deadline and lastPrune is when scan last
+	// pruned outages; both are guarded by mu.
+	queue     deadlines
+	lastPrune time.Time
+
+	counters counters
+	events   eventHub
+	// eventNotifier publishes notifications on EventSubject; nil when
+	// disabled.
+	eventNotifier *notifier.NATS
+
+	// uptime is guarded by mu.
+	uptime map[string]*availability
+
+	historyMu   sync.Mutex
+	history     map[string]*history
+	historySize int
+
+	// shardID names this monitor in its shard group; ring is nil when
+	// sharding is off.
+	shardID string
+	ring    atomic.Pointer[hashRing]
+	shard   shardState
 }
 
 func New(nc *nats.Conn, n notifier.Notifier, cfg Config) *Monitor {
-	if cfg.PollEvery <= 0 {
-		cfg.PollEvery = time.Second
-	}
-	if cfg.RepeatEvery <= 0 {
-		cfg.RepeatEvery = 12 * time.Hour
+	cfg = normalizeConfig(cfg)
+	if n == nil {
+		n = notifier.Nop{}
 	}
-	cfg.Prefix = strings.TrimSuffix(cfg.Prefix, ".")
 	logger := cfg.Logger
 	if logger == nil {
 		level := slog.LevelInfo
@@ -58,45 +164,146 @@ func New(nc *nats.Conn, n notifier.Notifier, cfg Config) *Monitor {
 			Level: level,
 		}))
 	}
-	return &Monitor{
-		cfg:      cfg,
+	clock := cfg.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	m := &Monitor{
 		nc:       nc,
-		notifier: n,
 		logger:   logger,
+		clock:    clock,
+		reloaded: make(chan struct{}, 1),
 		state:    make(map[string]*state),
+		history:  make(map[string]*history),
+		uptime:   make(map[string]*availability),
+
+		historySize: cfg.HistorySize,
+		shardID:     shardMember(cfg),
 	}
+	if cfg.EventSubject != "" {
+		m.eventNotifier = &notifier.NATS{Conn: nc, Subject: cfg.EventSubject, Stream: cfg.EventStream}
+	}
+	m.settings.Store(&settings{cfg: cfg, notifier: n})
+	return m
+}
+
+func normalizeConfig(cfg Config) Config {
+	if cfg.PollEvery <= 0 {
+		cfg.PollEvery = time.Second
+	}
+	if cfg.RepeatEvery <= 0 {
+		cfg.RepeatEvery = 12 * time.Hour
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = defaultHistorySize
+	}
+	if len(cfg.UptimeWindows) == 0 {
+		cfg.UptimeWindows = defaultUptimeWindows
+	}
+	if cfg.HistorySubject == "" {
+		cfg.HistorySubject = defaultHistorySubject
+	}
+	if cfg.ShardHeartbeat <= 0 {
+		cfg.ShardHeartbeat = defaultShardHeartbeat
+	}
+	cfg.Prefix = strings.TrimSuffix(cfg.Prefix, ".")
+	cfg.HistorySubject = strings.TrimSuffix(cfg.HistorySubject, ".")
+	cfg.EventSubject = strings.TrimSuffix(cfg.EventSubject, ".")
+	cfg.ShardGroup = strings.TrimSuffix(cfg.ShardGroup, ".")
+	return cfg
+}
+
+// config returns the current configuration. Callers must not modify it.
+func (m *Monitor) config() *Config {
+	return &m.settings.Load().cfg
+}
+
+// notify returns the configured notifier, combined with the event
+// publisher when EventSubject is set.
+func (m *Monitor) notify() notifier.Notifier {
+	n := m.settings.Load().notifier
+	if m.eventNotifier != nil {
+		return notifier.Multi{m.eventNotifier, n}
+	}
+	return n
 }
 
 func (m *Monitor) Start(ctx context.Context) error {
 	if m.nc == nil {
 		return errors.New("nats connection is required")
 	}
-	if m.notifier == nil {
-		m.notifier = notifier.Nop{}
+	cfg := m.config()
+	// a member taking over subjects reads their last beats from the prime
+	// stream; without it a silent subject would go unwatched
+	if cfg.ShardGroup != "" && cfg.PrimeStream == "" {
+		return errors.New("a shard group requires a prime stream")
+	}
+
+	var statusErrCh chan error
+	if cfg.StatusAddr != "" {
+		statusErrCh = make(chan error, 1)
+		go m.serveStatus(ctx, statusErrCh)
+	}
+
+	if cfg.PrimeStream != "" || cfg.HistoryStream != "" || cfg.ShardGroup != "" {
+		if !m.nc.IsConnected() {
+			m.logger.Info("waiting for nats connection before loading streams", "prime_stream", cfg.PrimeStream, "history_stream", cfg.HistoryStream, "shard_group", cfg.ShardGroup)
+		}
+		if err := natsconn.WaitConnected(ctx, m.nc); err != nil {
+			m.logger.Info("monitor stopping")
+			return nil
+		}
+	}
+	if cfg.ShardGroup != "" {
+		shardSub, err := m.joinShard(ctx)
+		if err != nil {
+			return fmt.Errorf("join shard group: %w", err)
+		}
+		defer m.leaveShard(shardSub)
+	}
+	if cfg.HistoryStream != "" {
+		if err := m.loadHistory(ctx); err != nil {
+			m.logger.Warn("load history failed", "stream", cfg.HistoryStream, "err", err)
+		}
 	}
-	if m.cfg.PrimeStream != "" {
+	if cfg.PrimeStream != "" {
 		if err := m.primeCache(ctx); err != nil {
 			m.logger.Warn("prime cache failed", "err", err)
 		}
 	}
 
+	// subscriptions made while disconnected are sent once the connection
+	// is (re)established
 	subject := m.subscribeSubject()
 	sub, err := m.nc.Subscribe(subject, func(msg *nats.Msg) {
-		m.handleMessage(ctx, msg)
+		m.handleMessage(ctx, msg, m.clock.Now())
 	})
 	if err != nil {
 		return err
 	}
-	m.logger.Info("monitor subscribed", "subject", subject, "prime_stream", m.cfg.PrimeStream)
+	m.logger.Info("monitor subscribed", "subject", subject, "prime_stream", cfg.PrimeStream)
 	defer sub.Unsubscribe()
 
-	ticker := time.NewTicker(m.cfg.PollEvery)
-	defer ticker.Stop()
+	if cfg.AdminSubject != "" {
+		adminSub, err := m.subscribeAdmin(ctx)
+		if err != nil {
+			return fmt.Errorf("admin subscribe: %w", err)
+		}
+		defer adminSub.Unsubscribe()
+	}
 
-	var statusErrCh chan error
-	if m.cfg.StatusAddr != "" {
-		statusErrCh = make(chan error, 1)
-		go m.serveStatus(ctx, statusErrCh)
+	if cfg.ServiceSubject != "" {
+		svc, err := m.addService()
+		if err != nil {
+			return fmt.Errorf("register service: %w", err)
+		}
+		defer svc.Stop()
+	}
+
+	ticker := m.clock.NewTicker(cfg.PollEvery)
+	defer ticker.Stop()
+	if cfg.ShardGroup != "" {
+		go m.runShard(ctx)
 	}
 
 	for {
@@ -111,18 +318,43 @@ func (m *Monitor) Start(ctx context.Context) error {
 			if !ok {
 				statusErrCh = nil
 			}
-		case <-ticker.C:
+		case <-m.reloaded:
+			ticker.Reset(m.config().PollEvery)
+		case <-ticker.C():
 			m.scan(ctx)
 		}
 	}
 }
 
-func (m *Monitor) handleMessage(ctx context.Context, msg *nats.Msg) {
+func (m *Monitor) handleMessage(ctx context.Context, msg *nats.Msg, receivedAt time.Time) {
+	if m.isEventSubject(msg.Subject) {
+		return
+	}
+	// other shards' subjects are dropped before decoding, verifying or
+	// taking the lock; the payload is bound to the NATS subject below
+	if !m.owns(msg.Subject) {
+		m.counters.otherShard.Add(1)
+		return
+	}
 	hb, err := heartbeat.Unmarshal(msg.Data)
+	m.counters.received.Add(1)
 	if err != nil {
+		m.counters.decodeErrors.Add(1)
 		m.logger.Error("failed to decode heartbeat", "subject", msg.Subject, "err", err)
 		return
 	}
+	// state and trust rules are keyed by the payload subject and sharding by
+	// the NATS subject, so the two must agree
+	if msg.Subject != m.natsSubjectFor(hb.Subject) {
+		m.counters.subjectMismatches.Add(1)
+		m.logger.Warn("heartbeat subject does not match NATS subject", "subject", msg.Subject, "payload_subject", hb.Subject)
+		return
+	}
+
+	unverified, ok := m.checkSignature(msg, hb.Subject)
+	if !ok {
+		return
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -130,113 +362,296 @@ func (m *Monitor) handleMessage(ctx context.Context, msg *nats.Msg) {
 	s, ok := m.state[hb.Subject]
 	if !ok {
 		newState := newState(hb)
+		newState.natsSubject = msg.Subject
+		newState.trackInstance(hb, receivedAt)
+		newState.observe(hb, receivedAt)
+		m.recordBeat(hb, receivedAt)
+		m.trackAvailability(hb.Subject, receivedAt)
+		m.markUp(hb.Subject, receivedAt)
+		m.markVerification(&newState, unverified)
 		m.state[hb.Subject] = &newState
-		m.logger.Debug("new heartbeat subject added", "subject", hb.Subject, "interval", hb.Interval, "grace", hb.GracePeriod)
+		m.trackHost(ctx, &newState, hb.Host, receivedAt)
+		m.schedule(&newState, receivedAt)
+		m.events.touch()
+		m.logger.Debug("new heartbeat subject added", "subject", hb.Subject, "interval", hb.Interval, "grace", hb.GracePeriod, "skew", newState.skew)
+		m.checkSkew(ctx, &newState)
+		return
+	}
+
+	if s.isStale(hb, receivedAt) {
+		m.counters.stale.Add(1)
+		m.logger.Debug("stale heartbeat rejected", "subject", hb.Subject, "generated_at", hb.GeneratedAt, "last_generated_at", s.generatedAt, "received_at", receivedAt)
 		return
 	}
 
-	s.lastSeen = hb.GeneratedAt
-	s.interval = hb.Interval
-	s.grace = hb.GracePeriod
-	s.host = hb.Host
-	s.description = descriptionOrSubject(hb)
-	m.logger.Debug("heartbeat updated", "subject", hb.Subject, "interval", hb.Interval, "grace", hb.GracePeriod)
+	gap, hasGap := s.interArrival(hb, receivedAt)
+	change := s.trackInstance(hb, receivedAt)
+	s.natsSubject = msg.Subject
+	s.observe(hb, receivedAt)
+	m.recordBeat(hb, receivedAt)
+	m.markVerification(s, unverified)
+	m.trackHost(ctx, s, hb.Host, receivedAt)
+	m.recordInstanceChange(ctx, s, hb, change)
+	// every accepted beat moves last seen, sequence and skew; /events
+	// streams throttle the diffs this triggers
+	m.events.touch()
+	m.logger.Debug("heartbeat updated", "subject", hb.Subject, "interval", hb.Interval, "grace", hb.GracePeriod, "skew", s.skew)
+	m.checkSkew(ctx, s)
+	if hasGap {
+		s.cadence.add(gap)
+		m.checkDrift(ctx, s)
+	}
 
 	if s.alertActive {
 		s.alertActive = false
+		s.acked = false
 		s.missCount = 0
-		go m.notifier.Resolved(ctx, notifier.Event{
-			Subject:     s.subject,
-			Description: s.description,
-			Host:        s.host,
-			LastSeen:    s.lastSeen,
-			Interval:    s.interval,
-		})
+		m.markUp(s.subject, receivedAt)
+		m.counters.resolves.Add(1)
+		evt := s.event()
+		m.emit(eventResolved, evt)
+		if s.silenced(receivedAt) {
+			m.suppress(evt)
+		} else {
+			go m.notify().Resolved(ctx, evt)
+		}
 		m.logger.Debug("resolved state on heartbeat", "subject", s.subject, "last_seen", s.lastSeen)
 	}
+	m.schedule(s, receivedAt)
+}
+
+// checkSignature verifies msg, a heartbeat for subject, against the
+// configured trust rules. It returns whether the message should be flagged
+// as unverified and whether it should be processed at all.
+func (m *Monitor) checkSignature(msg *nats.Msg, subject string) (unverified bool, ok bool) {
+	cfg := m.config()
+	if len(cfg.TrustRules) == 0 {
+		return false, true
+	}
+	err := m.verifyMessage(msg, subject)
+	switch {
+	case err == nil:
+		m.counters.verified.Add(1)
+		return false, true
+	case errors.Is(err, errNoTrustRule):
+		return false, true
+	case errors.Is(err, heartbeat.ErrUnsigned):
+		m.counters.unsigned.Add(1)
+	default:
+		m.counters.invalidSignatures.Add(1)
+	}
+
+	if cfg.RejectUnverified {
+		m.logger.Warn("heartbeat rejected", "subject", msg.Subject, "err", err)
+		return true, false
+	}
+	m.logger.Debug("heartbeat failed verification", "subject", msg.Subject, "err", err)
+	return true, true
+}
+
+// markVerification records whether the latest heartbeat for s passed
+// signature verification. Callers must hold m.mu.
+func (m *Monitor) markVerification(s *state, unverified bool) {
+	if unverified && !s.unverified {
+		m.logger.Warn("accepting unverified heartbeats", "subject", s.subject, "host", s.host)
+	}
+	s.unverified = unverified
+}
+
+// checkSkew raises a notice when a subject's clock skew first exceeds
+// MaxSkew. Callers must hold m.mu.
+func (m *Monitor) checkSkew(ctx context.Context, s *state) {
+	maxSkew := m.config().MaxSkew
+	if maxSkew <= 0 {
+		return
+	}
+	exceeded := absDuration(s.skew) > maxSkew
+	if exceeded == s.skewExceeded {
+		return
+	}
+	s.skewExceeded = exceeded
+	if !exceeded {
+		m.logger.Info("clock skew back within threshold", "subject", s.subject, "skew", s.skew, "max_skew", maxSkew)
+		return
+	}
+
+	m.logger.Warn("clock skew exceeds threshold", "subject", s.subject, "host", s.host, "skew", s.skew, "max_skew", maxSkew)
+	evt := s.event()
+	evt.Reason = fmt.Sprintf("clock skew %s exceeds %s", s.skew.Round(time.Millisecond), maxSkew)
+	m.notice(ctx, evt)
+}
+
+// recordInstanceChange logs and counts restarts, lost beats and duplicate
+// publishers reported by trackInstance. Callers must hold m.mu.
+func (m *Monitor) recordInstanceChange(ctx context.Context, s *state, hb heartbeat.Message, change instanceChange) {
+	if change.restarted {
+		m.counters.restarts.Add(1)
+		m.logger.Info("publisher restarted", "subject", s.subject, "host", s.host, "boot_id", hb.BootID, "seq", hb.Sequence)
+	}
+	if change.lost > 0 {
+		m.counters.lostBeats.Add(change.lost)
+		m.logger.Info("heartbeats lost", "subject", s.subject, "host", s.host, "boot_id", hb.BootID, "lost", change.lost, "seq", hb.Sequence)
+	}
+	if !change.duplicate {
+		return
+	}
+	if !s.duplicatePublishers {
+		m.logger.Info("single publisher restored", "subject", s.subject, "host", s.host)
+		return
+	}
+
+	m.counters.duplicatePublishers.Add(1)
+	m.logger.Warn("multiple publishers detected", "subject", s.subject, "host", s.host, "boot_ids", len(s.boots))
+	if s.hostConflict {
+		// already reported by trackHost
+		return
+	}
+	evt := s.event()
+	evt.Reason = fmt.Sprintf("%d processes are publishing this heartbeat", len(s.boots))
+	m.notice(ctx, evt)
+}
+
+// notice sends evt to the notifier without blocking the caller.
+func (m *Monitor) notice(ctx context.Context, evt notifier.Event) {
+	m.counters.notices.Add(1)
+	m.emit(eventNotice, evt)
+	go func() {
+		if err := m.notify().Notice(ctx, evt); err != nil {
+			m.logger.Error("notice notify failed", "subject", evt.Subject, "err", err)
+		}
+	}()
 }
 
 func (m *Monitor) scan(ctx context.Context) {
-	now := time.Now()
+	cfg := m.config()
+	now := m.clock.Now()
 	var toAlert []notifier.Event
 	var toResolve []notifier.Event
+	var toExpire []notifier.Event
+	var toPurge []string
+	var toForget []string
+	silenced := make(map[string]bool)
 
 	m.mu.Lock()
-	for _, s := range m.state {
+	for _, s := range m.popDue(now) {
 		elapsed := now.Sub(s.lastSeen)
 		allowed := s.allowedWindow()
+		if s.silenced(now) {
+			silenced[s.subject] = true
+		}
+
+		if cfg.ExpireAfter > 0 && elapsed > cfg.ExpireAfter {
+			evt := s.event()
+			evt.MissFor, evt.MissCount = elapsed, int(elapsed/s.interval)
+			toExpire = append(toExpire, evt)
+			toPurge = append(toPurge, s.natsSubject)
+			toForget = append(toForget, s.subject)
+			delete(m.state, s.subject)
+			delete(m.uptime, s.subject)
+			m.logger.Info("heartbeat expired", "subject", s.subject, "elapsed", elapsed, "expire_after", cfg.ExpireAfter)
+			continue
+		}
 
-		if elapsed <= allowed {
+		switch {
+		case elapsed <= allowed:
 			if s.alertActive {
-				toResolve = append(toResolve, notifier.Event{
-					Subject:     s.subject,
-					Description: s.description,
-					Host:        s.host,
-					LastSeen:    s.lastSeen,
-					Interval:    s.interval,
-					MissFor:     elapsed,
-					MissCount:   s.missCount,
-				})
+				evt := s.event()
+				evt.MissFor, evt.MissCount = elapsed, s.missCount
+				toResolve = append(toResolve, evt)
 				s.alertActive = false
+				s.acked = false
 				s.missCount = 0
 				s.lastAlert = time.Time{}
+				m.markUp(s.subject, s.lastSeen)
 				m.logger.Debug("heartbeat recovered", "subject", s.subject, "elapsed", elapsed, "allowed", allowed)
 			}
-			continue
-		}
-
-		s.missCount = int(elapsed / s.interval)
-		if !s.alertActive {
-			toAlert = append(toAlert, notifier.Event{
-				Subject:     s.subject,
-				Description: s.description,
-				Host:        s.host,
-				LastSeen:    s.lastSeen,
-				Interval:    s.interval,
-				MissFor:     elapsed,
-				MissCount:   s.missCount,
-			})
+		case !s.alertActive:
+			s.missCount = int(elapsed / s.interval)
+			evt := s.event()
+			evt.MissFor, evt.MissCount = elapsed, s.missCount
+			toAlert = append(toAlert, evt)
 			s.alertActive = true
 			s.lastAlert = now
+			m.markDown(s.subject, s.lastSeen.Add(allowed))
 			m.logger.Debug("heartbeat missed threshold", "subject", s.subject, "elapsed", elapsed, "allowed", allowed, "miss_count", s.missCount)
-		} else if now.Sub(s.lastAlert) >= m.cfg.RepeatEvery {
-			toAlert = append(toAlert, notifier.Event{
-				Subject:     s.subject,
-				Description: s.description,
-				Host:        s.host,
-				LastSeen:    s.lastSeen,
-				Interval:    s.interval,
-				MissFor:     elapsed,
-				MissCount:   s.missCount,
-			})
+		case !s.acked && now.Sub(s.lastAlert) >= cfg.RepeatEvery:
+			s.missCount = int(elapsed / s.interval)
+			evt := s.event()
+			evt.MissFor, evt.MissCount = elapsed, s.missCount
+			toAlert = append(toAlert, evt)
 			s.lastAlert = now
-			m.logger.Debug("heartbeat still missing, repeating alert", "subject", s.subject, "elapsed", elapsed, "allowed", allowed, "miss_count", s.missCount, "repeat_every", m.cfg.RepeatEvery)
+			m.logger.Debug("heartbeat still missing, repeating alert", "subject", s.subject, "elapsed", elapsed, "allowed", allowed, "miss_count", s.missCount, "repeat_every", cfg.RepeatEvery)
 		}
+		if cfg.PerHost {
+			hostAlerts, hostResolves := m.scanHosts(now, s)
+			toAlert = append(toAlert, hostAlerts...)
+			toResolve = append(toResolve, hostResolves...)
+		}
+		m.schedule(s, now)
+	}
+	if now.Sub(m.lastPrune) >= pruneEvery {
+		m.pruneAvailability(now)
+		m.lastPrune = now
 	}
 	m.mu.Unlock()
 
+	m.counters.alerts.Add(uint64(len(toAlert)))
+	m.counters.resolves.Add(uint64(len(toResolve)))
+	m.counters.expiries.Add(uint64(len(toExpire)))
+	// quiet polls leave /events clients alone; elapsed times are not diffed
+	if len(toAlert) > 0 || len(toResolve) > 0 || len(toExpire) > 0 {
+		m.events.touch()
+	}
 	for _, evt := range toAlert {
-		if err := m.notifier.Alert(ctx, evt); err != nil {
+		m.emit(eventAlert, evt)
+		if silenced[evt.Subject] {
+			m.suppress(evt)
+			continue
+		}
+		if err := m.notify().Alert(ctx, evt); err != nil {
 			m.logger.Error("alert notify failed", "subject", evt.Subject, "err", err)
 		}
 	}
 	for _, evt := range toResolve {
-		if err := m.notifier.Resolved(ctx, evt); err != nil {
+		m.emit(eventResolved, evt)
+		if silenced[evt.Subject] {
+			m.suppress(evt)
+			continue
+		}
+		if err := m.notify().Resolved(ctx, evt); err != nil {
 			m.logger.Error("resolved notify failed", "subject", evt.Subject, "err", err)
 		}
 	}
+	for _, evt := range toExpire {
+		m.emit(eventExpired, evt)
+		if silenced[evt.Subject] {
+			m.suppress(evt)
+			continue
+		}
+		if err := m.notify().Expired(ctx, evt); err != nil {
+			m.logger.Error("expired notify failed", "subject", evt.Subject, "err", err)
+		}
+	}
+	for _, subject := range toPurge {
+		m.purgeSubject(subject)
+	}
+	// after the expired events, which are recorded in the history too
+	for _, subject := range toForget {
+		m.forgetHistory(subject)
+	}
 }
 
 func (m *Monitor) primeCache(ctx context.Context) error {
+	cfg := m.config()
 	js, err := m.nc.JetStream()
 	if err != nil {
 		return err
 	}
 
 	subject := m.subscribeSubject()
-	m.logger.Info("priming cache from stream", "stream", m.cfg.PrimeStream, "subject", subject)
+	m.logger.Info("priming cache from stream", "stream", cfg.PrimeStream, "subject", subject)
 	sub, err := js.SubscribeSync(subject,
-		nats.BindStream(m.cfg.PrimeStream),
+		nats.BindStream(cfg.PrimeStream),
 		nats.ManualAck(),
 		nats.DeliverLastPerSubject(),
 		nats.MaxDeliver(1),
@@ -256,41 +671,80 @@ func (m *Monitor) primeCache(ctx context.Context) error {
 			}
 			return err
 		}
-		m.handleMessage(ctx, msg)
+		if hb, err := heartbeat.Unmarshal(msg.Data); err == nil && m.tracking(hb.Subject) {
+			// re-priming after a shard rebalance; the live beat is newer
+			_ = msg.Ack()
+			continue
+		}
+		receivedAt := m.clock.Now()
+		if meta, err := msg.Metadata(); err == nil {
+			receivedAt = meta.Timestamp
+		}
+		m.handleMessage(ctx, msg, receivedAt)
 		_ = msg.Ack()
 	}
 }
 
+// natsSubjectFor returns the NATS subject heartbeats for subject arrive on:
+// subject itself when it is already under Prefix, as the agent publishes,
+// and otherwise subject under Prefix, as a prefixed Publisher does.
+func (m *Monitor) natsSubjectFor(subject string) string {
+	prefix := m.config().Prefix
+	if prefix == "" || strings.HasPrefix(subject, prefix+".") {
+		return subject
+	}
+	return prefix + "." + subject
+}
+
+// tracking reports whether the monitor has state for subject.
+func (m *Monitor) tracking(subject string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.state[subject]
+	return ok
+}
+
 func (m *Monitor) subscribeSubject() string {
-	if m.cfg.Prefix == "" {
+	prefix := m.config().Prefix
+	if prefix == "" {
 		return ">"
 	}
-	return fmt.Sprintf("%s.>", m.cfg.Prefix)
+	return fmt.Sprintf("%s.>", prefix)
 }
 
-type statusResponse struct {
-	ObservedAt time.Time      `json:"observed_at"`
-	Subjects   []subjectState `json:"subjects"`
+// isEventSubject reports whether subject is one of the monitor's own
+// published events.
+func (m *Monitor) isEventSubject(subject string) bool {
+	prefix := m.config().EventSubject
+	return prefix != "" && strings.HasPrefix(subject, prefix+".")
 }
 
-type subjectState struct {
-	Subject       string    `json:"subject"`
-	Description   string    `json:"description"`
-	Host          string    `json:"host,omitempty"`
-	LastSeen      time.Time `json:"last_seen"`
-	Interval      string    `json:"interval"`
-	Grace         *string   `json:"grace,omitempty"`
-	AllowedWindow string    `json:"allowed_window"`
-	Missing       bool      `json:"missing"`
-	MissFor       string    `json:"miss_for,omitempty"`
-	MissCount     int       `json:"miss_count,omitempty"`
-	AlertActive   bool      `json:"alert_active"`
+// Handler returns the status and admin HTTP handler served on StatusAddr.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", m.statusHandler())
+	mux.Handle("/subjects/", m.subjectsHandler())
+	mux.Handle("/metrics", m.metricsHandler())
+	mux.Handle("/reload", m.reloadHandler())
+	mux.Handle("/events", m.eventsHandler())
+	mux.Handle("/report", m.reportHandler())
+	mux.Handle("/ui", m.uiHandler())
+	mux.Handle("/ui/", m.uiHandler())
+	return m.authorize(mux)
 }
 
 func (m *Monitor) serveStatus(ctx context.Context, errCh chan<- error) {
+	cfg := m.config()
+	tlsCfg, err := statusTLSConfig(*cfg)
+	if err != nil {
+		errCh <- err
+		close(errCh)
+		return
+	}
 	server := &http.Server{
-		Addr:    m.cfg.StatusAddr,
-		Handler: m.statusHandler(),
+		Addr:      cfg.StatusAddr,
+		Handler:   m.Handler(),
+		TLSConfig: tlsCfg,
 		BaseContext: func(_ net.Listener) context.Context {
 			return ctx
 		},
@@ -305,8 +759,13 @@ func (m *Monitor) serveStatus(ctx context.Context, errCh chan<- error) {
 		}
 	}()
 
-	m.logger.Info("status server starting", "addr", m.cfg.StatusAddr)
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	m.logger.Info("status server starting", "addr", cfg.StatusAddr, "tls", tlsCfg != nil, "mtls", cfg.StatusClientCA != "", "auth", len(cfg.StatusAuth) > 0)
+	if tlsCfg != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		errCh <- err
 	}
 	close(errCh)
@@ -314,10 +773,12 @@ func (m *Monitor) serveStatus(ctx context.Context, errCh chan<- error) {
 
 func (m *Monitor) statusHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		observedAt := time.Now()
-		resp := statusResponse{
+		observedAt := m.clock.Now()
+		resp := statusapi.Status{
+			Version:    statusapi.Version,
 			ObservedAt: observedAt,
 			Subjects:   m.snapshot(observedAt),
+			Shard:      m.shardStatus(),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -331,43 +792,13 @@ func (m *Monitor) statusHandler() http.Handler {
 	})
 }
 
-func (m *Monitor) snapshot(now time.Time) []subjectState {
+func (m *Monitor) snapshot(now time.Time) []statusapi.SubjectStatus {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	subjects := make([]subjectState, 0, len(m.state))
+	subjects := make([]statusapi.SubjectStatus, 0, len(m.state))
 	for _, s := range m.state {
-		allowed := s.allowedWindow()
-		elapsed := now.Sub(s.lastSeen)
-		missing := elapsed > allowed
-
-		var missFor string
-		var missCount int
-		if missing {
-			missFor = elapsed.String()
-			if s.interval > 0 {
-				missCount = int(elapsed / s.interval)
-			}
-		}
-
-		subject := subjectState{
-			Subject:       s.subject,
-			Description:   s.description,
-			Host:          s.host,
-			LastSeen:      s.lastSeen,
-			Interval:      s.interval.String(),
-			AllowedWindow: allowed.String(),
-			Missing:       missing,
-			MissFor:       missFor,
-			MissCount:     missCount,
-			AlertActive:   s.alertActive,
-		}
-		if s.grace != nil && *s.grace > 0 {
-			grace := (*s.grace).String()
-			subject.Grace = &grace
-		}
-
-		subjects = append(subjects, subject)
+		subjects = append(subjects, m.subjectStatus(now, s))
 	}
 
 	sort.Slice(subjects, func(i, j int) bool {
@@ -376,3 +807,70 @@ func (m *Monitor) snapshot(now time.Time) []subjectState {
 
 	return subjects
 }
+
+// subjectInfo returns the status API view of one subject.
+func (m *Monitor) subjectInfo(subject string) (statusapi.SubjectStatus, bool) {
+	now := m.clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.state[subject]
+	if !ok {
+		return statusapi.SubjectStatus{}, false
+	}
+	return m.subjectStatus(now, s), true
+}
+
+// subjectStatus builds the status API view of s. Callers must hold m.mu.
+func (m *Monitor) subjectStatus(now time.Time, s *state) statusapi.SubjectStatus {
+	allowed := s.allowedWindow()
+	elapsed := now.Sub(s.lastSeen)
+	missing := elapsed > allowed
+
+	var missFor string
+	var missCount int
+	if missing {
+		missFor = elapsed.String()
+		if s.interval > 0 {
+			missCount = int(elapsed / s.interval)
+		}
+	}
+
+	subject := statusapi.SubjectStatus{
+		Subject:       s.subject,
+		Description:   s.description,
+		Host:          s.host,
+		LastSeen:      s.lastSeen,
+		GeneratedAt:   s.generatedAt,
+		Skew:          s.skew.Round(time.Millisecond).String(),
+		SkewExceeded:  s.skewExceeded,
+		BootID:        s.bootID,
+		Sequence:      s.boots[s.bootID].seq,
+		Restarts:      s.restarts,
+		LastRestart:   s.lastRestart,
+		LostBeats:     s.lostBeats,
+		Duplicate:     s.duplicatePublishers,
+		HostConflict:  s.hostConflict,
+		Hosts:         m.hostStatuses(now, s),
+		Unverified:    s.unverified,
+		Interval:      s.interval.String(),
+		AllowedWindow: allowed.String(),
+		Missing:       missing,
+		MissFor:       missFor,
+		MissCount:     missCount,
+		AlertActive:   s.alertActive,
+		Acknowledged:  s.acked,
+		RecentBeats:   m.recentBeats(s.subject),
+		Uptime:        m.uptimeWindows(s.subject, now),
+		Cadence:       s.cadenceStatus(),
+	}
+	if s.grace != nil && *s.grace > 0 {
+		grace := (*s.grace).String()
+		subject.Grace = &grace
+	}
+	if s.silenced(now) {
+		until := s.silencedUntil
+		subject.SilencedUntil = &until
+	}
+
+	return subject
+}
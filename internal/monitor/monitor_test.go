@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	alerts   []notifier.Event
+	resolved []notifier.Event
+	expired  []notifier.Event
+	notices  []notifier.Event
+}
+
+func (r *recordingNotifier) Alert(_ context.Context, evt notifier.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, evt)
+	return nil
+}
+
+func (r *recordingNotifier) Resolved(_ context.Context, evt notifier.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolved = append(r.resolved, evt)
+	return nil
+}
+
+func (r *recordingNotifier) Expired(_ context.Context, evt notifier.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expired = append(r.expired, evt)
+	return nil
+}
+
+func (r *recordingNotifier) Notice(_ context.Context, evt notifier.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notices = append(r.notices, evt)
+	return nil
+}
+
+func TestScanExpiresSubjectsPastThreshold(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{ExpireAfter: time.Minute})
+	now := time.Now()
+
+	m.mu.Lock()
+	m.state["svc-gone"] = &state{
+		subject:     "svc-gone",
+		description: "svc-gone",
+		lastSeen:    now.Add(-2 * time.Minute),
+		interval:    time.Second,
+		alertActive: true,
+	}
+	m.recordBeat(heartbeat.Message{Subject: "svc-gone", GeneratedAt: now.Add(-2 * time.Minute)}, now.Add(-2*time.Minute))
+	m.state["svc-late"] = &state{
+		subject:     "svc-late",
+		description: "svc-late",
+		lastSeen:    now.Add(-30 * time.Second),
+		interval:    time.Second,
+	}
+	m.rescheduleAll(now)
+	m.mu.Unlock()
+
+	m.scan(context.Background())
+
+	if len(rec.expired) != 1 || rec.expired[0].Subject != "svc-gone" {
+		t.Fatalf("expected svc-gone to expire, got %+v", rec.expired)
+	}
+	if len(rec.alerts) != 1 || rec.alerts[0].Subject != "svc-late" {
+		t.Fatalf("expected alert for svc-late only, got %+v", rec.alerts)
+	}
+	if _, ok := m.state["svc-gone"]; ok {
+		t.Fatalf("expected svc-gone to be removed from state")
+	}
+	if _, ok := m.state["svc-late"]; !ok {
+		t.Fatalf("expected svc-late to remain in state")
+	}
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+	if _, ok := m.history["svc-gone"]; ok {
+		t.Fatalf("expected svc-gone's history to be forgotten")
+	}
+}
+
+// waitFor polls cond until it holds, for checks on notifications sent from
+// goroutines.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package monitortest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+)
+
+// FakeClock is a monitor.Clock that only moves when Advance is called.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	changed chan struct{}
+}
+
+// NewFakeClock returns a clock frozen at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start, changed: make(chan struct{})}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) monitor.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	c.broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d and fires any tickers that became
+// due. Like time.Ticker, ticks are dropped if the receiver is not keeping up.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.stopped {
+			continue
+		}
+		if !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			// the later ticks would be dropped by a receiver that has not
+			// read this one yet, so skip them in one step
+			t.next = t.next.Add((c.now.Sub(t.next)/t.period + 1) * t.period)
+		}
+	}
+}
+
+// WaitForTickers blocks until at least n tickers are active or timeout
+// elapses, reporting whether they were created in time.
+func (c *FakeClock) WaitForTickers(n int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		c.mu.Lock()
+		active := 0
+		for _, t := range c.tickers {
+			if !t.stopped {
+				active++
+			}
+		}
+		changed := c.changed
+		c.mu.Unlock()
+		if active >= n {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// broadcast wakes WaitForTickers callers. c.mu must be held.
+func (c *FakeClock) broadcast() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+type fakeTicker struct {
+	clock   *FakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period = d
+	t.next = t.clock.now.Add(d)
+	t.stopped = false
+	t.clock.broadcast()
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+	t.clock.broadcast()
+}
@@ -0,0 +1,220 @@
+// Package monitortest runs a monitor against a NATS server with a fake
+// clock, so end-to-end scenarios complete without real waiting. The package
+// does not embed a server; tests start one (e.g. an in-process nats-server
+// from a _test.go file) and pass its URL to Start.
+package monitortest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+// Timeout bounds how long harness helpers wait for the monitor to react.
+// Scenarios only wait on goroutine scheduling and loopback NATS traffic, so
+// it is never reached unless something is broken.
+var Timeout = 5 * time.Second
+
+// Harness is a running monitor wired to a fake clock, a recording notifier
+// and the NATS server at URL.
+type Harness struct {
+	tb        testing.TB
+	URL       string
+	auth      []monitor.Credential
+	Conn      *nats.Conn
+	Clock     *FakeClock
+	Notifier  *RecordingNotifier
+	Monitor   *monitor.Monitor
+	Publisher *heartbeat.Publisher
+}
+
+// Start launches a monitor with cfg against the NATS server at url, which
+// must have JetStream enabled if cfg uses streams. Prefix defaults to
+// "heartbeat", PollEvery to one second and, with a ShardGroup, ShardHeartbeat
+// to one second; Clock is always replaced by the harness clock. The monitor
+// is stopped when the test ends.
+func Start(tb testing.TB, url string, cfg monitor.Config) *Harness {
+	tb.Helper()
+	if cfg.Prefix == "" {
+		cfg.Prefix = "heartbeat"
+	}
+	if cfg.PollEvery == 0 {
+		cfg.PollEvery = time.Second
+	}
+	if cfg.ShardGroup != "" && cfg.ShardHeartbeat == 0 {
+		cfg.ShardHeartbeat = time.Second
+	}
+	h := &Harness{
+		tb:       tb,
+		URL:      url,
+		auth:     cfg.StatusAuth,
+		Conn:     Connect(tb, url),
+		Clock:    NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Notifier: NewRecordingNotifier(),
+	}
+	cfg.Clock = h.Clock
+	h.Monitor = monitor.New(h.Conn, h.Notifier, cfg)
+	h.Publisher = heartbeat.NewPublisher(Connect(tb, url), cfg.Prefix)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Monitor.Start(ctx)
+	}()
+	tb.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			tb.Errorf("monitor stopped with error: %v", err)
+		}
+	})
+
+	if !JoinShard(h.Clock, cfg) {
+		tb.Fatalf("monitor did not join its shard group")
+	}
+	// the ticker is created after the subscriptions, so once it exists the
+	// monitor is ready for heartbeats; a shard member stops its join wait
+	// before and starts its announcement ticker right after it
+	ready := 1
+	if cfg.ShardGroup != "" {
+		ready = 2
+	}
+	if !h.Clock.WaitForTickers(ready, Timeout) {
+		tb.Fatalf("monitor did not start")
+	}
+	if err := h.Conn.Flush(); err != nil {
+		tb.Fatalf("flush: %v", err)
+	}
+	return h
+}
+
+// JoinShard lets a monitor started with cfg and clock finish joining its
+// shard group: the monitor waits one ShardHeartbeat of clock time for its
+// peers to answer, so the clock is advanced once the wait has begun. It
+// reports false if the monitor never started waiting, and does nothing
+// without a ShardGroup.
+func JoinShard(clock *FakeClock, cfg monitor.Config) bool {
+	if cfg.ShardGroup == "" {
+		return true
+	}
+	if !clock.WaitForTickers(1, Timeout) {
+		return false
+	}
+	clock.Advance(cfg.ShardHeartbeat)
+	return true
+}
+
+// Connect opens a client connection to the NATS server at url that is
+// closed when the test ends.
+func Connect(tb testing.TB, url string) *nats.Conn {
+	tb.Helper()
+	nc, err := nats.Connect(url)
+	if err != nil {
+		tb.Fatalf("connect to nats: %v", err)
+	}
+	tb.Cleanup(nc.Close)
+	return nc
+}
+
+// Beat publishes a heartbeat generated at the current fake time and waits
+// until the monitor has recorded it.
+func (h *Harness) Beat(msg heartbeat.Message) {
+	h.tb.Helper()
+	now := h.Clock.Now()
+	if msg.GeneratedAt.IsZero() {
+		msg.GeneratedAt = now
+	}
+	if err := h.Publisher.Publish(context.Background(), msg); err != nil {
+		h.tb.Fatalf("publish heartbeat: %v", err)
+	}
+	h.waitFor(func(s Subject) bool {
+		return s.Subject == msg.Subject && s.LastSeen.Equal(now)
+	}, "heartbeat for %s at %s", msg.Subject, now)
+}
+
+// Advance moves the fake clock forward by d, firing the monitor's scan
+// ticker if it became due.
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+// Subject is the subset of the status API the harness inspects.
+type Subject struct {
+	Subject     string    `json:"subject"`
+	LastSeen    time.Time `json:"last_seen"`
+	Missing     bool      `json:"missing"`
+	MissCount   int       `json:"miss_count"`
+	AlertActive bool      `json:"alert_active"`
+}
+
+// Status fetches the monitor's status API through its HTTP handler, with
+// the first token or basic auth credential when StatusAuth is set.
+func (h *Harness) Status() []Subject {
+	h.tb.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range h.auth {
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+			break
+		}
+		if c.User != "" {
+			req.SetBasicAuth(c.User, c.Password)
+			break
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.Monitor.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		h.tb.Fatalf("status returned %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Subjects []Subject `json:"subjects"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		h.tb.Fatalf("decode status: %v", err)
+	}
+	return resp.Subjects
+}
+
+// WaitForAlert waits until the n-th alert for subject has been sent.
+func (h *Harness) WaitForAlert(subject string, n int) {
+	h.tb.Helper()
+	h.waitForEvent(KindAlert, subject, n)
+}
+
+// WaitForResolved waits until the n-th resolved notification for subject has
+// been sent.
+func (h *Harness) WaitForResolved(subject string, n int) {
+	h.tb.Helper()
+	h.waitForEvent(KindResolved, subject, n)
+}
+
+func (h *Harness) waitForEvent(kind, subject string, n int) {
+	h.tb.Helper()
+	if err := h.Notifier.WaitForCount(kind, subject, n, Timeout); err != nil {
+		h.tb.Fatal(err)
+	}
+}
+
+func (h *Harness) waitFor(match func(Subject) bool, format string, args ...interface{}) {
+	h.tb.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		for _, s := range h.Status() {
+			if match(s) {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			h.tb.Fatalf("timed out waiting for "+format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,312 @@
+package monitortest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestMissedHeartbeatAlertsRepeatsAndResolves(t *testing.T) {
+	h := Start(t, runServer(t), monitor.Config{RepeatEvery: time.Hour})
+	beat := heartbeat.Message{Subject: "svc", Interval: 10 * time.Second, Description: "svc"}
+
+	h.Beat(beat)
+	h.Advance(5 * time.Second)
+	if got := h.Notifier.Count(KindAlert, "svc"); got != 0 {
+		t.Fatalf("expected no alerts within the interval, got %d", got)
+	}
+
+	h.Advance(time.Minute)
+	h.WaitForAlert("svc", 1)
+
+	h.Advance(time.Hour)
+	h.WaitForAlert("svc", 2)
+
+	h.Beat(beat)
+	h.WaitForResolved("svc", 1)
+	if got := h.Notifier.Count(KindAlert, "svc"); got != 2 {
+		t.Fatalf("expected 2 alerts, got %d", got)
+	}
+}
+
+func TestExpiredSubjectIsDropped(t *testing.T) {
+	h := Start(t, runServer(t), monitor.Config{ExpireAfter: time.Hour})
+	h.Beat(heartbeat.Message{Subject: "gone", Interval: time.Minute})
+
+	h.Advance(2 * time.Hour)
+	h.waitForEvent(KindExpired, "gone", 1)
+	if subjects := h.Status(); len(subjects) != 0 {
+		t.Fatalf("expected expired subject to be dropped, got %+v", subjects)
+	}
+}
+
+func TestFakeClockFiresDueTickers(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	ticker := c.NewTicker(time.Second)
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatalf("ticker fired early")
+	default:
+	}
+
+	c.Advance(time.Second)
+	select {
+	case got := <-ticker.C():
+		if !got.Equal(time.Unix(1, 0)) {
+			t.Fatalf("unexpected tick time %s", got)
+		}
+	default:
+		t.Fatalf("ticker did not fire")
+	}
+
+	ticker.Stop()
+	c.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Fatalf("stopped ticker fired")
+	default:
+	}
+}
+
+func TestHistoryIsRestoredFromStream(t *testing.T) {
+	cfg := monitor.Config{HistoryStream: "HEARTBEAT_HISTORY"}
+	h := Start(t, runServer(t), cfg)
+	beat := heartbeat.Message{Subject: "svc", Interval: 10 * time.Second}
+	h.Beat(beat)
+	h.Advance(time.Minute)
+	h.WaitForAlert("svc", 1)
+	if err := h.Conn.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	// a second monitor on the same server starts with the persisted history
+	cfg.Prefix = "heartbeat"
+	cfg.Clock = NewFakeClock(h.Clock.Now())
+	restarted := monitor.New(Connect(t, h.URL), nil, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- restarted.Start(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(Timeout)
+	for {
+		rec := httptest.NewRecorder()
+		restarted.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subjects/svc/history", nil))
+		var resp struct {
+			Entries []struct {
+				Kind string `json:"kind"`
+			} `json:"entries"`
+		}
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode history: %v", err)
+			}
+			if len(resp.Entries) == 2 && resp.Entries[0].Kind == "beat" && resp.Entries[1].Kind == "alert" {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("history not restored: %d %s", rec.Code, rec.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShardGroupSplitsSubjectsAndHandsOff(t *testing.T) {
+	cfg := monitor.Config{
+		ShardGroup:     "hbshard",
+		ShardMember:    "a",
+		ShardHeartbeat: 100 * time.Millisecond,
+		PrimeStream:    "HEARTBEATS",
+	}
+	h := startShard(t, cfg)
+	peer, _, stop := startPeer(t, h, cfg, "b", NewRecordingNotifier())
+	defer stop()
+
+	const subjects = 40
+	for i := 0; i < subjects; i++ {
+		msg := heartbeat.Message{Subject: fmt.Sprintf("svc-%d", i), Interval: time.Minute, GeneratedAt: h.Clock.Now()}
+		if err := h.Publisher.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("publish heartbeat: %v", err)
+		}
+	}
+	var owned []statusapi.SubjectStatus
+	waitForStatus(t, peer, "the subjects to be split", func(s statusapi.Status) bool {
+		owned = s.Subjects
+		return len(owned)+len(statusOf(t, h.Monitor).Subjects) == subjects
+	})
+	if len(owned) == 0 || len(owned) == subjects {
+		t.Fatalf("expected the subjects to be split, b owns %d of %d", len(owned), subjects)
+	}
+	for _, s := range statusOf(t, h.Monitor).Subjects {
+		for _, o := range owned {
+			if s.Subject == o.Subject {
+				t.Fatalf("%s is tracked by both members", s.Subject)
+			}
+		}
+	}
+
+	// once b leaves, a takes its subjects over from the prime stream without
+	// waiting for their next beat
+	stop()
+	waitForStatus(t, h.Monitor, "a to take over every subject", func(s statusapi.Status) bool {
+		return s.Shard != nil && len(s.Shard.Members) == 1 && len(s.Subjects) == subjects
+	})
+}
+
+func TestShardHandoverRealertsAlertingSubject(t *testing.T) {
+	cfg := monitor.Config{
+		ShardGroup:     "hbshard",
+		ShardMember:    "a",
+		ShardHeartbeat: time.Second,
+		PrimeStream:    "HEARTBEATS",
+	}
+	h := startShard(t, cfg)
+	// a beat read from the prime stream counts as seen when the stream
+	// stored it, so the fake clocks must follow the wall clock
+	h.Advance(time.Since(h.Clock.Now()))
+	const subjects = 20
+	for i := 0; i < subjects; i++ {
+		h.Beat(heartbeat.Message{Subject: fmt.Sprintf("svc-%d", i), Interval: 10 * time.Second})
+	}
+	h.Advance(time.Minute)
+	for i := 0; i < subjects; i++ {
+		h.WaitForAlert(fmt.Sprintf("svc-%d", i), 1)
+	}
+
+	// b takes its share from the prime stream when it joins and alerts on
+	// the silent subjects it took over
+	notifier := NewRecordingNotifier()
+	peer, clock, stop := startPeer(t, h, cfg, "b", notifier)
+	defer stop()
+	var taken string
+	waitForStatus(t, peer, "b to take over a subject", func(s statusapi.Status) bool {
+		if len(s.Subjects) > 0 {
+			taken = s.Subjects[0].Subject
+		}
+		return taken != ""
+	})
+	clock.Advance(time.Second)
+	if err := notifier.WaitForCount(KindAlert, taken, 1, Timeout); err != nil {
+		t.Fatal(err)
+	}
+
+	// once b leaves, a reads the subject back from the prime stream and
+	// alerts on it again
+	stop()
+	waitForStatus(t, h.Monitor, "a to take "+taken+" back", func(s statusapi.Status) bool {
+		for _, sub := range s.Subjects {
+			if sub.Subject == taken {
+				return s.Shard != nil && len(s.Shard.Members) == 1
+			}
+		}
+		return false
+	})
+	h.Advance(time.Second)
+	h.WaitForAlert(taken, 2)
+}
+
+// startShard starts the first member of cfg's shard group together with
+// the prime stream every member reads subjects it takes over from.
+func startShard(t *testing.T, cfg monitor.Config) *Harness {
+	t.Helper()
+	url := runServer(t)
+	js, err := Connect(t, url).JetStream()
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: cfg.PrimeStream, Subjects: []string{"heartbeat.>"}, MaxMsgsPerSubject: 1}); err != nil {
+		t.Fatalf("add stream: %v", err)
+	}
+	return Start(t, url, cfg)
+}
+
+// startPeer adds member to h's shard group with its own fake clock and
+// notifier n, and waits until both sides see each other. The returned function stops the
+// peer; it is safe to call more than once.
+func startPeer(t *testing.T, h *Harness, cfg monitor.Config, member string, n *RecordingNotifier) (*monitor.Monitor, *FakeClock, func()) {
+	t.Helper()
+	cfg.Prefix = "heartbeat"
+	cfg.PollEvery = time.Second
+	cfg.ShardMember = member
+	clock := NewFakeClock(h.Clock.Now())
+	cfg.Clock = clock
+	nc := Connect(t, h.URL)
+	peer := monitor.New(nc, n, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- peer.Start(ctx) }()
+	stopped := false
+	stop := func() {
+		if !stopped {
+			stopped = true
+			cancel()
+			<-done
+		}
+	}
+	t.Cleanup(stop)
+
+	// h answers the join straight away; let the answer reach the peer
+	// before its wait ends
+	waitForStatus(t, h.Monitor, "a to see "+member, func(s statusapi.Status) bool {
+		return s.Shard != nil && len(s.Shard.Members) == 2
+	})
+	if err := h.Conn.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if !JoinShard(clock, cfg) {
+		t.Fatalf("%s did not join the shard group", member)
+	}
+	// the scan and announcement tickers start once the peer has subscribed
+	if !clock.WaitForTickers(2, Timeout) {
+		t.Fatalf("%s did not start", member)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	waitForStatus(t, peer, member+" to join", func(s statusapi.Status) bool {
+		return s.Shard != nil && len(s.Shard.Members) == 2
+	})
+	return peer, clock, stop
+}
+
+func statusOf(t *testing.T, m *monitor.Monitor) statusapi.Status {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var s statusapi.Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	return s
+}
+
+func waitForStatus(t *testing.T, m *monitor.Monitor, what string, match func(statusapi.Status) bool) {
+	t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		s := statusOf(t, m)
+		if match(s) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", what, s)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
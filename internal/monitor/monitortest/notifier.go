@@ -0,0 +1,101 @@
+package monitortest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+)
+
+// Event kinds recorded by RecordingNotifier.
+const (
+	KindAlert    = "alert"
+	KindResolved = "resolved"
+	KindExpired  = "expired"
+	KindNotice   = "notice"
+)
+
+// Recorded is a notification captured by RecordingNotifier.
+type Recorded struct {
+	Kind  string
+	Event notifier.Event
+}
+
+// RecordingNotifier is a notifier.Notifier that keeps every event it
+// receives.
+type RecordingNotifier struct {
+	mu      sync.Mutex
+	events  []Recorded
+	changed chan struct{}
+}
+
+func NewRecordingNotifier() *RecordingNotifier {
+	return &RecordingNotifier{changed: make(chan struct{})}
+}
+
+func (r *RecordingNotifier) Alert(_ context.Context, evt notifier.Event) error {
+	r.record(KindAlert, evt)
+	return nil
+}
+
+func (r *RecordingNotifier) Resolved(_ context.Context, evt notifier.Event) error {
+	r.record(KindResolved, evt)
+	return nil
+}
+
+func (r *RecordingNotifier) Expired(_ context.Context, evt notifier.Event) error {
+	r.record(KindExpired, evt)
+	return nil
+}
+
+func (r *RecordingNotifier) Notice(_ context.Context, evt notifier.Event) error {
+	r.record(KindNotice, evt)
+	return nil
+}
+
+func (r *RecordingNotifier) record(kind string, evt notifier.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Recorded{Kind: kind, Event: evt})
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Events returns a copy of everything recorded so far.
+func (r *RecordingNotifier) Events() []Recorded {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Recorded(nil), r.events...)
+}
+
+// Count returns how many events of kind were recorded for subject.
+func (r *RecordingNotifier) Count(kind, subject string) int {
+	n := 0
+	for _, rec := range r.Events() {
+		if rec.Kind == kind && rec.Event.Subject == subject {
+			n++
+		}
+	}
+	return n
+}
+
+// WaitForCount blocks until n events of kind were recorded for subject.
+func (r *RecordingNotifier) WaitForCount(kind, subject string, n int, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		r.mu.Lock()
+		changed := r.changed
+		r.mu.Unlock()
+		got := r.Count(kind, subject)
+		if got >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return fmt.Errorf("timed out waiting for %d %s event(s) for %s, got %d: %+v", n, kind, subject, got, r.Events())
+		}
+	}
+}
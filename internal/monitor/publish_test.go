@@ -0,0 +1,80 @@
+package monitor_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/internal/monitor/monitortest"
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+func TestEventsPublishedOnNATS(t *testing.T) {
+	h := monitortest.Start(t, runServer(t), monitor.Config{EventSubject: "heartbeat.events"})
+	sub, err := h.Conn.SubscribeSync("heartbeat.events.>")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := h.Conn.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	beat := heartbeat.Message{Subject: "svc", Interval: 10 * time.Second, Description: "svc"}
+	h.Beat(beat)
+	h.Advance(time.Minute)
+	h.WaitForAlert("svc", 1)
+	h.Beat(beat)
+	h.WaitForResolved("svc", 1)
+
+	for _, kind := range []string{"alert", "resolved"} {
+		msg, err := sub.NextMsg(monitortest.Timeout)
+		if err != nil {
+			t.Fatalf("waiting for %s event: %v", kind, err)
+		}
+		if want := "heartbeat.events." + kind + ".svc"; msg.Subject != want {
+			t.Fatalf("expected event on %s, got %s", want, msg.Subject)
+		}
+		var evt notifier.EventMessage
+		if err := json.Unmarshal(msg.Data, &evt); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if evt.Kind != kind || evt.Subject != "svc" || evt.Interval != "10s" || evt.AllowedWindow != "10s" || evt.Seq == 0 || evt.BootID == "" {
+			t.Fatalf("unexpected %s event: %+v", kind, evt)
+		}
+	}
+
+	// the monitor must not try to decode its own events as heartbeats
+	rec := httptest.NewRecorder()
+	h.Monitor.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "heartbeat_decode_errors_total 0") {
+		t.Fatalf("expected no decode errors:\n%s", rec.Body)
+	}
+}
+
+func TestEventsPublishedToStream(t *testing.T) {
+	h := monitortest.Start(t, runServer(t), monitor.Config{EventSubject: "hb-events", EventStream: "HB_EVENTS"})
+	h.Beat(heartbeat.Message{Subject: "svc", Interval: 10 * time.Second})
+	h.Advance(time.Minute)
+	h.WaitForAlert("svc", 1)
+
+	js, err := h.Conn.JetStream()
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+	deadline := time.Now().Add(monitortest.Timeout)
+	for {
+		info, err := js.StreamInfo("HB_EVENTS")
+		if err == nil && info.State.Msgs == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one event in the stream (info %+v, err %v)", info, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
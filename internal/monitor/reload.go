@@ -0,0 +1,188 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+)
+
+// ReloadFunc produces a fresh configuration and notifier, typically by
+// re-reading a config file.
+type ReloadFunc func() (Config, notifier.Notifier, error)
+
+// ErrReloadUnavailable is returned by TriggerReload when no ReloadFunc has
+// been set.
+var ErrReloadUnavailable = errors.New("reload is not configured")
+
+type settings struct {
+	cfg      Config
+	notifier notifier.Notifier
+}
+
+// SetReloadFunc registers the source used by TriggerReload, the admin reload
+// endpoint and the NATS admin reload request.
+func (m *Monitor) SetReloadFunc(fn ReloadFunc) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	m.reloadFn = fn
+}
+
+// TriggerReload loads a new configuration from the registered ReloadFunc and
+// applies it with Reload.
+func (m *Monitor) TriggerReload() ([]string, error) {
+	m.reloadMu.Lock()
+	fn := m.reloadFn
+	m.reloadMu.Unlock()
+	if fn == nil {
+		return nil, ErrReloadUnavailable
+	}
+
+	cfg, n, err := fn()
+	if err != nil {
+		m.logger.Error("reload failed; keeping current configuration", "err", err)
+		return nil, err
+	}
+	return m.Reload(cfg, n), nil
+}
+
+// Reload atomically swaps the configuration and notifier without touching
+// the subscription or cached state, and returns the changes it applied.
+// Settings that are only read at startup (subject prefix, prime stream,
+// status address and TLS, admin, service and event subjects, history, shard,
+// logger, clock) keep their current values.
+func (m *Monitor) Reload(cfg Config, n notifier.Notifier) []string {
+	cfg = normalizeConfig(cfg)
+	if n == nil {
+		n = notifier.Nop{}
+	}
+
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	old := m.settings.Load()
+	for _, field := range restartOnlyChanges(old.cfg, cfg) {
+		m.logger.Warn("config change requires restart; keeping current value", "field", field)
+	}
+	cfg.Prefix = old.cfg.Prefix
+	cfg.PrimeStream = old.cfg.PrimeStream
+	cfg.StatusAddr = old.cfg.StatusAddr
+	cfg.StatusTLSCert = old.cfg.StatusTLSCert
+	cfg.StatusTLSKey = old.cfg.StatusTLSKey
+	cfg.StatusClientCA = old.cfg.StatusClientCA
+	cfg.AdminSubject = old.cfg.AdminSubject
+	cfg.ServiceSubject = old.cfg.ServiceSubject
+	cfg.EventSubject = old.cfg.EventSubject
+	cfg.EventStream = old.cfg.EventStream
+	cfg.Debug = old.cfg.Debug
+	cfg.Logger = old.cfg.Logger
+	cfg.Clock = old.cfg.Clock
+	cfg.HistorySize = old.cfg.HistorySize
+	cfg.HistoryStream = old.cfg.HistoryStream
+	cfg.HistorySubject = old.cfg.HistorySubject
+	cfg.ShardGroup = old.cfg.ShardGroup
+	cfg.ShardMember = old.cfg.ShardMember
+	cfg.ShardHeartbeat = old.cfg.ShardHeartbeat
+
+	changes := configDiff(old.cfg, cfg)
+	if !reflect.DeepEqual(old.notifier, n) {
+		changes = append(changes, "notifier: updated")
+	}
+
+	m.settings.Store(&settings{cfg: cfg, notifier: n})
+	m.mu.Lock()
+	m.rescheduleAll(m.clock.Now())
+	m.mu.Unlock()
+	select {
+	case m.reloaded <- struct{}{}:
+	default:
+	}
+
+	if len(changes) == 0 {
+		m.logger.Info("config reloaded; no changes")
+		return changes
+	}
+	for _, change := range changes {
+		m.logger.Info("config reloaded", "change", change)
+	}
+	return changes
+}
+
+func restartOnlyChanges(old, cfg Config) []string {
+	var fields []string
+	if old.Prefix != cfg.Prefix {
+		fields = append(fields, "subject_prefix")
+	}
+	if old.PrimeStream != cfg.PrimeStream {
+		fields = append(fields, "prime_stream")
+	}
+	if old.StatusAddr != cfg.StatusAddr {
+		fields = append(fields, "status_addr")
+	}
+	if old.StatusTLSCert != cfg.StatusTLSCert || old.StatusTLSKey != cfg.StatusTLSKey || old.StatusClientCA != cfg.StatusClientCA {
+		fields = append(fields, "status_tls")
+	}
+	if old.AdminSubject != cfg.AdminSubject {
+		fields = append(fields, "admin_subject")
+	}
+	if old.ServiceSubject != cfg.ServiceSubject {
+		fields = append(fields, "service_subject")
+	}
+	if old.EventSubject != cfg.EventSubject {
+		fields = append(fields, "event_subject")
+	}
+	if old.EventStream != cfg.EventStream {
+		fields = append(fields, "event_stream")
+	}
+	if old.HistorySize != cfg.HistorySize {
+		fields = append(fields, "history_size")
+	}
+	if old.HistoryStream != cfg.HistoryStream {
+		fields = append(fields, "history_stream")
+	}
+	if old.HistorySubject != cfg.HistorySubject {
+		fields = append(fields, "history_subject")
+	}
+	if old.ShardGroup != cfg.ShardGroup || old.ShardMember != cfg.ShardMember || old.ShardHeartbeat != cfg.ShardHeartbeat {
+		fields = append(fields, "shard")
+	}
+	return fields
+}
+
+// configDiff describes reloadable settings that differ between old and cfg.
+func configDiff(old, cfg Config) []string {
+	var changes []string
+	duration := func(name string, a, b time.Duration) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", name, a, b))
+		}
+	}
+	boolean := func(name string, a, b bool) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s: %t -> %t", name, a, b))
+		}
+	}
+
+	duration("poll", old.PollEvery, cfg.PollEvery)
+	duration("repeat_every", old.RepeatEvery, cfg.RepeatEvery)
+	duration("expire_after", old.ExpireAfter, cfg.ExpireAfter)
+	duration("max_skew", old.MaxSkew, cfg.MaxSkew)
+	duration("host_window", old.HostWindow, cfg.HostWindow)
+	boolean("per_host", old.PerHost, cfg.PerHost)
+	boolean("reject_unverified", old.RejectUnverified, cfg.RejectUnverified)
+	if old.MaxDrift != cfg.MaxDrift {
+		changes = append(changes, fmt.Sprintf("max_drift: %g -> %g", old.MaxDrift, cfg.MaxDrift))
+	}
+	if !reflect.DeepEqual(old.UptimeWindows, cfg.UptimeWindows) {
+		changes = append(changes, fmt.Sprintf("uptime_windows: %s -> %s", formatWindows(old.UptimeWindows), formatWindows(cfg.UptimeWindows)))
+	}
+	if !reflect.DeepEqual(old.TrustRules, cfg.TrustRules) {
+		changes = append(changes, fmt.Sprintf("trust_rules: %d -> %d rule(s)", len(old.TrustRules), len(cfg.TrustRules)))
+	}
+	if !reflect.DeepEqual(old.StatusAuth, cfg.StatusAuth) {
+		changes = append(changes, fmt.Sprintf("status_auth: %d -> %d credential(s)", len(old.StatusAuth), len(cfg.StatusAuth)))
+	}
+	return changes
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+)
+
+func TestReloadSwapsSettingsAndKeepsState(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat.", PollEvery: time.Second})
+	m.state["svc"] = &state{subject: "svc", lastSeen: time.Now(), interval: time.Second}
+
+	rec := &recordingNotifier{}
+	changes := m.Reload(Config{Prefix: "other.", PollEvery: 5 * time.Second, PerHost: true}, rec)
+
+	cfg := m.config()
+	if cfg.PollEvery != 5*time.Second || !cfg.PerHost {
+		t.Fatalf("expected reloadable settings to change, got %+v", cfg)
+	}
+	if cfg.Prefix != "heartbeat" {
+		t.Fatalf("expected prefix to require restart, got %q", cfg.Prefix)
+	}
+	if m.notify() != notifier.Notifier(rec) {
+		t.Fatalf("expected notifier to be swapped")
+	}
+	if _, ok := m.state["svc"]; !ok {
+		t.Fatalf("expected state to survive reload")
+	}
+
+	want := map[string]bool{"poll: 1s -> 5s": true, "per_host: false -> true": true, "notifier: updated": true}
+	if len(changes) != len(want) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	for _, c := range changes {
+		if !want[c] {
+			t.Fatalf("unexpected change %q in %v", c, changes)
+		}
+	}
+}
+
+func TestTriggerReload(t *testing.T) {
+	m := New(nil, nil, Config{})
+	if _, err := m.TriggerReload(); !errors.Is(err, ErrReloadUnavailable) {
+		t.Fatalf("expected ErrReloadUnavailable, got %v", err)
+	}
+
+	m.SetReloadFunc(func() (Config, notifier.Notifier, error) {
+		return Config{}, nil, errors.New("bad config")
+	})
+	res := httptest.NewRecorder()
+	m.reloadHandler().ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/reload", nil))
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("expected failed reload to return 500, got %d", res.Code)
+	}
+
+	m.SetReloadFunc(func() (Config, notifier.Notifier, error) {
+		return Config{RepeatEvery: time.Hour}, nil, nil
+	})
+	res = httptest.NewRecorder()
+	m.reloadHandler().ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/reload", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected reload to succeed, got %d: %s", res.Code, res.Body.String())
+	}
+	if m.config().RepeatEvery != time.Hour {
+		t.Fatalf("expected repeat every 1h, got %s", m.config().RepeatEvery)
+	}
+}
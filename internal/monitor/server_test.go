@@ -0,0 +1,32 @@
+package monitor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// runServer starts an in-process NATS server with JetStream enabled on a
+// random port and returns its client URL. The server shuts down when the
+// test ends.
+func runServer(tb testing.TB) string {
+	tb.Helper()
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  tb.TempDir(),
+	})
+	if err != nil {
+		tb.Fatalf("create nats server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		tb.Fatalf("nats server not ready")
+	}
+	tb.Cleanup(srv.Shutdown)
+	return srv.ClientURL()
+}
@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+const (
+	// ServiceName is the NATS micro service name monitors register under;
+	// "$SRV.PING.heartbeat-monitor" lists the running instances.
+	ServiceName    = "heartbeat-monitor"
+	serviceVersion = "1.0.0"
+)
+
+// addService registers the monitor as a NATS micro service with endpoints
+// under ServiceSubject: status, subject.info, history and report to read
+// state, and silence and ack to act on alerts. Every instance answers each
+// request (the endpoints use a per-instance queue group), so clients can
+// aggregate replies from several monitors.
+func (m *Monitor) addService() (micro.Service, error) {
+	cfg := m.config()
+	metadata := map[string]string{}
+	if host, err := os.Hostname(); err == nil {
+		metadata["host"] = host
+	}
+	if cfg.StatusAddr != "" {
+		metadata["status_addr"] = cfg.StatusAddr
+	}
+	svc, err := micro.AddService(m.nc, micro.Config{
+		Name:        ServiceName,
+		Version:     serviceVersion,
+		Description: "Heartbeat monitor status",
+		Metadata:    metadata,
+		ErrorHandler: func(_ micro.Service, err *micro.NATSError) {
+			m.logger.Warn("service error", "subject", err.Subject, "err", err.Description)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	id := svc.Info().ID
+	group := svc.AddGroup(strings.TrimSuffix(cfg.ServiceSubject, "."), micro.WithGroupQueueGroup(id))
+	endpoints := []struct {
+		name    string
+		role    Role
+		handler micro.HandlerFunc
+	}{
+		{"status", RoleRead, m.serviceStatus},
+		{"subject.info", RoleRead, m.serviceSubjectInfo},
+		{"history", RoleRead, m.serviceHistory},
+		{"report", RoleRead, m.serviceReport},
+		{"silence", RoleAdmin, m.serviceSilence},
+		{"ack", RoleAdmin, m.serviceAck},
+	}
+	for _, e := range endpoints {
+		name := strings.ReplaceAll(e.name, ".", "-")
+		if err := group.AddEndpoint(name, m.serviceHandler(id, e.role, e.handler), micro.WithEndpointSubject(e.name)); err != nil {
+			_ = svc.Stop()
+			return nil, err
+		}
+	}
+	m.logger.Info("service registered", "name", ServiceName, "id", id, "subject", cfg.ServiceSubject+".>")
+	return svc, nil
+}
+
+// serviceHandler tags replies with the instance ID and, when the status
+// server requires credentials, rejects requests whose Authorization header
+// does not grant role.
+func (m *Monitor) serviceHandler(id string, role Role, h micro.HandlerFunc) micro.HandlerFunc {
+	return func(req micro.Request) {
+		tagged := &taggedRequest{Request: req, id: id}
+		if err := m.authorizeRequest(req.Headers().Get("Authorization"), role); err != nil {
+			m.respondError(tagged, serviceCode(err), err.Error())
+			return
+		}
+		h(tagged)
+	}
+}
+
+// taggedRequest adds the monitor ID header to every reply.
+type taggedRequest struct {
+	micro.Request
+	id string
+}
+
+func (r *taggedRequest) opts(opts []micro.RespondOpt) []micro.RespondOpt {
+	return append(opts, micro.WithHeaders(micro.Headers{statusapi.MonitorIDHeader: []string{r.id}}))
+}
+
+func (r *taggedRequest) Respond(data []byte, opts ...micro.RespondOpt) error {
+	return r.Request.Respond(data, r.opts(opts)...)
+}
+
+func (r *taggedRequest) RespondJSON(v interface{}, opts ...micro.RespondOpt) error {
+	return r.Request.RespondJSON(v, r.opts(opts)...)
+}
+
+func (r *taggedRequest) Error(code, description string, data []byte, opts ...micro.RespondOpt) error {
+	return r.Request.Error(code, description, data, r.opts(opts)...)
+}
+
+func (m *Monitor) serviceStatus(req micro.Request) {
+	observedAt := m.clock.Now()
+	m.respondJSON(req, statusapi.Status{
+		Version:    statusapi.Version,
+		ObservedAt: observedAt,
+		Subjects:   m.snapshot(observedAt),
+		Shard:      m.shardStatus(),
+	})
+}
+
+// serviceSubjectInfo replies with the status of the subject named in the
+// request body.
+func (m *Monitor) serviceSubjectInfo(req micro.Request) {
+	subject := strings.TrimSpace(string(req.Data()))
+	if subject == "" {
+		m.respondError(req, "400", "subject is required")
+		return
+	}
+	info, ok := m.subjectInfo(subject)
+	if !ok {
+		m.respondError(req, "404", ErrUnknownSubject.Error())
+		return
+	}
+	m.respondJSON(req, info)
+}
+
+// serviceHistory replies with the history of the subject named in the
+// request body, optionally followed by a space and an entry limit.
+func (m *Monitor) serviceHistory(req micro.Request) {
+	fields := strings.Fields(string(req.Data()))
+	if len(fields) == 0 || len(fields) > 2 {
+		m.respondError(req, "400", "expected <subject> [limit]")
+		return
+	}
+	entries, ok := m.subjectHistory(fields[0])
+	if !ok {
+		m.respondError(req, "404", ErrUnknownSubject.Error())
+		return
+	}
+	if len(fields) == 2 {
+		limit, err := strconv.Atoi(fields[1])
+		if err != nil || limit < 0 {
+			m.respondError(req, "400", "invalid limit")
+			return
+		}
+		if limit < len(entries) {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+	m.respondJSON(req, statusapi.History{Version: statusapi.Version, Subject: fields[0], Entries: entries})
+}
+
+// serviceReport replies with the uptime report for the range in the request
+// body, given as a query string like the HTTP endpoint ("window=7d").
+func (m *Monitor) serviceReport(req micro.Request) {
+	q, err := url.ParseQuery(string(req.Data()))
+	if err != nil {
+		m.respondError(req, "400", "invalid query: "+err.Error())
+		return
+	}
+	now := m.clock.Now()
+	from, to, err := parseReportRange(q, now)
+	if err != nil {
+		m.respondError(req, "400", err.Error())
+		return
+	}
+	m.respondJSON(req, m.report(from, to, now))
+}
+
+// serviceSilence silences the subject in a "<subject> <duration>" request
+// body; a zero duration lifts the silence.
+func (m *Monitor) serviceSilence(req micro.Request) {
+	subject, d, err := parseSilence(req.Data())
+	if err != nil {
+		m.respondError(req, "400", err.Error())
+		return
+	}
+	until, err := m.Silence(subject, d)
+	if err != nil {
+		m.respondError(req, serviceCode(err), err.Error())
+		return
+	}
+	resp := statusapi.AdminResult{Subject: subject, OK: true}
+	if !until.IsZero() {
+		resp.SilencedUntil = &until
+	}
+	m.respondJSON(req, resp)
+}
+
+// serviceAck acknowledges the alert on the subject named in the request body.
+func (m *Monitor) serviceAck(req micro.Request) {
+	subject := strings.TrimSpace(string(req.Data()))
+	if subject == "" {
+		m.respondError(req, "400", "subject is required")
+		return
+	}
+	if err := m.Ack(subject); err != nil {
+		m.respondError(req, serviceCode(err), err.Error())
+		return
+	}
+	m.respondJSON(req, statusapi.AdminResult{Subject: subject, OK: true})
+}
+
+// serviceCode maps an admin operation error to a service error code, using
+// the HTTP status codes of the equivalent endpoints.
+func serviceCode(err error) string {
+	return strconv.Itoa(adminStatus(err))
+}
+
+func (m *Monitor) respondJSON(req micro.Request, v interface{}) {
+	if err := req.RespondJSON(v); err != nil {
+		m.logger.Warn("service respond failed", "subject", req.Subject(), "err", err)
+	}
+}
+
+func (m *Monitor) respondError(req micro.Request, code, description string) {
+	if err := req.Error(code, description, nil); err != nil {
+		m.logger.Warn("service respond failed", "subject", req.Subject(), "err", err)
+	}
+}
@@ -0,0 +1,216 @@
+package monitor_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/internal/monitor/monitortest"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestServiceEndpoints(t *testing.T) {
+	h := monitortest.Start(t, runServer(t), monitor.Config{ServiceSubject: "hbsvc"})
+	h.Beat(heartbeat.Message{Subject: "svc", Interval: time.Minute, Description: "svc"})
+
+	msg, err := h.Conn.Request("hbsvc.status", nil, monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("status request: %v", err)
+	}
+	if msg.Header.Get(statusapi.MonitorIDHeader) == "" {
+		t.Fatalf("expected %s header", statusapi.MonitorIDHeader)
+	}
+	var status struct {
+		Subjects []monitortest.Subject `json:"subjects"`
+	}
+	if err := json.Unmarshal(msg.Data, &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if len(status.Subjects) != 1 || status.Subjects[0].Subject != "svc" {
+		t.Fatalf("unexpected status subjects: %+v", status.Subjects)
+	}
+
+	msg, err = h.Conn.Request("hbsvc.subject.info", []byte("svc"), monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("subject.info request: %v", err)
+	}
+	var info monitortest.Subject
+	if err := json.Unmarshal(msg.Data, &info); err != nil || info.Subject != "svc" {
+		t.Fatalf("unexpected subject info %s (err %v)", msg.Data, err)
+	}
+
+	msg, err = h.Conn.Request("hbsvc.subject.info", []byte("missing"), monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("subject.info request: %v", err)
+	}
+	if code := msg.Header.Get(micro.ErrorCodeHeader); code != "404" {
+		t.Fatalf("expected 404 for unknown subject, got %q", code)
+	}
+
+	msg, err = h.Conn.Request("hbsvc.history", []byte("svc 1"), monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("history request: %v", err)
+	}
+	var hist struct {
+		Entries []json.RawMessage `json:"entries"`
+	}
+	if err := json.Unmarshal(msg.Data, &hist); err != nil || len(hist.Entries) != 1 {
+		t.Fatalf("expected one history entry, got %s (err %v)", msg.Data, err)
+	}
+
+	msg, err = h.Conn.Request("hbsvc.report", []byte("window=bogus"), monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("report request: %v", err)
+	}
+	if code := msg.Header.Get(micro.ErrorCodeHeader); code != "400" {
+		t.Fatalf("expected 400 for invalid window, got %q", code)
+	}
+
+	msg, err = h.Conn.Request("hbsvc.silence", []byte("svc 1h"), monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("silence request: %v", err)
+	}
+	var silenced struct {
+		OK            bool       `json:"ok"`
+		SilencedUntil *time.Time `json:"silenced_until"`
+	}
+	if err := json.Unmarshal(msg.Data, &silenced); err != nil || !silenced.OK || silenced.SilencedUntil == nil {
+		t.Fatalf("unexpected silence reply %s (err %v)", msg.Data, err)
+	}
+
+	msg, err = h.Conn.Request("hbsvc.ack", []byte("svc"), monitortest.Timeout)
+	if err != nil {
+		t.Fatalf("ack request: %v", err)
+	}
+	if code := msg.Header.Get(micro.ErrorCodeHeader); code != "409" {
+		t.Fatalf("expected 409 acknowledging a healthy subject, got %q", code)
+	}
+}
+
+func TestServiceEveryInstanceReplies(t *testing.T) {
+	h := monitortest.Start(t, runServer(t), monitor.Config{ServiceSubject: "hbsvc"})
+
+	clock := monitortest.NewFakeClock(h.Clock.Now())
+	nc := monitortest.Connect(t, h.URL)
+	second := monitor.New(nc, nil, monitor.Config{
+		Prefix:         "heartbeat",
+		ServiceSubject: "hbsvc",
+		Clock:          clock,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- second.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	if !clock.WaitForTickers(1, monitortest.Timeout) {
+		t.Fatalf("second monitor did not start")
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	inbox := h.Conn.NewRespInbox()
+	sub, err := h.Conn.SubscribeSync(inbox)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := h.Conn.PublishRequest("hbsvc.status", inbox, nil); err != nil {
+		t.Fatalf("publish request: %v", err)
+	}
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg, err := sub.NextMsg(monitortest.Timeout)
+		if err != nil {
+			t.Fatalf("reply %d: %v", i+1, err)
+		}
+		ids[msg.Header.Get(statusapi.MonitorIDHeader)] = true
+	}
+	if len(ids) != 2 {
+		t.Fatalf("expected replies from two monitors, got %v", ids)
+	}
+}
+
+func TestNATSRequestsNeedCredentials(t *testing.T) {
+	h := monitortest.Start(t, runServer(t), monitor.Config{
+		ServiceSubject: "hbsvc",
+		AdminSubject:   "hbadmin",
+		StatusAuth: []monitor.Credential{
+			{Role: monitor.RoleRead, Token: "reader"},
+			{Role: monitor.RoleAdmin, User: "ops", Password: "pw"},
+		},
+	})
+	h.Beat(heartbeat.Message{Subject: "svc", Interval: time.Minute})
+
+	request := func(subject, data, auth string) *nats.Msg {
+		t.Helper()
+		req := nats.NewMsg(subject)
+		req.Data = []byte(data)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		msg, err := h.Conn.RequestMsg(req, monitortest.Timeout)
+		if err != nil {
+			t.Fatalf("%s request: %v", subject, err)
+		}
+		return msg
+	}
+	admin := "Basic " + base64.StdEncoding.EncodeToString([]byte("ops:pw"))
+
+	cases := []struct {
+		name    string
+		subject string
+		data    string
+		auth    string
+		code    string
+	}{
+		{"anonymous status", "hbsvc.status", "", "", "401"},
+		{"wrong token", "hbsvc.status", "", "Bearer nope", "401"},
+		{"reader status", "hbsvc.status", "", "Bearer reader", ""},
+		{"reader history", "hbsvc.history", "svc", "Bearer reader", ""},
+		{"reader cannot silence", "hbsvc.silence", "svc 1h", "Bearer reader", "403"},
+		{"reader cannot ack", "hbsvc.ack", "svc", "Bearer reader", "403"},
+		{"admin silences", "hbsvc.silence", "svc 1h", admin, ""},
+	}
+	for _, tc := range cases {
+		msg := request(tc.subject, tc.data, tc.auth)
+		if code := msg.Header.Get(micro.ErrorCodeHeader); code != tc.code {
+			t.Errorf("%s: error code %q, want %q (%s)", tc.name, code, tc.code, msg.Header.Get(micro.ErrorHeader))
+		}
+	}
+
+	adminCases := []struct {
+		name  string
+		op    string
+		auth  string
+		error string
+	}{
+		{"anonymous forget", "forget", "", "authentication required"},
+		{"reader cannot forget", "forget", "Bearer reader", "admin role required"},
+		{"reader cannot silence", "silence", "Bearer reader", "admin role required"},
+		{"admin forgets", "forget", admin, ""},
+	}
+	for _, tc := range adminCases {
+		data := "svc"
+		if tc.op == "silence" {
+			data = "svc 1h"
+		}
+		var resp statusapi.AdminResult
+		if err := json.Unmarshal(request("hbadmin."+tc.op, data, tc.auth).Data, &resp); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		if resp.Error != tc.error || resp.OK != (tc.error == "") {
+			t.Errorf("%s: got %+v, want error %q", tc.name, resp, tc.error)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+const (
+	defaultShardHeartbeat = 2 * time.Second
+	// shardVirtualNodes is how many points each member places on the hash
+	// ring; more points spread subjects more evenly.
+	shardVirtualNodes = 128
+	// shardMissedAnnouncements is how many announcements a peer may miss
+	// before it is dropped from the ring.
+	shardMissedAnnouncements = 3
+
+	shardJoin  = "join"
+	shardAlive = "alive"
+	shardLeave = "leave"
+)
+
+// hashRing assigns subjects to shard members by consistent hashing, so a
+// member joining or leaving only moves the subjects it gains or loses.
+type hashRing struct {
+	members []string
+	points  []uint64
+	owners  []string
+}
+
+func newHashRing(members []string) *hashRing {
+	r := &hashRing{members: members}
+	type point struct {
+		hash  uint64
+		owner string
+	}
+	points := make([]point, 0, len(members)*shardVirtualNodes)
+	for _, member := range members {
+		for i := 0; i < shardVirtualNodes; i++ {
+			points = append(points, point{shardHash(fmt.Sprintf("%s#%d", member, i)), member})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].owner < points[j].owner
+	})
+	for _, p := range points {
+		r.points = append(r.points, p.hash)
+		r.owners = append(r.owners, p.owner)
+	}
+	return r
+}
+
+// owner returns the member owning key: the first point at or after the
+// key's hash, wrapping around the ring.
+func (r *hashRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := shardHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+// shardHash is FNV-1a followed by a 64-bit finalizer, which spreads the
+// similar, short subject names fleets tend to use.
+func shardHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// shardMessage is published on "<ShardGroup>.members" to announce, keep
+// alive and withdraw a member. Instance tells apart two processes
+// misconfigured with the same member name.
+type shardMessage struct {
+	Op       string `json:"op"`
+	Member   string `json:"member"`
+	Instance string `json:"instance"`
+}
+
+// shardState tracks the other members of the shard group.
+type shardState struct {
+	mu       sync.Mutex
+	instance string
+	// peers maps other members to when they were last heard from.
+	peers map[string]time.Time
+}
+
+// shardMember returns the name this monitor joins its shard group under.
+func shardMember(cfg Config) string {
+	if cfg.ShardMember != "" {
+		return cfg.ShardMember
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return nuid.Next()
+}
+
+func (m *Monitor) shardSubject() string {
+	return m.config().ShardGroup + ".members"
+}
+
+// owns reports whether the NATS subject belongs to this monitor's shard.
+// Unsharded monitors own every subject.
+func (m *Monitor) owns(subject string) bool {
+	ring := m.ring.Load()
+	return ring == nil || ring.owner(subject) == m.shardID
+}
+
+// joinShard subscribes to the shard group, announces this monitor and waits
+// one ShardHeartbeat for the other members to answer before building the
+// ring, so the monitor never briefly claims the whole subject space.
+func (m *Monitor) joinShard(ctx context.Context) (*nats.Subscription, error) {
+	cfg := m.config()
+	m.shard.instance = nuid.Next()
+	m.shard.peers = make(map[string]time.Time)
+
+	sub, err := m.nc.Subscribe(m.shardSubject(), func(msg *nats.Msg) {
+		m.handleShardMessage(ctx, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.announceShard(shardJoin)
+
+	wait := m.clock.NewTicker(cfg.ShardHeartbeat)
+	select {
+	case <-ctx.Done():
+	case <-wait.C():
+	}
+	wait.Stop()
+	m.shard.mu.Lock()
+	members := m.shardMembers()
+	m.ring.Store(newHashRing(members))
+	m.shard.mu.Unlock()
+	m.logger.Info("joined shard group", "group", cfg.ShardGroup, "member", m.shardID, "members", members)
+	return sub, nil
+}
+
+// leaveShard withdraws this monitor so its peers take over its subjects
+// without waiting for it to time out.
+func (m *Monitor) leaveShard(sub *nats.Subscription) {
+	_ = sub.Unsubscribe()
+	m.announceShard(shardLeave)
+	_ = m.nc.FlushTimeout(time.Second)
+}
+
+func (m *Monitor) announceShard(op string) {
+	data, _ := json.Marshal(shardMessage{Op: op, Member: m.shardID, Instance: m.shard.instance})
+	if err := m.nc.Publish(m.shardSubject(), data); err != nil {
+		m.logger.Warn("shard announcement failed", "op", op, "err", err)
+	}
+}
+
+func (m *Monitor) handleShardMessage(ctx context.Context, msg *nats.Msg) {
+	var sm shardMessage
+	if err := json.Unmarshal(msg.Data, &sm); err != nil || sm.Member == "" {
+		m.logger.Warn("invalid shard message", "subject", msg.Subject, "err", err)
+		return
+	}
+	if sm.Member == m.shardID {
+		if sm.Instance != m.shard.instance {
+			m.logger.Warn("another monitor uses this shard member name", "member", sm.Member)
+		}
+		return
+	}
+
+	m.shard.mu.Lock()
+	defer m.shard.mu.Unlock()
+	_, known := m.shard.peers[sm.Member]
+	switch sm.Op {
+	case shardLeave:
+		delete(m.shard.peers, sm.Member)
+	case shardJoin:
+		m.shard.peers[sm.Member] = m.clock.Now()
+		// answer right away so the new member sees us before it settles
+		m.announceShard(shardAlive)
+	default:
+		m.shard.peers[sm.Member] = m.clock.Now()
+	}
+	if _, present := m.shard.peers[sm.Member]; present != known && m.ring.Load() != nil {
+		m.rebalance(ctx, m.shardMembers())
+	}
+}
+
+// runShard announces this monitor every ShardHeartbeat and drops peers
+// that stopped announcing, rebalancing when the membership changes.
+func (m *Monitor) runShard(ctx context.Context) {
+	cfg := m.config()
+	ticker := m.clock.NewTicker(cfg.ShardHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		m.announceShard(shardAlive)
+
+		now := m.clock.Now()
+		m.shard.mu.Lock()
+		changed := false
+		for peer, seen := range m.shard.peers {
+			if now.Sub(seen) > shardMissedAnnouncements*cfg.ShardHeartbeat {
+				delete(m.shard.peers, peer)
+				changed = true
+				m.logger.Info("shard member timed out", "member", peer, "last_seen", seen)
+			}
+		}
+		if changed {
+			m.rebalance(ctx, m.shardMembers())
+		}
+		m.shard.mu.Unlock()
+	}
+}
+
+// shardMembers returns the sorted members, this monitor included. Callers
+// hold shard.mu.
+func (m *Monitor) shardMembers() []string {
+	members := []string{m.shardID}
+	for peer := range m.shard.peers {
+		members = append(members, peer)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// rebalance rebuilds the ring for members and drops the subjects this
+// monitor no longer owns. Subjects it gains are read from the prime stream
+// straight away, so one that is silent or alerting is not lost in the
+// handover. Callers hold shard.mu.
+func (m *Monitor) rebalance(ctx context.Context, members []string) {
+	ring := newHashRing(members)
+	old := m.ring.Swap(ring)
+	m.counters.rebalances.Add(1)
+
+	var dropped []string
+	m.mu.Lock()
+	for key, s := range m.state {
+		if ring.owner(m.natsSubjectFor(key)) != m.shardID {
+			m.unschedule(s)
+			delete(m.state, key)
+			delete(m.uptime, key)
+			dropped = append(dropped, key)
+		}
+	}
+	owned := len(m.state)
+	m.mu.Unlock()
+
+	m.historyMu.Lock()
+	for subject := range m.history {
+		if ring.owner(m.natsSubjectFor(subject)) != m.shardID {
+			delete(m.history, subject)
+		}
+	}
+	m.historyMu.Unlock()
+	m.events.touch()
+	m.logger.Info("shard rebalanced", "group", m.config().ShardGroup, "member", m.shardID, "members", members, "subjects", owned, "handed_off", len(dropped))
+
+	// members can briefly disagree on the membership, so any change may hand
+	// this monitor subjects, not only a member leaving
+	if m.nc != nil && old != nil {
+		go func() {
+			if err := m.primeCache(ctx); err != nil && ctx.Err() == nil {
+				m.logger.Warn("prime cache after rebalance failed", "err", err)
+			}
+		}()
+	}
+}
+
+// shardStatus describes this monitor's shard for the status API, or nil
+// when sharding is off.
+func (m *Monitor) shardStatus() *statusapi.Shard {
+	ring := m.ring.Load()
+	if ring == nil {
+		return nil
+	}
+	return &statusapi.Shard{
+		Group:   m.config().ShardGroup,
+		Member:  m.shardID,
+		Members: ring.members,
+	}
+}
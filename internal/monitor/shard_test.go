@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+func TestHashRingSpreadsSubjects(t *testing.T) {
+	ring := newHashRing([]string{"a", "b", "c"})
+	counts := make(map[string]int)
+	const subjects = 30000
+	for i := 0; i < subjects; i++ {
+		counts[ring.owner(fmt.Sprintf("host%d.backup", i))]++
+	}
+	for _, member := range []string{"a", "b", "c"} {
+		// an even split is 10000 each
+		if n := counts[member]; n < 7000 || n > 13000 {
+			t.Errorf("member %s owns %d of %d subjects: %v", member, n, subjects, counts)
+		}
+	}
+}
+
+func TestHashRingMovesOnlyTheJoiningMembersSubjects(t *testing.T) {
+	before := newHashRing([]string{"a", "b", "c"})
+	after := newHashRing([]string{"a", "b", "c", "d"})
+	moved := 0
+	const subjects = 20000
+	for i := 0; i < subjects; i++ {
+		key := fmt.Sprintf("svc-%d", i)
+		was, is := before.owner(key), after.owner(key)
+		if was == is {
+			continue
+		}
+		if is != "d" {
+			t.Fatalf("%s moved from %s to %s, not to the new member", key, was, is)
+		}
+		moved++
+	}
+	// the new member should take about a quarter
+	if moved < subjects/8 || moved > subjects*3/8 {
+		t.Fatalf("expected about %d subjects to move, got %d", subjects/4, moved)
+	}
+}
+
+func TestOwnsWithoutShardGroup(t *testing.T) {
+	m := New(nil, nil, Config{ShardMember: "a"})
+	if !m.owns("anything") {
+		t.Fatalf("an unsharded monitor should own every subject")
+	}
+	m.ring.Store(newHashRing([]string{"a", "b"}))
+	owned := 0
+	for i := 0; i < 100; i++ {
+		if m.owns(fmt.Sprintf("svc-%d", i)) {
+			owned++
+		}
+	}
+	if owned == 0 || owned == 100 {
+		t.Fatalf("expected the subjects to be split, a owns %d of 100", owned)
+	}
+}
+
+func TestHandleMessageDropsOtherShardsBeforeDecoding(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat", ShardMember: "a"})
+	ring := newHashRing([]string{"a", "b"})
+	m.ring.Store(ring)
+	var mine, theirs string
+	for i := 0; mine == "" || theirs == ""; i++ {
+		subject := fmt.Sprintf("svc-%d", i)
+		if ring.owner("heartbeat."+subject) == "a" {
+			mine = subject
+		} else {
+			theirs = subject
+		}
+	}
+
+	// the payload is never looked at for another member's subject
+	m.handleMessage(context.Background(), &nats.Msg{Subject: "heartbeat." + theirs, Data: []byte("not json")}, time.Now())
+	if got := m.counters.decodeErrors.Load(); got != 0 {
+		t.Fatalf("expected another member's beat to be dropped before decoding, got %d decode errors", got)
+	}
+	if got := m.counters.otherShard.Load(); got != 1 {
+		t.Fatalf("expected 1 beat skipped for another shard, got %d", got)
+	}
+
+	// a beat on an owned NATS subject cannot claim another member's subject
+	data, err := heartbeat.Message{Subject: theirs, GeneratedAt: time.Now(), Interval: time.Minute}.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	m.handleMessage(context.Background(), &nats.Msg{Subject: "heartbeat." + mine, Data: data}, time.Now())
+	if m.tracking(theirs) {
+		t.Fatalf("expected a beat for %s published on %s to be dropped", theirs, mine)
+	}
+
+	data, _ = heartbeat.Message{Subject: mine, GeneratedAt: time.Now(), Interval: time.Minute}.Marshal()
+	m.handleMessage(context.Background(), &nats.Msg{Subject: "heartbeat." + mine, Data: data}, time.Now())
+	if !m.tracking(mine) {
+		t.Fatalf("expected %s to be tracked", mine)
+	}
+}
+
+func TestRebalanceKeysByNATSSubject(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat", ShardMember: "a"})
+	now := time.Now()
+	for i := 0; i < 50; i++ {
+		subject := fmt.Sprintf("svc-%d", i)
+		data, _ := heartbeat.Message{Subject: subject, GeneratedAt: now, Interval: time.Minute}.Marshal()
+		m.handleMessage(context.Background(), &nats.Msg{Subject: "heartbeat." + subject, Data: data}, now)
+	}
+
+	m.shard.mu.Lock()
+	m.rebalance(context.Background(), []string{"a", "b"})
+	m.shard.mu.Unlock()
+
+	ring := m.ring.Load()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.state) == 0 || len(m.state) == 50 {
+		t.Fatalf("expected the subjects to be split, a kept %d of 50", len(m.state))
+	}
+	for i := 0; i < 50; i++ {
+		subject := fmt.Sprintf("svc-%d", i)
+		_, kept := m.state[subject]
+		if owned := ring.owner("heartbeat."+subject) == "a"; kept != owned {
+			t.Errorf("%s: kept %t, but owned %t", subject, kept, owned)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
+)
+
+// ErrNotAlerting is returned when acknowledging a subject with no active
+// alert.
+var ErrNotAlerting = errors.New("subject is not alerting")
+
+// Silence suppresses notifications for a subject until d from now. Alerts,
+// resolves and expiries are still tracked, recorded in the history and
+// streamed to /events; only the notifier is skipped. A zero or negative d
+// lifts an existing silence. It returns the end of the silence.
+func (m *Monitor) Silence(subject string, d time.Duration) (time.Time, error) {
+	var until time.Time
+	if d > 0 {
+		until = m.clock.Now().Add(d)
+	}
+	m.mu.Lock()
+	s, ok := m.state[subject]
+	if ok {
+		s.silencedUntil = until
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return time.Time{}, ErrUnknownSubject
+	}
+	if until.IsZero() {
+		m.logger.Info("silence lifted", "subject", subject)
+	} else {
+		m.logger.Info("subject silenced", "subject", subject, "until", until)
+	}
+	m.events.touch()
+	return until, nil
+}
+
+// Ack acknowledges a subject's active alert: repeat notifications stop until
+// the subject resolves, after which a new outage alerts as usual.
+func (m *Monitor) Ack(subject string) error {
+	m.mu.Lock()
+	s, ok := m.state[subject]
+	alerting := ok && s.alertActive
+	if alerting {
+		s.acked = true
+		// the repeat alerts it was queued for are off
+		m.schedule(s, m.clock.Now())
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownSubject
+	}
+	if !alerting {
+		return ErrNotAlerting
+	}
+	m.logger.Info("alert acknowledged", "subject", subject)
+	m.events.touch()
+	return nil
+}
+
+// silenced reports whether notifications for s are suppressed at now.
+func (s *state) silenced(now time.Time) bool {
+	return now.Before(s.silencedUntil)
+}
+
+// suppress counts and logs a notification skipped because its subject is
+// silenced.
+func (m *Monitor) suppress(evt notifier.Event) {
+	m.counters.silenced.Add(1)
+	m.logger.Debug("notification silenced", "subject", evt.Subject, "host", evt.Host)
+}
+
+// parseSilence parses a "<subject> <duration>" request body as used by the
+// NATS admin and service silence operations.
+func parseSilence(data []byte) (string, time.Duration, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return "", 0, errors.New("expected <subject> <duration>")
+	}
+	d, err := time.ParseDuration(fields[1])
+	if err != nil {
+		return fields[0], 0, fmt.Errorf("invalid duration: %w", err)
+	}
+	return fields[0], d, nil
+}
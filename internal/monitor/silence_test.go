@@ -0,0 +1,219 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestSilenceSuppressesNotifications(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{RepeatEvery: time.Hour})
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.clock = fixedClock(start)
+	m.state["svc"] = &state{subject: "svc", lastSeen: start.Add(-time.Minute), interval: time.Second}
+	m.schedule(m.state["svc"], start)
+
+	until, err := m.Silence("svc", 10*time.Minute)
+	if err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	if !until.Equal(start.Add(10 * time.Minute)) {
+		t.Fatalf("expected silence until %s, got %s", start.Add(10*time.Minute), until)
+	}
+	m.scan(context.Background())
+	if len(rec.alerts) != 0 {
+		t.Fatalf("expected silenced alert, got %+v", rec.alerts)
+	}
+	if !m.state["svc"].alertActive {
+		t.Fatalf("expected the alert to be tracked while silenced")
+	}
+	if got := m.counters.silenced.Load(); got != 1 {
+		t.Fatalf("expected 1 silenced notification, got %d", got)
+	}
+	if status := m.subjectStatus(start, m.state["svc"]); status.SilencedUntil == nil || !status.SilencedUntil.Equal(until) {
+		t.Fatalf("expected silenced_until in status, got %+v", status.SilencedUntil)
+	}
+
+	// once the silence ends, the still-missing subject repeats as usual
+	m.clock = fixedClock(start.Add(2 * time.Hour))
+	m.scan(context.Background())
+	if len(rec.alerts) != 1 {
+		t.Fatalf("expected alert after the silence ended, got %+v", rec.alerts)
+	}
+	if status := m.subjectStatus(start.Add(2*time.Hour), m.state["svc"]); status.SilencedUntil != nil {
+		t.Fatalf("expected no silenced_until after the silence ended, got %s", status.SilencedUntil)
+	}
+
+	if _, err := m.Silence("missing", time.Minute); !errors.Is(err, ErrUnknownSubject) {
+		t.Fatalf("expected ErrUnknownSubject, got %v", err)
+	}
+}
+
+func TestSilenceZeroLiftsSilence(t *testing.T) {
+	m := New(nil, nil, Config{})
+	m.state["svc"] = &state{subject: "svc", lastSeen: time.Now(), interval: time.Second}
+	if _, err := m.Silence("svc", time.Hour); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	until, err := m.Silence("svc", 0)
+	if err != nil || !until.IsZero() {
+		t.Fatalf("expected silence lifted, got %s (err %v)", until, err)
+	}
+	if m.state["svc"].silenced(time.Now()) {
+		t.Fatalf("expected svc not to be silenced")
+	}
+}
+
+func TestAckStopsRepeatsUntilResolved(t *testing.T) {
+	rec := &recordingNotifier{}
+	m := New(nil, rec, Config{RepeatEvery: time.Minute})
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.clock = fixedClock(start)
+	m.state["svc"] = &state{subject: "svc", lastSeen: start.Add(-time.Minute), interval: time.Second}
+	m.schedule(m.state["svc"], start)
+
+	if err := m.Ack("svc"); !errors.Is(err, ErrNotAlerting) {
+		t.Fatalf("expected ErrNotAlerting before the alert, got %v", err)
+	}
+	m.scan(context.Background())
+	if len(rec.alerts) != 1 {
+		t.Fatalf("expected initial alert, got %d", len(rec.alerts))
+	}
+	if err := m.Ack("svc"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if !m.subjectStatus(start, m.state["svc"]).Acknowledged {
+		t.Fatalf("expected acknowledged in status")
+	}
+
+	m.clock = fixedClock(start.Add(10 * time.Minute))
+	m.scan(context.Background())
+	if len(rec.alerts) != 1 {
+		t.Fatalf("expected no repeat after ack, got %d alerts", len(rec.alerts))
+	}
+
+	// recovery clears the ack, so the next outage pages again
+	m.state["svc"].lastSeen = start.Add(10 * time.Minute)
+	m.schedule(m.state["svc"], start.Add(10*time.Minute))
+	m.scan(context.Background())
+	if len(rec.resolved) != 1 || m.state["svc"].acked {
+		t.Fatalf("expected resolve to clear the ack, resolved %d, acked %v", len(rec.resolved), m.state["svc"].acked)
+	}
+	m.clock = fixedClock(start.Add(20 * time.Minute))
+	m.scan(context.Background())
+	if len(rec.alerts) != 2 {
+		t.Fatalf("expected a new alert for the next outage, got %d", len(rec.alerts))
+	}
+
+	if err := m.Ack("missing"); !errors.Is(err, ErrUnknownSubject) {
+		t.Fatalf("expected ErrUnknownSubject, got %v", err)
+	}
+}
+
+func TestSubjectsHandlerSilenceAndAck(t *testing.T) {
+	m := New(nil, nil, Config{})
+	now := time.Now()
+	m.state["svc"] = &state{subject: "svc", lastSeen: now, interval: time.Second}
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodPost, "/subjects/svc/silence?for=1h", http.StatusOK},
+		{http.MethodPost, "/subjects/svc/silence?for=soon", http.StatusBadRequest},
+		{http.MethodGet, "/subjects/svc/silence?for=1h", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/subjects/missing/silence?for=1h", http.StatusNotFound},
+		{http.MethodPost, "/subjects/svc/ack", http.StatusConflict},
+		{http.MethodPost, "/subjects/missing/ack", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		m.subjectsHandler().ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d (%s)", tc.method, tc.path, tc.code, rec.Code, rec.Body.String())
+		}
+	}
+	if !m.state["svc"].silenced(now) {
+		t.Fatalf("expected svc to be silenced")
+	}
+
+	m.state["svc"].alertActive = true
+	rec := httptest.NewRecorder()
+	m.subjectsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/subjects/svc/ack", nil))
+	var resp statusapi.AdminResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK || !resp.OK {
+		t.Fatalf("expected ack to succeed, got %d %s", rec.Code, rec.Body.String())
+	}
+	if !m.state["svc"].acked {
+		t.Fatalf("expected svc to be acknowledged")
+	}
+}
+
+func TestParseSilence(t *testing.T) {
+	cases := []struct {
+		in      string
+		subject string
+		d       time.Duration
+		wantErr bool
+	}{
+		{in: "svc 1h", subject: "svc", d: time.Hour},
+		{in: " svc  0s ", subject: "svc"},
+		{in: "svc", wantErr: true},
+		{in: "svc soon", wantErr: true},
+		{in: "svc 1h extra", wantErr: true},
+	}
+	for _, tc := range cases {
+		subject, d, err := parseSilence([]byte(tc.in))
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil || subject != tc.subject || d != tc.d {
+			t.Errorf("%q: got %q %s (err %v)", tc.in, subject, d, err)
+		}
+	}
+}
+
+func TestBeatResolveClearsAckAndHonoursSilence(t *testing.T) {
+	m := New(nil, &recordingNotifier{}, Config{Prefix: "heartbeat", RepeatEvery: time.Minute})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	beat := func(at time.Time) {
+		data, err := heartbeat.Message{Subject: "svc", GeneratedAt: at, Interval: 10 * time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, at)
+	}
+	beat(start)
+	m.clock = fixedClock(start.Add(time.Minute))
+	m.scan(ctx)
+	if err := m.Ack("svc"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if _, err := m.Silence("svc", time.Hour); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+
+	// a beat resolves the alert without waiting for the next poll
+	beat(start.Add(time.Minute))
+	if s := m.state["svc"]; s.alertActive || s.acked {
+		t.Fatalf("expected the beat to resolve the alert and clear the ack, alerting %v, acked %v", s.alertActive, s.acked)
+	}
+	if got := m.counters.silenced.Load(); got != 1 {
+		t.Fatalf("expected the resolve to be silenced, got %d silenced notifications", got)
+	}
+}
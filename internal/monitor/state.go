@@ -3,19 +3,73 @@ package monitor
 import (
 	"time"
 
+	"github.com/venkytv/nats-heartbeat/internal/notifier"
 	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
 )
 
 type state struct {
-	subject     string
-	description string
-	host        string
-	lastSeen    time.Time
-	interval    time.Duration
-	grace       *time.Duration
-	alertActive bool
-	missCount   int
-	lastAlert   time.Time
+	subject      string
+	natsSubject  string
+	description  string
+	host         string
+	lastSeen     time.Time // monitor receive time of the latest heartbeat
+	generatedAt  time.Time // publisher timestamp of the latest heartbeat
+	skew         time.Duration
+	skewExceeded bool
+	interval     time.Duration
+	grace        *time.Duration
+	alertActive  bool
+	missCount    int
+	lastAlert    time.Time
+	acked        bool
+
+	silencedUntil time.Time
+
+	bootID              string
+	boots               map[string]bootInfo
+	restarts            int
+	lostBeats           uint64
+	lastRestart         time.Time
+	duplicatePublishers bool
+	pending             pendingRestart
+
+	hosts        map[string]*hostState
+	hostConflict bool
+
+	unverified bool
+
+	cadence       cadence
+	driftExceeded bool
+
+	// checkAt is when scan next evaluates the subject and queueIndex its
+	// position in the monitor's deadline queue.
+	checkAt    time.Time
+	queueIndex int
+}
+
+// maxRecentBeats is how many beat times the status API reports per subject.
+const maxRecentBeats = 30
+
+type bootInfo struct {
+	seq      uint64
+	lastSeen time.Time
+}
+
+// pendingRestart is a boot ID that replaced prev but has not yet been
+// confirmed as a restart, because prev may still be publishing.
+type pendingRestart struct {
+	boot  string
+	prev  string
+	since time.Time
+}
+
+// instanceChange describes what trackInstance learned from a heartbeat.
+type instanceChange struct {
+	restarted bool
+	lost      uint64
+	// duplicate is set when duplicatePublishers flips, with the new value in
+	// s.duplicatePublishers.
+	duplicate bool
 }
 
 func newState(msg heartbeat.Message) state {
@@ -24,11 +78,122 @@ func newState(msg heartbeat.Message) state {
 		description: descriptionOrSubject(msg),
 		host:        msg.Host,
 		lastSeen:    msg.GeneratedAt,
+		generatedAt: msg.GeneratedAt,
 		interval:    msg.Interval,
 		grace:       msg.GracePeriod,
 	}
 }
 
+// event returns a notification for s carrying its current state; callers
+// add the miss details or notice reason.
+func (s *state) event() notifier.Event {
+	return notifier.Event{
+		Subject:       s.subject,
+		Description:   s.description,
+		Host:          s.host,
+		LastSeen:      s.lastSeen,
+		Interval:      s.interval,
+		AllowedWindow: s.allowedWindow(),
+		GeneratedAt:   s.generatedAt,
+		BootID:        s.bootID,
+		Sequence:      s.boots[s.bootID].seq,
+	}
+}
+
+// observe records an accepted heartbeat. Liveness is tracked using the
+// monitor's receive time so that publisher clock errors cannot mask or
+// fabricate missed beats; the difference is kept as skew.
+func (s *state) observe(msg heartbeat.Message, receivedAt time.Time) {
+	s.lastSeen = receivedAt
+	s.generatedAt = msg.GeneratedAt
+	s.skew = receivedAt.Sub(msg.GeneratedAt)
+	s.interval = msg.Interval
+	s.grace = msg.GracePeriod
+	s.host = msg.Host
+	s.description = descriptionOrSubject(msg)
+}
+
+// isStale reports whether msg predates the latest accepted heartbeat and
+// arrived later than the allowed window, i.e. it was delayed or replayed.
+// Duplicates of the latest heartbeat are always stale.
+// Older timestamps that arrive promptly are accepted so a publisher whose
+// clock is stepped backwards does not get stuck.
+func (s state) isStale(msg heartbeat.Message, receivedAt time.Time) bool {
+	if msg.GeneratedAt.After(s.generatedAt) {
+		return false
+	}
+	if msg.GeneratedAt.Equal(s.generatedAt) {
+		return true
+	}
+	return receivedAt.Sub(msg.GeneratedAt) > s.allowedWindow()
+}
+
+// trackInstance follows boot IDs and sequence numbers to detect publisher
+// restarts, lost heartbeats and several processes publishing the same
+// subject. Boot IDs seen within twice the allowed window count as live.
+// A new boot ID only counts as a restart once the previous one has been
+// quiet for the allowed window; if the previous ID beats again first, the
+// two are treated as duplicate publishers instead.
+func (s *state) trackInstance(msg heartbeat.Message, receivedAt time.Time) instanceChange {
+	var change instanceChange
+	if msg.BootID == "" {
+		return change
+	}
+	if s.boots == nil {
+		s.boots = make(map[string]bootInfo)
+	}
+
+	live := 2 * s.allowedWindow()
+	for id, b := range s.boots {
+		if id != msg.BootID && receivedAt.Sub(b.lastSeen) > live {
+			delete(s.boots, id)
+		}
+	}
+
+	prev, known := s.boots[msg.BootID]
+	if known && prev.seq > 0 && msg.Sequence > prev.seq+1 {
+		change.lost = msg.Sequence - prev.seq - 1
+		s.lostBeats += change.lost
+	}
+	if s.bootID != "" && msg.BootID != s.bootID && !known {
+		s.pending = pendingRestart{boot: msg.BootID, prev: s.bootID, since: receivedAt}
+	}
+	switch msg.BootID {
+	case s.pending.prev:
+		s.pending = pendingRestart{}
+	case s.pending.boot:
+		if old, ok := s.boots[s.pending.prev]; !ok || receivedAt.Sub(old.lastSeen) > s.allowedWindow() {
+			change.restarted = true
+			s.restarts++
+			s.lastRestart = s.pending.since
+			s.pending = pendingRestart{}
+		}
+	}
+
+	// switching back to another live boot ID means several processes are
+	// publishing concurrently; a plain restart never revisits the old ID.
+	duplicate := s.duplicatePublishers
+	if known && s.bootID != "" && msg.BootID != s.bootID {
+		duplicate = true
+	}
+
+	next := bootInfo{seq: msg.Sequence, lastSeen: receivedAt}
+	if prev.seq > next.seq {
+		next.seq = prev.seq
+	}
+	s.boots[msg.BootID] = next
+	s.bootID = msg.BootID
+
+	if len(s.boots) == 1 {
+		duplicate = false
+	}
+	if duplicate != s.duplicatePublishers {
+		s.duplicatePublishers = duplicate
+		change.duplicate = true
+	}
+	return change
+}
+
 func (s state) allowedWindow() time.Duration {
 	if s.grace != nil && *s.grace > 0 {
 		return *s.grace
@@ -42,3 +207,10 @@ func descriptionOrSubject(msg heartbeat.Message) string {
 	}
 	return msg.Subject
 }
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -42,3 +42,125 @@ func TestNewStateCapturesHost(t *testing.T) {
 		t.Fatalf("expected host host-1, got %s", st.host)
 	}
 }
+
+func TestObserveUsesReceiveTimeAndRecordsSkew(t *testing.T) {
+	generated := time.Now().Add(-time.Minute)
+	received := generated.Add(90 * time.Second)
+	msg := heartbeat.Message{Subject: "svc", GeneratedAt: generated, Interval: time.Second}
+
+	st := newState(msg)
+	st.observe(msg, received)
+	if !st.lastSeen.Equal(received) {
+		t.Fatalf("expected last seen %s, got %s", received, st.lastSeen)
+	}
+	if st.skew != 90*time.Second {
+		t.Fatalf("expected skew 90s, got %s", st.skew)
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	now := time.Now()
+	msg := heartbeat.Message{Subject: "svc", GeneratedAt: now, Interval: 10 * time.Second}
+	st := newState(msg)
+	st.observe(msg, now)
+
+	if !st.isStale(msg, now.Add(time.Second)) {
+		t.Fatalf("expected duplicate heartbeat to be stale")
+	}
+
+	replayed := msg
+	replayed.GeneratedAt = now.Add(-time.Minute)
+	if !st.isStale(replayed, now.Add(time.Second)) {
+		t.Fatalf("expected delayed heartbeat to be stale")
+	}
+
+	stepped := msg
+	stepped.GeneratedAt = now.Add(-time.Minute)
+	if st.isStale(stepped, stepped.GeneratedAt.Add(time.Second)) {
+		t.Fatalf("expected promptly delivered heartbeat with older clock to be accepted")
+	}
+
+	next := msg
+	next.GeneratedAt = now.Add(10 * time.Second)
+	if st.isStale(next, now.Add(10*time.Second)) {
+		t.Fatalf("expected newer heartbeat to be accepted")
+	}
+}
+
+func TestTrackInstanceDetectsRestartAndLostBeats(t *testing.T) {
+	now := time.Now()
+	beat := func(boot string, seq uint64, at time.Time) heartbeat.Message {
+		return heartbeat.Message{Subject: "svc", GeneratedAt: at, Interval: time.Second, BootID: boot, Sequence: seq}
+	}
+
+	st := newState(beat("a", 1, now))
+	st.trackInstance(beat("a", 1, now), now)
+
+	change := st.trackInstance(beat("a", 4, now.Add(time.Second)), now.Add(time.Second))
+	if change.lost != 2 || st.lostBeats != 2 {
+		t.Fatalf("expected 2 lost beats, got %d (total %d)", change.lost, st.lostBeats)
+	}
+
+	// the new boot ID is only a restart once "a" has missed its next beat
+	change = st.trackInstance(beat("b", 1, now.Add(2*time.Second)), now.Add(2*time.Second))
+	if change.restarted || st.restarts != 0 {
+		t.Fatalf("expected restart to wait for the old boot ID to go quiet, got %+v", change)
+	}
+
+	change = st.trackInstance(beat("b", 2, now.Add(3*time.Second)), now.Add(3*time.Second))
+	if !change.restarted || st.restarts != 1 {
+		t.Fatalf("expected restart to be detected, got %+v (restarts %d)", change, st.restarts)
+	}
+	if !st.lastRestart.Equal(now.Add(2 * time.Second)) {
+		t.Fatalf("expected restart time to be the first beat of the new boot ID, got %s", st.lastRestart)
+	}
+	if st.duplicatePublishers {
+		t.Fatalf("expected restart not to be flagged as duplicate publishers")
+	}
+
+	change = st.trackInstance(beat("b", 3, now.Add(4*time.Second)), now.Add(4*time.Second))
+	if change.restarted || change.duplicate || change.lost != 0 {
+		t.Fatalf("expected steady heartbeat to report no change, got %+v", change)
+	}
+}
+
+func TestTrackInstanceDoesNotCountSecondPublisherAsRestart(t *testing.T) {
+	now := time.Now()
+	beat := func(boot string, seq uint64, at time.Duration) (heartbeat.Message, time.Time) {
+		return heartbeat.Message{Subject: "svc", GeneratedAt: now.Add(at), Interval: 10 * time.Second, BootID: boot, Sequence: seq}, now.Add(at)
+	}
+
+	st := newState(heartbeat.Message{Subject: "svc", GeneratedAt: now, Interval: 10 * time.Second})
+	for i := 0; i < 5; i++ {
+		at := time.Duration(i) * 10 * time.Second
+		st.trackInstance(beat("host-a", uint64(i+1), at))
+		st.trackInstance(beat("host-b", uint64(i+1), at+3*time.Second))
+	}
+	if st.restarts != 0 {
+		t.Fatalf("expected two concurrent publishers not to count as restarts, got %d", st.restarts)
+	}
+	if !st.duplicatePublishers {
+		t.Fatalf("expected concurrent publishers to be flagged as duplicates")
+	}
+}
+
+func TestTrackInstanceDetectsDuplicatePublishers(t *testing.T) {
+	now := time.Now()
+	beat := func(boot string, seq uint64) heartbeat.Message {
+		return heartbeat.Message{Subject: "svc", GeneratedAt: now, Interval: 10 * time.Second, BootID: boot, Sequence: seq}
+	}
+
+	st := newState(beat("a", 1))
+	st.trackInstance(beat("a", 1), now)
+	st.trackInstance(beat("b", 1), now.Add(time.Second))
+	change := st.trackInstance(beat("a", 2), now.Add(2*time.Second))
+	if !change.duplicate || !st.duplicatePublishers {
+		t.Fatalf("expected duplicate publishers to be flagged, got %+v", change)
+	}
+
+	// once b stops publishing for longer than twice the window, the flag clears
+	change = st.trackInstance(beat("a", 3), now.Add(time.Minute))
+	if !change.duplicate || st.duplicatePublishers {
+		t.Fatalf("expected duplicate flag to clear, got %+v (flag %t)", change, st.duplicatePublishers)
+	}
+}
@@ -3,6 +3,8 @@ package monitor
 import (
 	"testing"
 	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
 )
 
 func TestSnapshotReportsMissingAndHealthy(t *testing.T) {
@@ -34,7 +36,7 @@ func TestSnapshotReportsMissingAndHealthy(t *testing.T) {
 		t.Fatalf("expected 2 subjects, got %d", len(snapshot))
 	}
 
-	var missing, healthy subjectState
+	var missing, healthy statusapi.SubjectStatus
 	if snapshot[0].Subject == "svc-healthy" {
 		healthy = snapshot[0]
 		missing = snapshot[1]
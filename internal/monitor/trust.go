@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+// TrustRule lists the public nkeys allowed to sign heartbeats on subjects
+// matching Pattern (NATS wildcards "*" and ">" are supported).
+type TrustRule struct {
+	Pattern string
+	Keys    []string
+}
+
+// ParseTrustRules reads rules of the form "<pattern> <key> [<key>...]", one
+// per line. Blank lines and lines starting with "#" are ignored.
+func ParseTrustRules(r io.Reader) ([]TrustRule, error) {
+	var rules []TrustRule
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected subject pattern followed by at least one key", line)
+		}
+		rules = append(rules, TrustRule{Pattern: fields[0], Keys: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// LoadTrustRules reads trust rules from path.
+func LoadTrustRules(path string) ([]TrustRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseTrustRules(f)
+}
+
+var (
+	errUntrustedKey = errors.New("signing key is not trusted for subject")
+	errNoTrustRule  = errors.New("no trust rule for subject")
+)
+
+// verifyMessage checks msg, a heartbeat for subject, against the first
+// trust rule matching subject, or failing that the NATS subject. Subjects
+// without a rule are not verified and return errNoTrustRule.
+func (m *Monitor) verifyMessage(msg *nats.Msg, subject string) error {
+	rules := m.config().TrustRules
+	rule, ok := matchTrustRule(rules, subject)
+	if !ok {
+		rule, ok = matchTrustRule(rules, msg.Subject)
+	}
+	if !ok {
+		return errNoTrustRule
+	}
+	key, err := heartbeat.Verify(msg.Header, msg.Data)
+	if err != nil {
+		return err
+	}
+	for _, trusted := range rule.Keys {
+		if key == trusted {
+			return nil
+		}
+	}
+	return errUntrustedKey
+}
+
+func matchTrustRule(rules []TrustRule, subject string) (TrustRule, bool) {
+	for _, rule := range rules {
+		if subjectMatches(rule.Pattern, subject) {
+			return rule, true
+		}
+	}
+	return TrustRule{}, false
+}
+
+// subjectMatches reports whether subject matches a NATS subject pattern.
+func subjectMatches(pattern, subject string) bool {
+	pTokens := strings.Split(pattern, ".")
+	sTokens := strings.Split(subject, ".")
+	for i, p := range pTokens {
+		if p == ">" {
+			return len(sTokens) > i
+		}
+		if i >= len(sTokens) {
+			return false
+		}
+		if p != "*" && p != sTokens[i] {
+			return false
+		}
+	}
+	return len(pTokens) == len(sTokens)
+}
@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+)
+
+func TestSubjectMatches(t *testing.T) {
+	cases := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"heartbeat.api", "heartbeat.api", true},
+		{"heartbeat.*", "heartbeat.api", true},
+		{"heartbeat.*", "heartbeat.api.v2", false},
+		{"heartbeat.>", "heartbeat.api.v2", true},
+		{"heartbeat.>", "heartbeat", false},
+		{"heartbeat.*.v2", "heartbeat.api.v2", true},
+		{"heartbeat.api", "heartbeat.worker", false},
+	}
+	for _, tc := range cases {
+		if got := subjectMatches(tc.pattern, tc.subject); got != tc.want {
+			t.Errorf("subjectMatches(%q, %q) = %t, want %t", tc.pattern, tc.subject, got, tc.want)
+		}
+	}
+}
+
+func TestParseTrustRules(t *testing.T) {
+	rules, err := ParseTrustRules(strings.NewReader("# comment\n\nheartbeat.api.> UAAA UBBB\nheartbeat.> UCCC\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rules) != 2 || len(rules[0].Keys) != 2 || rules[1].Pattern != "heartbeat.>" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	if _, err := ParseTrustRules(strings.NewReader("heartbeat.>\n")); err == nil {
+		t.Fatalf("expected error for rule without keys")
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	kp, _ := nkeys.CreateUser()
+	seed, _ := kp.Seed()
+	signer, err := heartbeat.NewSigner(seed)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+
+	m := New(nil, nil, Config{TrustRules: []TrustRule{{Pattern: "heartbeat.api", Keys: []string{signer.PublicKey()}}}})
+
+	signed := &nats.Msg{Subject: "heartbeat.api", Data: []byte("{}"), Header: nats.Header{}}
+	if err := signer.Sign(signed.Header, signed.Data); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if err := m.verifyMessage(signed, signed.Subject); err != nil {
+		t.Fatalf("expected signed message to verify, got %v", err)
+	}
+
+	unsigned := &nats.Msg{Subject: "heartbeat.api", Data: []byte("{}"), Header: nats.Header{}}
+	if err := m.verifyMessage(unsigned, unsigned.Subject); !errors.Is(err, heartbeat.ErrUnsigned) {
+		t.Fatalf("expected ErrUnsigned, got %v", err)
+	}
+
+	other, _ := nkeys.CreateUser()
+	otherSeed, _ := other.Seed()
+	otherSigner, _ := heartbeat.NewSigner(otherSeed)
+	untrusted := &nats.Msg{Subject: "heartbeat.api", Data: []byte("{}"), Header: nats.Header{}}
+	_ = otherSigner.Sign(untrusted.Header, untrusted.Data)
+	if err := m.verifyMessage(untrusted, untrusted.Subject); !errors.Is(err, errUntrustedKey) {
+		t.Fatalf("expected errUntrustedKey, got %v", err)
+	}
+
+	if err := m.verifyMessage(&nats.Msg{Subject: "heartbeat.worker"}, "heartbeat.worker"); !errors.Is(err, errNoTrustRule) {
+		t.Fatalf("expected errNoTrustRule, got %v", err)
+	}
+}
+
+func TestHandleMessageBindsPayloadSubject(t *testing.T) {
+	kp, _ := nkeys.CreateUser()
+	seed, _ := kp.Seed()
+	signer, err := heartbeat.NewSigner(seed)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	m := New(nil, nil, Config{
+		Prefix:           "heartbeat",
+		RejectUnverified: true,
+		TrustRules:       []TrustRule{{Pattern: "heartbeat.api", Keys: []string{signer.PublicKey()}}},
+	})
+	ctx := context.Background()
+	now := time.Now()
+	tracking := func(subject string) bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		_, ok := m.state[subject]
+		return ok
+	}
+	deliver := func(natsSubject, subject string) {
+		data, err := heartbeat.Message{Subject: subject, GeneratedAt: now, Interval: time.Minute}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: natsSubject, Data: data, Header: nats.Header{}}, now)
+	}
+
+	// unsigned beats for the protected subject, sent from a subject no rule
+	// covers, in both the full and the prefix-relative form
+	deliver("heartbeat.other", "heartbeat.api")
+	deliver("heartbeat.other", "api")
+	if tracking("heartbeat.api") || tracking("api") {
+		t.Fatalf("expected beats published on another subject to be dropped")
+	}
+	if got := m.counters.subjectMismatches.Load(); got != 2 {
+		t.Fatalf("expected 2 subject mismatches, got %d", got)
+	}
+
+	// on the right subject the trust rule applies and rejects them
+	deliver("heartbeat.api", "heartbeat.api")
+	deliver("heartbeat.api", "api")
+	if tracking("heartbeat.api") || tracking("api") {
+		t.Fatalf("expected unsigned beats for a protected subject to be rejected")
+	}
+	if got := m.counters.unsigned.Load(); got != 2 {
+		t.Fatalf("expected 2 unsigned beats, got %d", got)
+	}
+
+	deliver("heartbeat.other", "other")
+	if !tracking("other") {
+		t.Fatalf("expected a beat on its own subject to be tracked")
+	}
+}
@@ -0,0 +1,29 @@
+package monitor
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed ui/index.html
+var uiFiles embed.FS
+
+// uiHandler serves the self-contained HTML dashboard. It polls the JSON
+// status endpoint itself, so it needs no external assets.
+func (m *Monitor) uiHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ui" && r.URL.Path != "/ui/" {
+			http.NotFound(w, r)
+			return
+		}
+		page, err := uiFiles.ReadFile("ui/index.html")
+		if err != nil {
+			m.logger.Error("dashboard missing from build", "err", err)
+			http.Error(w, "dashboard unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(page)
+	})
+}
@@ -0,0 +1,31 @@
+package monitor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUIHandlerServesDashboard(t *testing.T) {
+	m := New(nil, nil, Config{})
+	for _, path := range []string{"/ui", "/ui/"} {
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("%s: unexpected content type %q", path, ct)
+		}
+		if !strings.Contains(rec.Body.String(), "<title>nats-heartbeat</title>") {
+			t.Fatalf("%s: dashboard not served", path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/other", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown ui path, got %d", rec.Code)
+	}
+}
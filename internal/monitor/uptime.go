@@ -0,0 +1,269 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+// defaultUptimeWindows are the trailing windows reported in the status API.
+var defaultUptimeWindows = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
+
+// availability records when a subject was down. A subject counts as down
+// from the moment its heartbeat became overdue until it resumed or expired.
+type availability struct {
+	since   time.Time // first observation; nothing is known before it
+	outages []outage  // oldest first
+}
+
+type outage struct {
+	start time.Time
+	end   time.Time // zero while ongoing
+}
+
+// trackAvailability starts tracking subject at t if it is not tracked yet.
+// Callers must hold m.mu.
+func (m *Monitor) trackAvailability(subject string, t time.Time) *availability {
+	a, ok := m.uptime[subject]
+	if !ok {
+		a = &availability{since: t}
+		m.uptime[subject] = a
+	}
+	return a
+}
+
+// markDown opens an outage starting at t. Callers must hold m.mu.
+func (m *Monitor) markDown(subject string, t time.Time) {
+	a := m.trackAvailability(subject, t)
+	if n := len(a.outages); n > 0 && a.outages[n-1].end.IsZero() {
+		return
+	}
+	a.outages = append(a.outages, outage{start: t})
+}
+
+// markUp closes the ongoing outage, if any, at t. Callers must hold m.mu.
+func (m *Monitor) markUp(subject string, t time.Time) {
+	a, ok := m.uptime[subject]
+	if !ok {
+		return
+	}
+	if n := len(a.outages); n > 0 && a.outages[n-1].end.IsZero() {
+		if t.Before(a.outages[n-1].start) {
+			t = a.outages[n-1].start
+		}
+		a.outages[n-1].end = t
+	}
+}
+
+// pruneAvailability drops outages that ended before the longest uptime
+// window and forgets subjects that are no longer tracked and have nothing
+// left to report. Callers must hold m.mu.
+func (m *Monitor) pruneAvailability(now time.Time) {
+	cutoff := now.Add(-m.uptimeRetention())
+	for subject, a := range m.uptime {
+		keep := a.outages[:0]
+		for _, o := range a.outages {
+			if o.end.IsZero() || o.end.After(cutoff) {
+				keep = append(keep, o)
+			}
+		}
+		a.outages = keep
+		if _, live := m.state[subject]; !live && len(a.outages) == 0 {
+			delete(m.uptime, subject)
+		}
+	}
+}
+
+func (m *Monitor) uptimeRetention() time.Duration {
+	var longest time.Duration
+	for _, w := range m.config().UptimeWindows {
+		if w > longest {
+			longest = w
+		}
+	}
+	return longest
+}
+
+// stats summarises availability over [from, to]; ongoing outages count up
+// to now. ok is false when the monitor has no data for the range.
+func (a *availability) stats(from, to, now time.Time) (statusapi.Uptime, bool) {
+	if a.since.After(from) {
+		from = a.since
+	}
+	observed := to.Sub(from)
+	if observed <= 0 {
+		return statusapi.Uptime{}, false
+	}
+
+	var down time.Duration
+	outages := 0
+	for _, o := range a.outages {
+		end := o.end
+		if end.IsZero() {
+			end = now
+		}
+		start := o.start
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			down += end.Sub(start)
+			outages++
+		}
+	}
+	return statusapi.Uptime{
+		UptimePercent: 100 * float64(observed-down) / float64(observed),
+		Downtime:      down.Round(time.Second).String(),
+		Outages:       outages,
+		Observed:      observed.Round(time.Second).String(),
+	}, true
+}
+
+// uptimeWindows reports a subject's availability over the configured
+// trailing windows. Callers must hold m.mu.
+func (m *Monitor) uptimeWindows(subject string, now time.Time) []statusapi.UptimeWindow {
+	a, ok := m.uptime[subject]
+	if !ok {
+		return nil
+	}
+	var out []statusapi.UptimeWindow
+	for _, w := range m.config().UptimeWindows {
+		if stats, ok := a.stats(now.Add(-w), now, now); ok {
+			out = append(out, statusapi.UptimeWindow{Window: formatWindow(w), Uptime: stats})
+		}
+	}
+	return out
+}
+
+// report summarises availability of every known subject over [from, to].
+func (m *Monitor) report(from, to, now time.Time) statusapi.Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := statusapi.Report{Version: statusapi.Version, From: from, To: to, Subjects: []statusapi.SubjectReport{}}
+	for subject, a := range m.uptime {
+		stats, ok := a.stats(from, to, now)
+		if !ok {
+			continue
+		}
+		r := statusapi.SubjectReport{Subject: subject, Uptime: stats}
+		if s, live := m.state[subject]; live {
+			r.Description = s.description
+		}
+		resp.Subjects = append(resp.Subjects, r)
+	}
+	sort.Slice(resp.Subjects, func(i, j int) bool {
+		return resp.Subjects[i].Subject < resp.Subjects[j].Subject
+	})
+	return resp
+}
+
+// restoreAvailability rebuilds outages from restored history entries so
+// uptime survives restarts as far back as the history stream reaches.
+// Callers must hold m.mu.
+func (m *Monitor) restoreAvailability(subject string, entries []statusapi.HistoryEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	a := m.trackAvailability(subject, entries[0].At)
+	if entries[0].At.Before(a.since) {
+		a.since = entries[0].At
+	}
+	for _, e := range entries {
+		switch e.Kind {
+		case eventAlert:
+			m.markDown(subject, e.At)
+		case eventResolved, eventExpired:
+			m.markUp(subject, e.At)
+		}
+	}
+}
+
+func (m *Monitor) reportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := m.clock.Now()
+		from, to, err := parseReportRange(r.URL.Query(), now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.writeJSON(w, http.StatusOK, m.report(from, to, now))
+	})
+}
+
+// parseReportRange reads either "window" (a trailing duration such as 30d)
+// or "from"/"to" (RFC 3339; "to" defaults to now) from a query string.
+func parseReportRange(q url.Values, now time.Time) (time.Time, time.Time, error) {
+	if v := q.Get("window"); v != "" {
+		if q.Get("from") != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("use either window or from/to")
+		}
+		window, err := ParseWindow(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return now.Add(-window), now, nil
+	}
+
+	to := now
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		to = t
+	}
+	from := to.Add(-24 * time.Hour)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// ParseWindow parses a duration that may also use a "d" (day) suffix, e.g.
+// "7d" or "36h".
+func ParseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err != nil || n <= 0 || fmt.Sprint(n) != days {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	return d, nil
+}
+
+func formatWindows(windows []time.Duration) string {
+	out := make([]string, len(windows))
+	for i, w := range windows {
+		out[i] = formatWindow(w)
+	}
+	return strings.Join(out, ",")
+}
+
+// formatWindow renders whole days as "7d" and anything else as a duration.
+func formatWindow(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func TestAvailabilityStats(t *testing.T) {
+	now := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	a := &availability{
+		since: now.Add(-48 * time.Hour),
+		outages: []outage{
+			{start: now.Add(-30 * time.Hour), end: now.Add(-29 * time.Hour)},
+			{start: now.Add(-2 * time.Hour), end: now.Add(-90 * time.Minute)},
+			{start: now.Add(-6 * time.Minute)},
+		},
+	}
+
+	day, ok := a.stats(now.Add(-24*time.Hour), now, now)
+	if !ok {
+		t.Fatalf("expected stats for the last day")
+	}
+	if day.Outages != 2 || day.Downtime != "36m0s" {
+		t.Fatalf("unexpected day stats: %+v", day)
+	}
+	if want := 100 * float64(24*60-36) / float64(24*60); day.UptimePercent != want {
+		t.Fatalf("expected uptime %f, got %f", want, day.UptimePercent)
+	}
+
+	// only two days of data exist, so a week is measured over those
+	week, _ := a.stats(now.Add(-7*24*time.Hour), now, now)
+	if week.Observed != "48h0m0s" || week.Outages != 3 || week.Downtime != "1h36m0s" {
+		t.Fatalf("unexpected week stats: %+v", week)
+	}
+
+	if _, ok := a.stats(now.Add(-72*time.Hour), now.Add(-60*time.Hour), now); ok {
+		t.Fatalf("expected no stats before the subject was first seen")
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	cases := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range cases {
+		got, err := ParseWindow(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseWindow(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "d", "-1d", "1.5d", "0s", "soon"} {
+		if _, err := ParseWindow(in); err == nil {
+			t.Fatalf("ParseWindow(%q) should fail", in)
+		}
+	}
+}
+
+func TestUptimeTracksAlertTransitions(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat", UptimeWindows: []time.Duration{time.Hour}})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	beat := func(at time.Time) {
+		data, err := heartbeat.Message{Subject: "svc", GeneratedAt: at, Interval: 10 * time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.svc", Data: data}, at)
+	}
+	beat(start)
+	m.clock = fixedClock(start.Add(time.Minute))
+	m.scan(ctx)
+	beat(start.Add(2 * time.Minute))
+
+	now := start.Add(time.Hour)
+	m.clock = fixedClock(now)
+	m.mu.Lock()
+	windows := m.uptimeWindows("svc", now)
+	m.mu.Unlock()
+	// down from 10s (one missed interval) until the beat at 2m
+	if len(windows) != 1 || windows[0].Window != "1h0m0s" || windows[0].Downtime != "1m50s" || windows[0].Outages != 1 {
+		t.Fatalf("unexpected uptime: %+v", windows)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?window=30m", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var report statusapi.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if len(report.Subjects) != 1 || report.Subjects[0].UptimePercent != 100 || report.Subjects[0].Outages != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?from=2024-06-01T01:00:00Z&to=2024-06-01T00:00:00Z", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for inverted range, got %d", rec.Code)
+	}
+}
+
+func TestExpiryAndForgetDropUptime(t *testing.T) {
+	m := New(nil, nil, Config{Prefix: "heartbeat", ExpireAfter: time.Hour, UptimeWindows: []time.Duration{24 * time.Hour}})
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, subject := range []string{"heartbeat.expired", "heartbeat.forgotten"} {
+		data, err := heartbeat.Message{Subject: subject, GeneratedAt: start, Interval: 10 * time.Second}.Marshal()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		m.handleMessage(ctx, &nats.Msg{Subject: subject, Data: data}, start)
+	}
+	m.clock = fixedClock(start.Add(time.Minute))
+	m.scan(ctx)
+
+	// keep the second subject alive past the expiry threshold
+	data, _ := heartbeat.Message{Subject: "heartbeat.forgotten", GeneratedAt: start.Add(90 * time.Minute), Interval: 10 * time.Second}.Marshal()
+	m.handleMessage(ctx, &nats.Msg{Subject: "heartbeat.forgotten", Data: data}, start.Add(90*time.Minute))
+	m.clock = fixedClock(start.Add(90 * time.Minute))
+	m.scan(ctx)
+	if err := m.Forget(ctx, "heartbeat.forgotten"); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, subject := range []string{"heartbeat.expired", "heartbeat.forgotten"} {
+		if _, ok := m.state[subject]; ok {
+			t.Errorf("expected %s to be dropped from state", subject)
+		}
+		if _, ok := m.uptime[subject]; ok {
+			t.Errorf("expected %s to be dropped from uptime", subject)
+		}
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                   { return time.Time(c) }
+func (c fixedClock) NewTicker(d time.Duration) Ticker { return realClock{}.NewTicker(d) }
@@ -0,0 +1,141 @@
+package natsconn
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Hooks receive connection lifecycle events in addition to the built-in
+// logging. Any hook may be nil.
+type Hooks struct {
+	OnConnect    func(*nats.Conn)
+	OnDisconnect func(*nats.Conn, error)
+	OnReconnect  func(*nats.Conn)
+	OnClosed     func(*nats.Conn)
+}
+
+const maxBackoff = 30 * time.Second
+
+// Connect dials NATS with opts, retrying connection failures with
+// exponential backoff until it succeeds or ctx is cancelled. Other errors,
+// such as unreadable credentials or TLS files, are returned immediately.
+// Once created the connection reconnects forever, and initial connects keep
+// retrying in the background, so callers may start before the server is
+// reachable.
+func Connect(ctx context.Context, logger *slog.Logger, opts Options, hooks Hooks) (*nats.Conn, error) {
+	extra, err := opts.NATSOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	natsOpts := append([]nats.Option{
+		nats.MaxReconnects(-1), // never give up once connected
+		nats.ReconnectWait(2 * time.Second),
+		nats.RetryOnFailedConnect(true), // keep trying initial connects with the same backoff policy
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			if err == nil {
+				return
+			}
+			if sub != nil {
+				logger.Warn("nats async error", "err", err, "subject", sub.Subject)
+				return
+			}
+			logger.Warn("nats async error", "err", err)
+		}),
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			logger.Info("nats connected", "url", nc.ConnectedUrlRedacted())
+			if hooks.OnConnect != nil {
+				hooks.OnConnect(nc)
+			}
+		}),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if err != nil {
+				logger.Warn("nats disconnected", "err", err)
+			} else {
+				logger.Warn("nats disconnected")
+			}
+			if hooks.OnDisconnect != nil {
+				hooks.OnDisconnect(nc, err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info("nats reconnected", "url", nc.ConnectedUrlRedacted())
+			if hooks.OnReconnect != nil {
+				hooks.OnReconnect(nc)
+			}
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			if nc != nil && nc.LastError() != nil {
+				logger.Error("nats connection closed", "err", nc.LastError())
+			} else {
+				logger.Error("nats connection closed")
+			}
+			if hooks.OnClosed != nil {
+				hooks.OnClosed(nc)
+			}
+		}),
+	}, extra...)
+	if opts.Name != "" {
+		natsOpts = append(natsOpts, nats.Name(opts.Name))
+	}
+
+	backoff := time.Second
+	for {
+		nc, err := nats.Connect(opts.URL, natsOpts...)
+		if err == nil {
+			if !nc.IsConnected() {
+				logger.Warn("nats not reachable yet; retrying in background", "url", opts.URL)
+			}
+			return nc, nil
+		}
+		if !retryable(err) {
+			return nil, err
+		}
+
+		logger.Error("connect to nats failed", "err", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff < maxBackoff {
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// retryable reports whether err from nats.Connect is a connection failure
+// worth retrying; anything else fails the same way on every attempt.
+func retryable(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, nats.ErrNoServers) || errors.Is(err, nats.ErrTimeout) || errors.As(err, &opErr)
+}
+
+// WaitConnected blocks until nc is connected or ctx is done.
+func WaitConnected(ctx context.Context, nc *nats.Conn) error {
+	if nc.IsConnected() {
+		return nil
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if nc.IsConnected() {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package natsconn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestConnectToleratesUnreachableServer(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	nc, err := Connect(context.Background(), logger, Options{URL: "nats://127.0.0.1:1"}, Hooks{})
+	if err != nil {
+		t.Fatalf("expected connect to succeed in background retry mode, got %v", err)
+	}
+	defer nc.Close()
+
+	if nc.IsConnected() {
+		t.Fatalf("expected connection to be pending")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := WaitConnected(ctx, nc); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestConnectRejectsInvalidOptions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := Connect(context.Background(), logger, Options{User: "u", Token: "t"}, Hooks{}); err == nil {
+		t.Fatalf("expected invalid options to fail")
+	}
+}
+
+func TestConnectFailsFastOnBadFiles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cases := []struct {
+		name string
+		opts Options
+	}{
+		{"creds", Options{URL: "nats://127.0.0.1:1", CredsFile: "/nonexistent/user.creds"}},
+		{"tls ca", Options{URL: "nats://127.0.0.1:1", TLSCA: "/nonexistent/ca.pem"}},
+		{"tls cert", Options{URL: "nats://127.0.0.1:1", TLSCert: "/nonexistent/cert.pem", TLSKey: "/nonexistent/key.pem"}},
+	}
+	for _, tc := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := Connect(ctx, logger, tc.opts, Hooks{})
+		cancel()
+		if err == nil || errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected connect to fail immediately, got %v", tc.name, err)
+		}
+	}
+}
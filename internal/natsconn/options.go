@@ -0,0 +1,113 @@
+// Package natsconn holds the NATS connection settings and resilient connect
+// logic shared by the commands.
+package natsconn
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Options configures authentication and TLS for a NATS connection.
+type Options struct {
+	URL string
+	// Name identifies the client to the server; it is not bound to a flag.
+	Name string
+
+	CredsFile    string
+	NKeySeedFile string
+	User         string
+	Password     string
+	Token        string
+
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSServerName string
+}
+
+// RegisterFlags binds the connection flags (with environment mirrors) to fs.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.URL, "nats-url", envDefault("NATS_URL", nats.DefaultURL), "NATS server URL")
+	fs.StringVar(&o.CredsFile, "nats-creds", envDefault("NATS_CREDS", ""), "NATS user credentials file")
+	fs.StringVar(&o.NKeySeedFile, "nats-nkey", envDefault("NATS_NKEY", ""), "NATS nkey seed file")
+	fs.StringVar(&o.User, "nats-user", envDefault("NATS_USER", ""), "NATS username")
+	fs.StringVar(&o.Password, "nats-password", envDefault("NATS_PASSWORD", ""), "NATS password")
+	fs.StringVar(&o.Token, "nats-token", envDefault("NATS_TOKEN", ""), "NATS authentication token")
+	fs.StringVar(&o.TLSCA, "nats-tls-ca", envDefault("NATS_TLS_CA", ""), "CA certificate file used to verify the NATS server")
+	fs.StringVar(&o.TLSCert, "nats-tls-cert", envDefault("NATS_TLS_CERT", ""), "Client certificate file for NATS TLS")
+	fs.StringVar(&o.TLSKey, "nats-tls-key", envDefault("NATS_TLS_KEY", ""), "Client key file for NATS TLS")
+	fs.StringVar(&o.TLSServerName, "nats-tls-server-name", envDefault("NATS_TLS_SERVER_NAME", ""), "Expected NATS server name for TLS verification")
+}
+
+// Validate rejects conflicting or incomplete settings.
+func (o Options) Validate() error {
+	methods := 0
+	for _, set := range []bool{o.CredsFile != "", o.NKeySeedFile != "", o.User != "", o.Token != ""} {
+		if set {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return errors.New("only one of credentials file, nkey seed, user/password or token may be set")
+	}
+	if o.Password != "" && o.User == "" {
+		return errors.New("nats password requires a user")
+	}
+	if (o.TLSCert == "") != (o.TLSKey == "") {
+		return errors.New("nats TLS client certificate and key must be set together")
+	}
+	return nil
+}
+
+// NATSOptions translates the settings into nats.Connect options.
+func (o Options) NATSOptions() ([]nats.Option, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	var opts []nats.Option
+	switch {
+	case o.CredsFile != "":
+		// nats only reads the file while connecting, where a bad path
+		// would be retried forever
+		if _, err := os.Stat(o.CredsFile); err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.UserCredentials(o.CredsFile))
+	case o.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(o.NKeySeedFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	case o.User != "":
+		opts = append(opts, nats.UserInfo(o.User, o.Password))
+	case o.Token != "":
+		opts = append(opts, nats.Token(o.Token))
+	}
+
+	if o.TLSServerName != "" {
+		opts = append(opts, nats.Secure(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: o.TLSServerName,
+		}))
+	}
+	if o.TLSCA != "" {
+		opts = append(opts, nats.RootCAs(o.TLSCA))
+	}
+	if o.TLSCert != "" {
+		opts = append(opts, nats.ClientCert(o.TLSCert, o.TLSKey))
+	}
+	return opts, nil
+}
+
+func envDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
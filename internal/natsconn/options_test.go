@@ -0,0 +1,47 @@
+package natsconn
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestValidateRejectsConflictingAuth(t *testing.T) {
+	cases := []Options{
+		{CredsFile: "a.creds", Token: "secret"},
+		{User: "u", NKeySeedFile: "seed.nk"},
+		{Password: "p"},
+		{TLSCert: "cert.pem"},
+	}
+	for _, opts := range cases {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("expected validation error for %+v", opts)
+		}
+	}
+
+	if err := (Options{User: "u", Password: "p", TLSCert: "c", TLSKey: "k"}).Validate(); err != nil {
+		t.Fatalf("expected valid options, got %v", err)
+	}
+}
+
+func TestRegisterFlagsUsesEnvironment(t *testing.T) {
+	t.Setenv("NATS_URL", "nats://example:4222")
+	t.Setenv("NATS_TOKEN", "secret")
+
+	var opts Options
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-nats-tls-server-name", "nats.internal"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if opts.URL != "nats://example:4222" || opts.Token != "secret" || opts.TLSServerName != "nats.internal" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	natsOpts, err := opts.NATSOptions()
+	if err != nil {
+		t.Fatalf("nats options: %v", err)
+	}
+	if len(natsOpts) != 2 {
+		t.Fatalf("expected token and TLS options, got %d", len(natsOpts))
+	}
+}
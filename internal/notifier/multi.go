@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+)
+
+// Multi sends every notification to each notifier in order and returns
+// their combined errors; one failing notifier does not stop the others.
+type Multi []Notifier
+
+func (m Multi) Alert(ctx context.Context, evt Event) error {
+	return m.each(func(n Notifier) error { return n.Alert(ctx, evt) })
+}
+
+func (m Multi) Resolved(ctx context.Context, evt Event) error {
+	return m.each(func(n Notifier) error { return n.Resolved(ctx, evt) })
+}
+
+func (m Multi) Expired(ctx context.Context, evt Event) error {
+	return m.each(func(n Notifier) error { return n.Expired(ctx, evt) })
+}
+
+func (m Multi) Notice(ctx context.Context, evt Event) error {
+	return m.each(func(n Notifier) error { return n.Notice(ctx, evt) })
+}
+
+func (m Multi) each(fn func(Notifier) error) error {
+	var errs []error
+	for _, n := range m {
+		if err := fn(n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,136 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Event kinds used in NATS event subjects and payloads.
+const (
+	KindAlert    = "alert"
+	KindResolved = "resolved"
+	KindExpired  = "expired"
+	KindNotice   = "notice"
+)
+
+// NATS publishes notifications as JSON on "<Subject>.<kind>.<heartbeat
+// subject>" (e.g. heartbeat.events.alert.api) so other systems can act on
+// them. When Stream is set, events are published through JetStream into
+// that stream, which is created on first use if missing.
+type NATS struct {
+	Conn    *nats.Conn
+	Subject string
+	Stream  string
+
+	mu          sync.Mutex
+	streamReady bool
+}
+
+// EventMessage is the payload published by NATS.
+type EventMessage struct {
+	Kind          string    `json:"kind"`
+	Subject       string    `json:"subject"`
+	Description   string    `json:"description,omitempty"`
+	Host          string    `json:"host,omitempty"`
+	LastSeen      time.Time `json:"last_seen"`
+	Interval      string    `json:"interval,omitempty"`
+	AllowedWindow string    `json:"allowed_window,omitempty"`
+	MissCount     int       `json:"miss_count,omitempty"`
+	MissFor       string    `json:"miss_for,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	GeneratedAt   time.Time `json:"generated_at,omitempty"`
+	BootID        string    `json:"boot_id,omitempty"`
+	Seq           uint64    `json:"seq,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+func (n *NATS) Alert(ctx context.Context, evt Event) error {
+	return n.publish(ctx, KindAlert, evt)
+}
+
+func (n *NATS) Resolved(ctx context.Context, evt Event) error {
+	return n.publish(ctx, KindResolved, evt)
+}
+
+func (n *NATS) Expired(ctx context.Context, evt Event) error {
+	return n.publish(ctx, KindExpired, evt)
+}
+
+func (n *NATS) Notice(ctx context.Context, evt Event) error {
+	return n.publish(ctx, KindNotice, evt)
+}
+
+func (n *NATS) publish(ctx context.Context, kind string, evt Event) error {
+	if n.Conn == nil || n.Subject == "" {
+		return errors.New("nats event publisher needs a connection and subject")
+	}
+	msg := EventMessage{
+		Kind:        kind,
+		Subject:     evt.Subject,
+		Description: evt.Description,
+		Host:        evt.Host,
+		LastSeen:    evt.LastSeen,
+		MissCount:   evt.MissCount,
+		Reason:      evt.Reason,
+		GeneratedAt: evt.GeneratedAt,
+		BootID:      evt.BootID,
+		Seq:         evt.Sequence,
+		At:          time.Now().UTC(),
+	}
+	if evt.Interval > 0 {
+		msg.Interval = evt.Interval.String()
+	}
+	if evt.AllowedWindow > 0 {
+		msg.AllowedWindow = evt.AllowedWindow.String()
+	}
+	if evt.MissFor > 0 {
+		msg.MissFor = evt.MissFor.String()
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("%s.%s.%s", n.Subject, kind, evt.Subject)
+
+	if n.Stream == "" {
+		return n.Conn.Publish(subject, data)
+	}
+	js, err := n.Conn.JetStream()
+	if err != nil {
+		return err
+	}
+	if err := n.ensureStream(js); err != nil {
+		return fmt.Errorf("event stream %s: %w", n.Stream, err)
+	}
+	_, err = js.Publish(subject, data, nats.Context(ctx))
+	return err
+}
+
+// ensureStream creates the event stream if it does not exist yet. Failures
+// are retried on the next event.
+func (n *NATS) ensureStream(js nats.JetStreamContext) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.streamReady {
+		return nil
+	}
+	_, err := js.StreamInfo(n.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     n.Stream,
+			Subjects: []string{n.Subject + ".>"},
+			Storage:  nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	n.streamReady = true
+	return nil
+}
@@ -14,12 +14,22 @@ type Event struct {
 	Interval    time.Duration
 	MissCount   int
 	MissFor     time.Duration
+	Reason      string // set for notices
+
+	// State of the subject when the notification was raised.
+	AllowedWindow time.Duration
+	GeneratedAt   time.Time
+	BootID        string
+	Sequence      uint64
 }
 
-// Notifier sends alerts and resolutions to downstream channels.
+// Notifier sends alerts, resolutions, expiries and notices (e.g. clock
+// skew) to downstream channels.
 type Notifier interface {
 	Alert(ctx context.Context, evt Event) error
 	Resolved(ctx context.Context, evt Event) error
+	Expired(ctx context.Context, evt Event) error
+	Notice(ctx context.Context, evt Event) error
 }
 
 // Nop is a no-op notifier useful in tests.
@@ -27,3 +37,5 @@ type Nop struct{}
 
 func (Nop) Alert(_ context.Context, _ Event) error    { return nil }
 func (Nop) Resolved(_ context.Context, _ Event) error { return nil }
+func (Nop) Expired(_ context.Context, _ Event) error  { return nil }
+func (Nop) Notice(_ context.Context, _ Event) error   { return nil }
@@ -33,6 +33,14 @@ func (p Pushover) Resolved(ctx context.Context, evt Event) error {
 	return p.send(ctx, "Heartbeat resolved", fmt.Sprintf("%s: recovered at %s", evt.Description, evt.LastSeen.UTC().Format(time.RFC3339)))
 }
 
+func (p Pushover) Expired(ctx context.Context, evt Event) error {
+	return p.send(ctx, "Heartbeat expired", fmt.Sprintf("%s: no heartbeat since %s, no longer monitored", evt.Description, evt.LastSeen.UTC().Format(time.RFC3339)))
+}
+
+func (p Pushover) Notice(ctx context.Context, evt Event) error {
+	return p.send(ctx, "Heartbeat notice", fmt.Sprintf("%s: %s", evt.Description, evt.Reason))
+}
+
 func (p Pushover) send(ctx context.Context, title, message string) error {
 	if p.Token == "" || p.User == "" {
 		return errors.New("pushover token and user are required")
@@ -15,6 +15,8 @@ type Message struct {
 	GracePeriod *time.Duration `json:"grace_period,omitempty"` // max time to miss beats
 	Host        string         `json:"host,omitempty"`         // origin host/container
 	Description string         `json:"description,omitempty"`
+	Sequence    uint64         `json:"seq,omitempty"`     // per-subject counter, increments each publish
+	BootID      string         `json:"boot_id,omitempty"` // identifies the publishing process instance
 }
 
 // Marshal renders the message as JSON for transport.
@@ -2,9 +2,12 @@ package heartbeat
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -14,15 +17,44 @@ import (
 type Publisher struct {
 	nc     *nats.Conn
 	prefix string
+	signer *Signer
+
+	mu  sync.Mutex
+	seq map[string]uint64
 }
 
 var hostname = os.Hostname
 
-func NewPublisher(nc *nats.Conn, prefix string) *Publisher {
-	return &Publisher{
+// bootID identifies this process so monitors can tell restarts apart from
+// continuous uptime.
+var bootID = newBootID()
+
+// PublisherOption customizes a Publisher.
+type PublisherOption func(*Publisher)
+
+// WithSigner signs every published heartbeat with s.
+func WithSigner(s *Signer) PublisherOption {
+	return func(p *Publisher) {
+		p.signer = s
+	}
+}
+
+func NewPublisher(nc *nats.Conn, prefix string, opts ...PublisherOption) *Publisher {
+	p := &Publisher{
 		nc:     nc,
 		prefix: strings.TrimSuffix(prefix, "."),
+		seq:    make(map[string]uint64),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
+}
+
+// BootID returns the instance identifier attached to heartbeats published by
+// this process.
+func BootID() string {
+	return bootID
 }
 
 // Publish sends a heartbeat to NATS using the configured prefix.
@@ -34,15 +66,22 @@ func (p *Publisher) Publish(ctx context.Context, msg Message) error {
 	if err := msg.Validate(); err != nil {
 		return err
 	}
+	msg = p.applySequence(msg)
 	payload, err := msg.Marshal()
 	if err != nil {
 		return err
 	}
+	headers := cloneHeaders(ctx)
+	if p.signer != nil {
+		if err := p.signer.Sign(headers, payload); err != nil {
+			return fmt.Errorf("sign heartbeat: %w", err)
+		}
+	}
 	subject := p.fullSubject(msg.Subject)
 	return p.nc.PublishMsg(&nats.Msg{
 		Subject: subject,
 		Data:    payload,
-		Header:  cloneHeaders(ctx),
+		Header:  headers,
 	})
 }
 
@@ -53,6 +92,30 @@ func (p *Publisher) fullSubject(s string) string {
 	return fmt.Sprintf("%s.%s", p.prefix, s)
 }
 
+// applySequence stamps the boot ID and the next per-subject sequence number
+// unless the caller already provided them.
+func (p *Publisher) applySequence(msg Message) Message {
+	if msg.BootID == "" {
+		msg.BootID = bootID
+	}
+	if msg.Sequence != 0 {
+		return msg
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seq[msg.Subject]++
+	msg.Sequence = p.seq[msg.Subject]
+	return msg
+}
+
+func newBootID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
 func applyHostDefault(msg Message) Message {
 	if msg.Host != "" {
 		return msg
@@ -38,3 +38,30 @@ func TestApplyHostDefaultIgnoresHostnameErrors(t *testing.T) {
 		t.Fatalf("expected empty host when lookup fails, got %q", got.Host)
 	}
 }
+
+func TestApplySequenceIncrementsPerSubject(t *testing.T) {
+	p := NewPublisher(nil, "")
+
+	first := p.applySequence(Message{Subject: "a"})
+	second := p.applySequence(Message{Subject: "a"})
+	other := p.applySequence(Message{Subject: "b"})
+
+	if first.Sequence != 1 || second.Sequence != 2 {
+		t.Fatalf("expected sequences 1 and 2, got %d and %d", first.Sequence, second.Sequence)
+	}
+	if other.Sequence != 1 {
+		t.Fatalf("expected independent sequence for subject b, got %d", other.Sequence)
+	}
+	if first.BootID == "" || first.BootID != BootID() {
+		t.Fatalf("expected boot id %q, got %q", BootID(), first.BootID)
+	}
+}
+
+func TestApplySequenceKeepsProvidedValues(t *testing.T) {
+	p := NewPublisher(nil, "")
+
+	got := p.applySequence(Message{Subject: "a", Sequence: 42, BootID: "custom"})
+	if got.Sequence != 42 || got.BootID != "custom" {
+		t.Fatalf("expected provided sequence and boot id to be kept, got %d/%q", got.Sequence, got.BootID)
+	}
+}
@@ -0,0 +1,90 @@
+package heartbeat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// Header names carrying heartbeat signatures.
+const (
+	SignatureHeader = "Heartbeat-Signature"
+	KeyHeader       = "Heartbeat-Key"
+)
+
+// ErrUnsigned is returned by Verify when a message carries no signature.
+var ErrUnsigned = errors.New("heartbeat is not signed")
+
+// Signer signs heartbeat payloads with an nkey (ed25519) seed.
+type Signer struct {
+	kp     nkeys.KeyPair
+	public string
+}
+
+// NewSigner builds a signer from an encoded nkey seed (e.g. "SU...").
+func NewSigner(seed []byte) (*Signer, error) {
+	kp, err := nkeys.FromSeed(bytes.TrimSpace(seed))
+	if err != nil {
+		return nil, fmt.Errorf("parse nkey seed: %w", err)
+	}
+	public, err := kp.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("derive public key: %w", err)
+	}
+	return &Signer{kp: kp, public: public}, nil
+}
+
+// LoadSigner reads an nkey seed from path.
+func LoadSigner(path string) (*Signer, error) {
+	seed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(seed)
+}
+
+// PublicKey returns the encoded public nkey monitors should trust.
+func (s *Signer) PublicKey() string {
+	return s.public
+}
+
+// Sign adds signature and public key headers for payload.
+func (s *Signer) Sign(h nats.Header, payload []byte) error {
+	sig, err := s.kp.Sign(payload)
+	if err != nil {
+		return err
+	}
+	h.Set(SignatureHeader, base64.RawURLEncoding.EncodeToString(sig))
+	h.Set(KeyHeader, s.public)
+	return nil
+}
+
+// Verify checks the signature headers against payload and returns the
+// signing public key. Callers decide whether that key is trusted.
+func Verify(h nats.Header, payload []byte) (string, error) {
+	encoded := h.Get(SignatureHeader)
+	public := h.Get(KeyHeader)
+	if encoded == "" && public == "" {
+		return "", ErrUnsigned
+	}
+	if encoded == "" || public == "" {
+		return public, errors.New("incomplete signature headers")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return public, fmt.Errorf("decode signature: %w", err)
+	}
+	kp, err := nkeys.FromPublicKey(public)
+	if err != nil {
+		return public, fmt.Errorf("parse public key: %w", err)
+	}
+	if err := kp.Verify(payload, sig); err != nil {
+		return public, err
+	}
+	return public, nil
+}
@@ -0,0 +1,53 @@
+package heartbeat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	kp, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatalf("create nkey: %v", err)
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	signer, err := NewSigner(seed)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	return signer
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	payload := []byte(`{"subject":"svc"}`)
+	headers := nats.Header{}
+	if err := signer.Sign(headers, payload); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	key, err := Verify(headers, payload)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if key != signer.PublicKey() {
+		t.Fatalf("expected key %s, got %s", signer.PublicKey(), key)
+	}
+
+	if _, err := Verify(headers, []byte(`{"subject":"other"}`)); err == nil {
+		t.Fatalf("expected tampered payload to fail verification")
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	if _, err := Verify(nats.Header{}, []byte("{}")); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("expected ErrUnsigned, got %v", err)
+	}
+}
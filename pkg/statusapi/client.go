@@ -0,0 +1,344 @@
+package statusapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is wrapped by errors for subjects the monitor is not
+// tracking.
+var ErrNotFound = errors.New("not found")
+
+// ErrNotAlerting is wrapped by errors for acknowledging a subject that has
+// no active alert.
+var ErrNotAlerting = errors.New("not alerting")
+
+// ErrUnauthorized is wrapped by errors for requests without valid
+// credentials.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrForbidden is wrapped by errors for requests whose credentials lack the
+// role the operation needs, e.g. forgetting a subject with a read-only
+// token.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrUnsupportedVersion is wrapped by errors for documents newer than
+// Version.
+var ErrUnsupportedVersion = errors.New("unsupported status API version")
+
+// Error is a non-2xx response from the monitor.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("unexpected status %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusConflict:
+		return ErrNotAlerting
+	}
+	return nil
+}
+
+// Client queries one monitor's HTTP status API.
+type Client struct {
+	base *url.URL
+	hc   *http.Client
+	auth func(*http.Request)
+}
+
+// ClientOption customizes a Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.hc = hc
+	}
+}
+
+// WithBearerToken authenticates requests with an "Authorization: Bearer"
+// header.
+func WithBearerToken(token string) ClientOption {
+	return func(c *Client) {
+		c.auth = func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// WithBasicAuth authenticates requests with HTTP basic auth.
+func WithBasicAuth(user, password string) ClientOption {
+	return func(c *Client) {
+		c.auth = func(r *http.Request) {
+			r.SetBasicAuth(user, password)
+		}
+	}
+}
+
+// NewClient returns a client for the monitor whose status endpoint is
+// baseURL, e.g. "http://127.0.0.1:8080/". Monitors served under a path
+// prefix are supported.
+func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid monitor url %q", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+		u.RawPath = ""
+	}
+	c := &Client{base: u, hc: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// URL returns the address of the endpoint at path, relative to the status
+// endpoint.
+func (c *Client) URL(path string) string {
+	return c.base.ResolveReference(&url.URL{Path: path}).String()
+}
+
+// Status returns every subject the monitor tracks.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var resp Status
+	if err := c.get(ctx, c.URL(""), &resp); err != nil {
+		return Status{}, err
+	}
+	if err := CheckVersion(resp.Version); err != nil {
+		return Status{}, err
+	}
+	return resp, nil
+}
+
+// Subject returns the status of one subject; the error wraps ErrNotFound
+// when the monitor is not tracking it.
+func (c *Client) Subject(ctx context.Context, subject string) (SubjectStatus, error) {
+	var resp SubjectStatus
+	if err := c.get(ctx, c.subjectURL(subject, ""), &resp); err != nil {
+		return SubjectStatus{}, err
+	}
+	return resp, nil
+}
+
+// History returns a subject's recent beats and notifications, at most limit
+// of them when limit is positive.
+func (c *Client) History(ctx context.Context, subject string, limit int) (History, error) {
+	u := c.subjectURL(subject, "/history")
+	if limit > 0 {
+		u += "?limit=" + strconv.Itoa(limit)
+	}
+	var resp History
+	if err := c.get(ctx, u, &resp); err != nil {
+		return History{}, err
+	}
+	if err := CheckVersion(resp.Version); err != nil {
+		return History{}, err
+	}
+	return resp, nil
+}
+
+// Events opens the monitor's event stream: Server-Sent Events carrying a
+// Status snapshot, Update and Event payloads. The caller closes the stream;
+// it ends when ctx is cancelled.
+func (c *Client) Events(ctx context.Context) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, c.URL("events"))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request events: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, responseError(res)
+	}
+	return res.Body, nil
+}
+
+// ReportQuery selects the range of a Report: a trailing Window (e.g. "24h"
+// or "7d"), or From and optionally To. The zero value reports on the
+// monitor's default window.
+type ReportQuery struct {
+	Window string
+	From   time.Time
+	To     time.Time
+}
+
+// Values encodes q as the report endpoint's query string parameters.
+func (q ReportQuery) Values() url.Values {
+	v := url.Values{}
+	if q.Window != "" {
+		v.Set("window", q.Window)
+	}
+	if !q.From.IsZero() {
+		v.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		v.Set("to", q.To.Format(time.RFC3339))
+	}
+	return v
+}
+
+// Report returns uptime per subject over the range selected by q.
+func (c *Client) Report(ctx context.Context, q ReportQuery) (Report, error) {
+	u := c.URL("report")
+	if v := q.Values(); len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+	var resp Report
+	if err := c.get(ctx, u, &resp); err != nil {
+		return Report{}, err
+	}
+	if err := CheckVersion(resp.Version); err != nil {
+		return Report{}, err
+	}
+	return resp, nil
+}
+
+// Forget asks the monitor to drop a subject until it beats again; the
+// error wraps ErrNotFound when the monitor is not tracking it.
+func (c *Client) Forget(ctx context.Context, subject string) error {
+	_, err := c.admin(ctx, http.MethodDelete, c.subjectURL(subject, ""))
+	return err
+}
+
+// Silence suppresses notifications for a subject for d and returns when the
+// silence ends; a zero d lifts it and returns the zero time. The error
+// wraps ErrNotFound when the monitor is not tracking the subject.
+func (c *Client) Silence(ctx context.Context, subject string, d time.Duration) (time.Time, error) {
+	u := c.subjectURL(subject, "/silence") + "?" + url.Values{"for": {d.String()}}.Encode()
+	resp, err := c.admin(ctx, http.MethodPost, u)
+	if err != nil || resp.SilencedUntil == nil {
+		return time.Time{}, err
+	}
+	return *resp.SilencedUntil, nil
+}
+
+// Ack acknowledges a subject's active alert, stopping repeat notifications
+// until it resolves. The error wraps ErrNotAlerting when the subject has no
+// active alert.
+func (c *Client) Ack(ctx context.Context, subject string) error {
+	_, err := c.admin(ctx, http.MethodPost, c.subjectURL(subject, "/ack"))
+	return err
+}
+
+// Reload asks the monitor to re-read its config file and returns the
+// settings that changed.
+func (c *Client) Reload(ctx context.Context) ([]string, error) {
+	resp, err := c.admin(ctx, http.MethodPost, c.URL("reload"))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Changes, nil
+}
+
+func (c *Client) subjectURL(subject, suffix string) string {
+	return c.base.ResolveReference(&url.URL{
+		Path:    "subjects/" + subject + suffix,
+		RawPath: "subjects/" + url.PathEscape(subject) + suffix,
+	}).String()
+}
+
+func (c *Client) newRequest(ctx context.Context, method, u string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if c.auth != nil {
+		c.auth(req)
+	}
+	return req, nil
+}
+
+func (c *Client) get(ctx context.Context, u string, v interface{}) error {
+	req, err := c.newRequest(ctx, http.MethodGet, u)
+	if err != nil {
+		return err
+	}
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("query monitor: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return responseError(res)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) admin(ctx context.Context, method, u string) (AdminResult, error) {
+	req, err := c.newRequest(ctx, method, u)
+	if err != nil {
+		return AdminResult{}, err
+	}
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return AdminResult{}, fmt.Errorf("request %s: %w", req.URL.Path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return AdminResult{}, responseError(res)
+	}
+	var resp AdminResult
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return AdminResult{}, fmt.Errorf("decode response: %w", err)
+	}
+	if !resp.OK {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// responseError reads the monitor's error message, which is either an
+// AdminResult or plain text.
+func responseError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	msg := strings.TrimSpace(string(body))
+	var admin AdminResult
+	if bytes.HasPrefix(body, []byte("{")) && json.Unmarshal(body, &admin) == nil && admin.Error != "" {
+		msg = admin.Error
+	}
+	return &Error{StatusCode: res.StatusCode, Message: msg}
+}
+
+// CheckVersion returns an error wrapping ErrUnsupportedVersion when a
+// document's version v is newer than Version. Documents from monitors that
+// predate versioning carry 0 and are accepted.
+func CheckVersion(v int) error {
+	if v > Version {
+		return fmt.Errorf("%w: monitor sent version %d, client supports %d", ErrUnsupportedVersion, v, Version)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package statusapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/venkytv/nats-heartbeat/internal/monitor"
+	"github.com/venkytv/nats-heartbeat/internal/monitor/monitortest"
+	"github.com/venkytv/nats-heartbeat/pkg/heartbeat"
+	"github.com/venkytv/nats-heartbeat/pkg/statusapi"
+)
+
+func startClient(t *testing.T) (*monitortest.Harness, *statusapi.Client) {
+	t.Helper()
+	h := monitortest.Start(t, runServer(t), monitor.Config{})
+	srv := httptest.NewServer(h.Monitor.Handler())
+	t.Cleanup(srv.Close)
+	c, err := statusapi.NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return h, c
+}
+
+func TestClientStatusAndSubject(t *testing.T) {
+	h, c := startClient(t)
+	h.Beat(heartbeat.Message{Subject: "db.backup", Interval: time.Minute, Description: "nightly backup"})
+	ctx := context.Background()
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Version != statusapi.Version {
+		t.Fatalf("expected version %d, got %d", statusapi.Version, status.Version)
+	}
+	if len(status.Subjects) != 1 || status.Subjects[0].Subject != "db.backup" {
+		t.Fatalf("unexpected subjects %+v", status.Subjects)
+	}
+
+	subj, err := c.Subject(ctx, "db.backup")
+	if err != nil {
+		t.Fatalf("subject: %v", err)
+	}
+	if subj.Description != "nightly backup" || subj.Interval != "1m0s" {
+		t.Fatalf("unexpected subject %+v", subj)
+	}
+
+	if _, err := c.Subject(ctx, "nope"); !errors.Is(err, statusapi.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown subject, got %v", err)
+	}
+}
+
+func TestClientHistoryAndReport(t *testing.T) {
+	h, c := startClient(t)
+	beat := heartbeat.Message{Subject: "svc", Interval: 10 * time.Second}
+	for i := 0; i < 3; i++ {
+		h.Beat(beat)
+		h.Advance(10 * time.Second)
+	}
+	ctx := context.Background()
+
+	hist, err := c.History(ctx, "svc", 2)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if hist.Subject != "svc" || len(hist.Entries) != 2 {
+		t.Fatalf("expected the last 2 entries for svc, got %+v", hist)
+	}
+
+	report, err := c.Report(ctx, statusapi.ReportQuery{Window: "1h"})
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if len(report.Subjects) != 1 || report.Subjects[0].Subject != "svc" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if got := report.To.Sub(report.From); got != time.Hour {
+		t.Fatalf("expected a 1h range, got %s", got)
+	}
+}
+
+func TestClientForget(t *testing.T) {
+	h, c := startClient(t)
+	h.Beat(heartbeat.Message{Subject: "old", Interval: time.Minute})
+	ctx := context.Background()
+
+	if err := c.Forget(ctx, "old"); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if _, err := c.Subject(ctx, "old"); !errors.Is(err, statusapi.ErrNotFound) {
+		t.Fatalf("expected forgotten subject to be gone, got %v", err)
+	}
+	if err := c.Forget(ctx, "old"); !errors.Is(err, statusapi.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound forgetting twice, got %v", err)
+	}
+}
+
+func TestClientSilenceAndAck(t *testing.T) {
+	h, c := startClient(t)
+	h.Beat(heartbeat.Message{Subject: "svc", Interval: 10 * time.Second})
+	ctx := context.Background()
+
+	until, err := c.Silence(ctx, "svc", time.Hour)
+	if err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	if want := h.Clock.Now().Add(time.Hour); !until.Equal(want) {
+		t.Fatalf("expected silence until %v, got %v", want, until)
+	}
+	if until, err := c.Silence(ctx, "svc", 0); err != nil || !until.IsZero() {
+		t.Fatalf("expected the silence to be lifted, got %v, %v", until, err)
+	}
+	if _, err := c.Silence(ctx, "nope", time.Hour); !errors.Is(err, statusapi.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound silencing an unknown subject, got %v", err)
+	}
+
+	if err := c.Ack(ctx, "svc"); !errors.Is(err, statusapi.ErrNotAlerting) {
+		t.Fatalf("expected ErrNotAlerting for a healthy subject, got %v", err)
+	}
+	h.Advance(time.Minute)
+	h.WaitForAlert("svc", 1)
+	if err := c.Ack(ctx, "svc"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	subj, err := c.Subject(ctx, "svc")
+	if err != nil || !subj.Acknowledged {
+		t.Fatalf("expected svc to be acknowledged, got %+v, %v", subj, err)
+	}
+}
+
+func TestClientRejectsNewerVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version":2,"subjects":[]}`))
+	}))
+	defer srv.Close()
+	c, err := statusapi.NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	if _, err := c.Status(context.Background()); !errors.Is(err, statusapi.ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestClientURLKeepsPathPrefix(t *testing.T) {
+	c, err := statusapi.NewClient("http://monitor.example/heartbeat")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if got, want := c.URL("events"), "http://monitor.example/heartbeat/events"; got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	h := monitortest.Start(t, runServer(t), monitor.Config{StatusAuth: []monitor.Credential{
+		{Role: monitor.RoleRead, Token: "reader"},
+		{Role: monitor.RoleAdmin, User: "ops", Password: "pw"},
+	}})
+	h.Beat(heartbeat.Message{Subject: "svc", Interval: time.Minute})
+	srv := httptest.NewServer(h.Monitor.Handler())
+	defer srv.Close()
+	ctx := context.Background()
+
+	anonymous, _ := statusapi.NewClient(srv.URL)
+	if _, err := anonymous.Status(ctx); !errors.Is(err, statusapi.ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized without credentials, got %v", err)
+	}
+
+	reader, _ := statusapi.NewClient(srv.URL, statusapi.WithBearerToken("reader"))
+	if _, err := reader.Subject(ctx, "svc"); err != nil {
+		t.Fatalf("read with token: %v", err)
+	}
+	if err := reader.Forget(ctx, "svc"); !errors.Is(err, statusapi.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden forgetting with a read token, got %v", err)
+	}
+
+	admin, _ := statusapi.NewClient(srv.URL, statusapi.WithBasicAuth("ops", "pw"))
+	if err := admin.Forget(ctx, "svc"); err != nil {
+		t.Fatalf("forget as admin: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package statusapi defines the JSON documents served by the heartbeat
+// monitor's status API (over HTTP and its NATS service) and a client for
+// the HTTP endpoints.
+//
+// Documents carry a Version. Fields may be added within a version; removing
+// or changing the meaning of a field bumps it, and Client refuses documents
+// newer than the Version it was built with.
+package statusapi
+
+import "time"
+
+// Version is the status API version this package speaks.
+const Version = 1
+
+// MonitorIDHeader carries the responding instance's service ID on replies
+// from the monitor's NATS service, so clients can tell replies from several
+// monitors apart.
+const MonitorIDHeader = "Heartbeat-Monitor-Id"
+
+// Status is the response of the status endpoint: every tracked subject.
+type Status struct {
+	Version    int             `json:"version"`
+	ObservedAt time.Time       `json:"observed_at"`
+	Subjects   []SubjectStatus `json:"subjects"`
+	// Shard is set by monitors that share the subject space with others;
+	// Subjects then holds only this monitor's shard.
+	Shard *Shard `json:"shard,omitempty"`
+}
+
+// Shard describes a sharded monitor's place in its group.
+type Shard struct {
+	Group   string   `json:"group"`
+	Member  string   `json:"member"`
+	Members []string `json:"members"`
+}
+
+// SubjectStatus is the monitor's view of one heartbeat subject.
+type SubjectStatus struct {
+	Subject       string         `json:"subject"`
+	Description   string         `json:"description"`
+	Host          string         `json:"host,omitempty"`
+	LastSeen      time.Time      `json:"last_seen"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Skew          string         `json:"skew"`
+	SkewExceeded  bool           `json:"skew_exceeded,omitempty"`
+	BootID        string         `json:"boot_id,omitempty"`
+	Sequence      uint64         `json:"seq,omitempty"`
+	Restarts      int            `json:"restarts,omitempty"`
+	LastRestart   time.Time      `json:"last_restart,omitempty"`
+	LostBeats     uint64         `json:"lost_beats,omitempty"`
+	Duplicate     bool           `json:"duplicate_publishers,omitempty"`
+	HostConflict  bool           `json:"host_conflict,omitempty"`
+	Hosts         []HostStatus   `json:"hosts,omitempty"`
+	Unverified    bool           `json:"unverified,omitempty"`
+	Interval      string         `json:"interval"`
+	Grace         *string        `json:"grace,omitempty"`
+	AllowedWindow string         `json:"allowed_window"`
+	Missing       bool           `json:"missing"`
+	MissFor       string         `json:"miss_for,omitempty"`
+	MissCount     int            `json:"miss_count,omitempty"`
+	AlertActive   bool           `json:"alert_active"`
+	Acknowledged  bool           `json:"acknowledged,omitempty"`
+	SilencedUntil *time.Time     `json:"silenced_until,omitempty"`
+	RecentBeats   []time.Time    `json:"recent_beats,omitempty"`
+	Uptime        []UptimeWindow `json:"uptime,omitempty"`
+	Cadence       *Cadence       `json:"cadence,omitempty"`
+}
+
+// HostStatus is one publishing host of a subject tracked per host.
+type HostStatus struct {
+	Host        string    `json:"host"`
+	LastSeen    time.Time `json:"last_seen"`
+	Missing     bool      `json:"missing"`
+	AlertActive bool      `json:"alert_active,omitempty"`
+}
+
+// Cadence summarises the gaps between a subject's recent beats.
+type Cadence struct {
+	Samples       int    `json:"samples"`
+	Mean          string `json:"mean"`
+	P95           string `json:"p95"`
+	Max           string `json:"max"`
+	Jitter        string `json:"jitter"`
+	DriftExceeded bool   `json:"drift_exceeded,omitempty"`
+}
+
+// UptimeWindow is a subject's availability over one trailing window.
+type UptimeWindow struct {
+	Window string `json:"window"`
+	Uptime
+}
+
+// Uptime is a subject's availability over a range.
+type Uptime struct {
+	UptimePercent float64 `json:"uptime_percent"`
+	Downtime      string  `json:"downtime"`
+	Outages       int     `json:"outages"`
+	// Observed is how much of the range the monitor has data for; it is
+	// shorter than the range for subjects first seen inside it.
+	Observed string `json:"observed"`
+}
+
+// Report is the response of the report endpoint: uptime per subject over
+// a range.
+type Report struct {
+	Version  int             `json:"version"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Subjects []SubjectReport `json:"subjects"`
+}
+
+// SubjectReport is one subject's availability in a Report.
+type SubjectReport struct {
+	Subject     string `json:"subject"`
+	Description string `json:"description,omitempty"`
+	Uptime
+}
+
+// History is a subject's recent beats and notifications, oldest first.
+type History struct {
+	Version int            `json:"version"`
+	Subject string         `json:"subject"`
+	Entries []HistoryEntry `json:"entries"`
+}
+
+// HistoryEntry is one beat or notification in a subject's timeline. Kind is
+// "beat" or one of the notification kinds (alert, resolved, expired,
+// notice).
+type HistoryEntry struct {
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	At          time.Time `json:"at"`
+	GeneratedAt time.Time `json:"generated_at,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	Seq         uint64    `json:"seq,omitempty"`
+	BootID      string    `json:"boot_id,omitempty"`
+	MissCount   int       `json:"miss_count,omitempty"`
+	MissFor     string    `json:"miss_for,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// Event is the payload of notification events on the /events stream.
+type Event struct {
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	Description string    `json:"description,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	LastSeen    time.Time `json:"last_seen"`
+	Interval    string    `json:"interval,omitempty"`
+	MissCount   int       `json:"miss_count,omitempty"`
+	MissFor     string    `json:"miss_for,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// Update is the payload of update events on the /events stream: subjects
+// whose status changed since the previous event, and subjects that went
+// away.
+type Update struct {
+	ObservedAt time.Time       `json:"observed_at"`
+	Subjects   []SubjectStatus `json:"subjects,omitempty"`
+	Removed    []string        `json:"removed,omitempty"`
+}
+
+// AdminResult is the reply to an admin operation (forget, reload, silence,
+// ack).
+type AdminResult struct {
+	Subject       string     `json:"subject,omitempty"`
+	OK            bool       `json:"ok"`
+	Error         string     `json:"error,omitempty"`
+	Changes       []string   `json:"changes,omitempty"`
+	SilencedUntil *time.Time `json:"silenced_until,omitempty"`
+}